@@ -0,0 +1,97 @@
+package main
+
+import (
+	"fmt"
+	"proxy-engineering-thesis/model/relational"
+	"strings"
+)
+
+func runAudit(name string, args []string) error {
+	flags, apiUrl := apiFlags(name)
+	config := relational.AuditConfiguration{}
+	flags.BoolVar(&config.CheckAuditExtension, "check-audit-extension", true, "check whether pgaudit is installed")
+	flags.BoolVar(&config.CheckAuditLogs, "check-audit-logs", true, "check whether pgaudit logging is enabled")
+	flags.BoolVar(&config.CheckSuperusers, "check-superusers", true, "list superusers")
+	flags.BoolVar(&config.CheckAuthenticationMethod, "check-authentication", true, "check the password encryption")
+	flags.BoolVar(&config.CheckRemoteAccess, "check-remote-access", true, "check addresses the database listens on")
+	maxSuperusers := flags.Int("max-superusers", 1, "number of superusers above which it's a finding")
+	failOnFindings := flags.Bool("fail-on-findings", false, "exit with an error when there are findings, e.g. in CI")
+	asJSON := flags.Bool("json", false, "print the audit result as JSON")
+	if err := parseFlags(flags, args, "<datasource id>"); err != nil {
+		return err
+	}
+
+	var result relational.AuditData
+	if err := newApiClient(*apiUrl).do("POST", "/api/audit/"+flags.Arg(0), config, &result); err != nil {
+		return err
+	}
+
+	findings := auditFindings(config, result, *maxSuperusers)
+	if *asJSON {
+		if err := printJSON(result); err != nil {
+			return err
+		}
+	} else {
+		printAudit(config, result, findings)
+	}
+
+	if *failOnFindings && len(findings) > 0 {
+		return fmt.Errorf("audit found %d problems", len(findings))
+	}
+	return nil
+}
+
+// auditFindings lists weaknesses of the database found by the checks which
+// were run.
+func auditFindings(config relational.AuditConfiguration, result relational.AuditData, maxSuperusers int) []string {
+	var findings []string
+	if config.CheckAuditExtension && !result.IsAuditExtensionEnabled {
+		findings = append(findings, "pgaudit extension isn't installed")
+	}
+	if config.CheckAuditLogs && !result.IsAuditLoggingEnabled {
+		findings = append(findings, "pgaudit logging isn't enabled")
+	}
+	if config.CheckSuperusers && len(result.Superusers) > maxSuperusers {
+		findings = append(findings, fmt.Sprintf("%d superusers, at most %d expected", len(result.Superusers), maxSuperusers))
+	}
+	if config.CheckAuthenticationMethod && result.AuthenticationMethod != "scram-sha-256" {
+		findings = append(findings, fmt.Sprintf("passwords are encrypted with %q instead of scram-sha-256", result.AuthenticationMethod))
+	}
+	if config.CheckRemoteAccess {
+		for _, hosts := range result.DatabaseHosts {
+			for _, host := range strings.Split(hosts, ",") {
+				if host = strings.TrimSpace(host); host == "*" || host == "0.0.0.0" || host == "::" {
+					findings = append(findings, fmt.Sprintf("database listens on all addresses (%s)", host))
+				}
+			}
+		}
+	}
+	return findings
+}
+
+func printAudit(config relational.AuditConfiguration, result relational.AuditData, findings []string) {
+	if config.CheckAuditExtension {
+		fmt.Printf("pgaudit extension:    %t\n", result.IsAuditExtensionEnabled)
+	}
+	if config.CheckAuditLogs {
+		fmt.Printf("pgaudit logging:      %t\n", result.IsAuditLoggingEnabled)
+	}
+	if config.CheckSuperusers {
+		fmt.Printf("superusers:           %s\n", strings.Join(result.Superusers, ", "))
+	}
+	if config.CheckAuthenticationMethod {
+		fmt.Printf("password encryption:  %s\n", result.AuthenticationMethod)
+	}
+	if config.CheckRemoteAccess {
+		fmt.Printf("listen addresses:     %s\n", strings.Join(result.DatabaseHosts, ", "))
+	}
+
+	if len(findings) == 0 {
+		fmt.Println("\nno findings")
+		return
+	}
+	fmt.Println("\nfindings:")
+	for _, finding := range findings {
+		fmt.Printf("  - %s\n", finding)
+	}
+}
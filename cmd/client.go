@@ -0,0 +1,113 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"flag"
+	"fmt"
+	"io"
+	"net/http"
+	"os"
+	"strings"
+	"time"
+)
+
+const (
+	apiEnv        = "GOHARDER_API"
+	defaultApiUrl = "http://localhost:8888"
+)
+
+// apiClient calls the REST API of a running server.
+type apiClient struct {
+	baseUrl string
+	http    *http.Client
+}
+
+// apiFlags returns the flag set of a command talking to the API, with the
+// -api flag already defined.
+func apiFlags(name string) (*flag.FlagSet, *string) {
+	flags := flag.NewFlagSet(name, flag.ContinueOnError)
+	apiUrl := os.Getenv(apiEnv)
+	if apiUrl == "" {
+		apiUrl = defaultApiUrl
+	}
+	return flags, flags.String("api", apiUrl, "URL of the goharder server ("+apiEnv+")")
+}
+
+// parseFlags parses args and checks the number of positional arguments.
+func parseFlags(flags *flag.FlagSet, args []string, positional ...string) error {
+	flags.Usage = func() {
+		fmt.Fprintf(flags.Output(), "Usage: %s [flags] %s\n", flags.Name(), strings.Join(positional, " "))
+		flags.PrintDefaults()
+	}
+	if err := flags.Parse(args); err != nil {
+		if err == flag.ErrHelp {
+			return err
+		}
+		return errUsage
+	}
+	if flags.NArg() != len(positional) {
+		fmt.Fprintf(flags.Output(), "%s expects arguments: %s\n", flags.Name(), strings.Join(positional, " "))
+		return errUsage
+	}
+	return nil
+}
+
+func newApiClient(baseUrl string) *apiClient {
+	return &apiClient{
+		baseUrl: strings.TrimRight(baseUrl, "/"),
+		http:    &http.Client{Timeout: 2 * time.Minute},
+	}
+}
+
+// do sends the body as JSON and decodes the JSON response into the result,
+// when they're not nil. Responses other than 2xx are returned as errors with
+// the text sent by the server.
+func (c *apiClient) do(method, path string, body, result interface{}) error {
+	var reader io.Reader
+	if body != nil {
+		encoded, err := json.Marshal(body)
+		if err != nil {
+			return err
+		}
+		reader = bytes.NewReader(encoded)
+	}
+
+	req, err := http.NewRequest(method, c.baseUrl+path, reader)
+	if err != nil {
+		return err
+	}
+	if body != nil {
+		req.Header.Set("Content-Type", "application/json")
+	}
+
+	resp, err := c.http.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+
+	data, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return err
+	}
+
+	if resp.StatusCode < 200 || resp.StatusCode > 299 {
+		message := strings.TrimSpace(string(data))
+		if message == "" || message == "null" || message == `""` {
+			message = resp.Status
+		}
+		return fmt.Errorf("%s %s failed: %s", method, path, message)
+	}
+
+	if result == nil || len(data) == 0 {
+		return nil
+	}
+	return json.Unmarshal(data, result)
+}
+
+func printJSON(value interface{}) error {
+	encoder := json.NewEncoder(os.Stdout)
+	encoder.SetIndent("", "  ")
+	return encoder.Encode(value)
+}
@@ -0,0 +1,76 @@
+package main
+
+import (
+	"fmt"
+	"os"
+	"proxy-engineering-thesis/model"
+	"text/tabwriter"
+)
+
+func addDataSource(name string, args []string) error {
+	flags, apiUrl := apiFlags(name)
+	host := flags.String("host", "", "hostname of the database")
+	port := flags.String("port", "5432", "port of the database")
+	user := flags.String("user", "", "user of the database")
+	password := flags.String("password", "", "password of the user, PGPASSWORD when not set")
+	sslMode := flags.String("sslmode", "", "SSL mode of connections to the database, e.g. verify-full")
+	sslRootCert := flags.String("sslrootcert", "", "path of the CA certificate of the database")
+	if err := parseFlags(flags, args); err != nil {
+		return err
+	}
+	if *host == "" || *user == "" {
+		return fmt.Errorf("-host and -user are required")
+	}
+	if *password == "" {
+		*password = os.Getenv("PGPASSWORD")
+	}
+
+	dataSource := model.DataSource{
+		Address:         model.Address{Hostname: *host, Port: *port},
+		Credential:      model.Credential{Username: *user, Password: *password},
+		SSLMode:         *sslMode,
+		SSLRootCertFile: *sslRootCert,
+	}
+	if err := newApiClient(*apiUrl).do("POST", "/api/datasource", dataSource, &dataSource); err != nil {
+		return err
+	}
+	fmt.Println(dataSource.ID)
+	return nil
+}
+
+func listDataSources(name string, args []string) error {
+	flags, apiUrl := apiFlags(name)
+	asJSON := flags.Bool("json", false, "print datasources as JSON")
+	if err := parseFlags(flags, args); err != nil {
+		return err
+	}
+
+	var dataSources []model.DataSource
+	if err := newApiClient(*apiUrl).do("GET", "/api/datasource", nil, &dataSources); err != nil {
+		return err
+	}
+
+	// passwords are never printed
+	for i := range dataSources {
+		dataSources[i].Password = ""
+	}
+	if *asJSON {
+		return printJSON(dataSources)
+	}
+
+	w := tabwriter.NewWriter(os.Stdout, 0, 4, 2, ' ', 0)
+	fmt.Fprintln(w, "ID\tHOST\tPORT\tUSER\tSSL MODE")
+	for _, ds := range dataSources {
+		fmt.Fprintf(w, "%d\t%s\t%s\t%s\t%s\n", ds.ID, ds.Hostname, ds.Port, ds.Username, ds.SSLMode)
+	}
+	return w.Flush()
+}
+
+func removeDataSource(name string, args []string) error {
+	flags, apiUrl := apiFlags(name)
+	if err := parseFlags(flags, args, "<id>"); err != nil {
+		return err
+	}
+
+	return newApiClient(*apiUrl).do("DELETE", "/api/datasource/"+flags.Arg(0), nil, nil)
+}
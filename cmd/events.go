@@ -0,0 +1,148 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/url"
+	"os"
+	"proxy-engineering-thesis/model"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+)
+
+const eventsPageSize = 1000
+
+// eventsPage is a page of events returned by the API, newest first.
+type eventsPage struct {
+	Events []model.SecurityEvent
+	Total  int64
+}
+
+func tailEvents(name string, args []string) error {
+	flags, apiUrl := apiFlags(name)
+	proxyId := flags.Uint("proxy", 0, "only events of the proxy")
+	severity := flags.String("severity", "", "only events of the comma separated severities")
+	clientIp := flags.String("client-ip", "", "only events of the client address")
+	last := flags.Int("n", 10, "number of the latest events printed first")
+	follow := flags.Bool("f", false, "keep printing new events")
+	interval := flags.Duration("interval", 2*time.Second, "how often new events are fetched with -f")
+	asJSON := flags.Bool("json", false, "print events as JSON, one per line")
+	if err := parseFlags(flags, args); err != nil {
+		return err
+	}
+	if *last < 0 || *last > eventsPageSize {
+		return fmt.Errorf("-n must be between 0 and %d", eventsPageSize)
+	}
+	if *interval <= 0 {
+		return fmt.Errorf("-interval must be positive")
+	}
+
+	query := url.Values{}
+	if *proxyId != 0 {
+		query.Set("proxy", strconv.FormatUint(uint64(*proxyId), 10))
+	}
+	if *severity != "" {
+		query.Set("severity", *severity)
+	}
+	if *clientIp != "" {
+		query.Set("clientIp", *clientIp)
+	}
+
+	client := newApiClient(*apiUrl)
+	printer := eventPrinter{asJSON: *asJSON}
+
+	var page eventsPage
+	query.Set("size", strconv.Itoa(eventsPageSize))
+	if err := client.do("GET", "/api/events?"+query.Encode(), nil, &page); err != nil {
+		return err
+	}
+
+	// events are fetched newest first, they're printed oldest first
+	var lastEvent model.SecurityEvent
+	if len(page.Events) > 0 {
+		lastEvent = page.Events[0]
+	}
+	latest := page.Events
+	if len(latest) > *last {
+		latest = latest[:*last]
+	}
+	printer.print(latest)
+
+	for *follow {
+		time.Sleep(*interval)
+
+		events, err := fetchNewEvents(client, query, lastEvent)
+		if err != nil {
+			return err
+		}
+		if len(events) > 0 {
+			lastEvent = events[0]
+		}
+		printer.print(events)
+	}
+	return nil
+}
+
+// fetchNewEvents returns events stored after the last one, newest first.
+func fetchNewEvents(client *apiClient, query url.Values, lastEvent model.SecurityEvent) ([]model.SecurityEvent, error) {
+	if !lastEvent.Timestamp.IsZero() {
+		query.Set("from", lastEvent.Timestamp.Format(time.RFC3339Nano))
+	}
+
+	var events []model.SecurityEvent
+	for pageNumber := 1; ; pageNumber++ {
+		query.Set("page", strconv.Itoa(pageNumber))
+		var page eventsPage
+		if err := client.do("GET", "/api/events?"+query.Encode(), nil, &page); err != nil {
+			return nil, err
+		}
+
+		for _, event := range page.Events {
+			// events of the same time as the last one are fetched again
+			if event.ID > lastEvent.ID {
+				events = append(events, event)
+			}
+		}
+		if len(page.Events) < eventsPageSize {
+			return events, nil
+		}
+	}
+}
+
+type eventPrinter struct {
+	asJSON bool
+}
+
+// print writes the events, given newest first, in the order they happened.
+func (p eventPrinter) print(events []model.SecurityEvent) {
+	sorted := make([]model.SecurityEvent, len(events))
+	copy(sorted, events)
+	sort.SliceStable(sorted, func(i, j int) bool {
+		return sorted[i].ID < sorted[j].ID
+	})
+
+	encoder := json.NewEncoder(os.Stdout)
+	for _, e := range sorted {
+		if p.asJSON {
+			encoder.Encode(e)
+			continue
+		}
+
+		fields := []string{
+			e.Timestamp.Format(time.RFC3339),
+			strings.ToUpper(e.Severity),
+			"proxy=" + e.ProxyName,
+			"client=" + e.ClientAddress,
+			"user=" + e.DbUser,
+			"database=" + e.Database,
+			"action=" + e.Action,
+		}
+		if e.Rules != "" {
+			fields = append(fields, "rules="+e.Rules)
+		}
+		fields = append(fields, "query="+strconv.Quote(e.Query))
+		fmt.Println(strings.Join(fields, " "))
+	}
+}
@@ -1,13 +1,84 @@
 package main
 
-import "proxy-engineering-thesis/server"
+import (
+	"errors"
+	"flag"
+	"fmt"
+	"os"
+	"sort"
+	"strings"
+)
+
+// command is a subcommand of the CLI, e.g. "proxy start". Subcommands return
+// errUsage after printing the usage themselves.
+type command struct {
+	summary string
+	run     func(name string, args []string) error
+}
+
+var errUsage = errors.New("usage")
+
+var commands = map[string]command{
+	"serve":           {"run the server and the proxies", serve},
+	"datasource add":  {"add a datasource", addDataSource},
+	"datasource list": {"list datasources", listDataSources},
+	"datasource rm":   {"remove a datasource", removeDataSource},
+	"proxy add":       {"add a proxy", addProxy},
+	"proxy list":      {"list proxies", listProxies},
+	"proxy start":     {"start a proxy", startProxy},
+	"proxy stop":      {"stop a proxy", stopProxy},
+	"audit run":       {"audit the configuration of a datasource", runAudit},
+	"rules import":    {"create or update detection rules from a JSON file", importRules},
+	"rules export":    {"write detection rules to a JSON file", exportRules},
+	"events tail":     {"print the latest security events and follow new ones", tailEvents},
+}
 
 func main() {
-	//agent := aws.NewCloudWatchConfiguration("GRUPA", "STREAM", "")
-	//agent.InitLogStore()
-	//err := agent.SendLog("cale te sie robi o i drugi raz")
-	//if err != nil {
-	//	fmt.Printf("%v\n", err)
-	//}
-	server.StartServer()
+	name, cmd, args, found := findCommand(os.Args[1:])
+	if !found {
+		printUsage()
+		os.Exit(2)
+	}
+
+	err := cmd.run("goharder "+name, args)
+	switch {
+	case err == nil, err == flag.ErrHelp:
+	case err == errUsage:
+		os.Exit(2)
+	default:
+		fmt.Fprintln(os.Stderr, "error:", err)
+		os.Exit(1)
+	}
+}
+
+// findCommand matches the command of one or two words starting the args.
+func findCommand(args []string) (string, command, []string, bool) {
+	if len(args) >= 2 {
+		if cmd, present := commands[args[0]+" "+args[1]]; present {
+			return args[0] + " " + args[1], cmd, args[2:], true
+		}
+	}
+	if len(args) >= 1 {
+		if cmd, present := commands[args[0]]; present {
+			return args[0], cmd, args[1:], true
+		}
+	}
+	return "", command{}, nil, false
+}
+
+func printUsage() {
+	names := make([]string, 0, len(commands))
+	for name := range commands {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	var b strings.Builder
+	b.WriteString("Usage: goharder <command> [flags]\n\nCommands:\n")
+	for _, name := range names {
+		fmt.Fprintf(&b, "  %-18s %s\n", name, commands[name].summary)
+	}
+	b.WriteString("\nRun 'goharder <command> -h' for flags of a command. Commands other than serve\n")
+	b.WriteString("talk to the REST API given by -api or " + apiEnv + ".\n")
+	fmt.Fprint(os.Stderr, b.String())
 }
@@ -0,0 +1,109 @@
+package main
+
+import (
+	"fmt"
+	"net/url"
+	"os"
+	"proxy-engineering-thesis/model"
+	"strconv"
+	"strings"
+	"text/tabwriter"
+)
+
+var proxyModes = []string{"full", "prevention", "detection"}
+
+func addProxy(name string, args []string) error {
+	flags, apiUrl := apiFlags(name)
+	proxyName := flags.String("name", "", "name of the proxy")
+	host := flags.String("host", "0.0.0.0", "address the proxy listens on")
+	port := flags.String("port", "", "port the proxy listens on")
+	dataSourceId := flags.Uint("datasource", 0, "id of the datasource the proxy forwards to")
+	detectionEngine := flags.String("detection-engine", "", "detection engine, the default one when not set")
+	blacklistScope := flags.String("blacklist-scope", "", "global or proxy")
+	slowQueryThreshold := flags.String("slow-query-threshold", "", "duration above which queries are recorded as slow, e.g. 500ms")
+	tlsCert := flags.String("tls-cert", "", "path of the TLS certificate offered to clients")
+	tlsKey := flags.String("tls-key", "", "path of the key of the TLS certificate")
+	if err := parseFlags(flags, args); err != nil {
+		return err
+	}
+	if *proxyName == "" || *port == "" || *dataSourceId == 0 {
+		return fmt.Errorf("-name, -port and -datasource are required")
+	}
+
+	proxy := model.ProxyDto{
+		Name:               *proxyName,
+		Address:            model.Address{Hostname: *host, Port: *port},
+		DataSourceID:       *dataSourceId,
+		DetectionEngine:    *detectionEngine,
+		BlacklistScope:     *blacklistScope,
+		SlowQueryThreshold: *slowQueryThreshold,
+		TLSCertificateFile: *tlsCert,
+		TLSKeyFile:         *tlsKey,
+	}
+	if err := newApiClient(*apiUrl).do("POST", "/api/proxy", proxy, &proxy); err != nil {
+		return err
+	}
+	fmt.Println(proxy.ID)
+	return nil
+}
+
+func listProxies(name string, args []string) error {
+	flags, apiUrl := apiFlags(name)
+	asJSON := flags.Bool("json", false, "print proxies as JSON")
+	if err := parseFlags(flags, args); err != nil {
+		return err
+	}
+
+	var proxies []model.ProxyDto
+	if err := newApiClient(*apiUrl).do("GET", "/api/proxy", nil, &proxies); err != nil {
+		return err
+	}
+	if *asJSON {
+		return printJSON(proxies)
+	}
+
+	w := tabwriter.NewWriter(os.Stdout, 0, 4, 2, ' ', 0)
+	fmt.Fprintln(w, "ID\tNAME\tADDRESS\tDATASOURCE\tRUNNING\tMODE")
+	for _, p := range proxies {
+		fmt.Fprintf(w, "%d\t%s\t%s\t%d\t%t\t%s\n", p.ID, p.Name, p.CreateHostString(), p.DataSourceID, p.Running, p.RunMode)
+	}
+	return w.Flush()
+}
+
+func startProxy(name string, args []string) error {
+	flags, apiUrl := apiFlags(name)
+	mode := flags.String("mode", "full", "protection mode: "+strings.Join(proxyModes, ", "))
+	if err := parseFlags(flags, args, "<id>"); err != nil {
+		return err
+	}
+	if !isProxyMode(*mode) {
+		return fmt.Errorf("unknown mode %s, expected one of: %s", *mode, strings.Join(proxyModes, ", "))
+	}
+	if _, err := strconv.ParseUint(flags.Arg(0), 10, 64); err != nil {
+		return fmt.Errorf("invalid proxy id: %s", flags.Arg(0))
+	}
+
+	path := fmt.Sprintf("/api/proxy/%s/start?mode=%s", flags.Arg(0), url.QueryEscape(*mode))
+	return newApiClient(*apiUrl).do("PUT", path, nil, nil)
+}
+
+func stopProxy(name string, args []string) error {
+	flags, apiUrl := apiFlags(name)
+	if err := parseFlags(flags, args, "<id>"); err != nil {
+		return err
+	}
+	if _, err := strconv.ParseUint(flags.Arg(0), 10, 64); err != nil {
+		return fmt.Errorf("invalid proxy id: %s", flags.Arg(0))
+	}
+
+	return newApiClient(*apiUrl).do("PUT", "/api/proxy/"+flags.Arg(0)+"/stop", nil, nil)
+}
+
+func isProxyMode(mode string) bool {
+	for _, m := range proxyModes {
+		if strings.EqualFold(m, mode) {
+			return true
+		}
+	}
+	return false
+}
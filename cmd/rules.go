@@ -0,0 +1,128 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"proxy-engineering-thesis/model"
+)
+
+// exportedRule is a rule without its database fields, so that files can be
+// imported into other servers.
+type exportedRule struct {
+	Name         string
+	PatternType  string
+	Pattern      string
+	Severity     string
+	Enabled      bool
+	Description  string
+	SQLState     string
+	ErrorMessage string
+}
+
+func exportRules(name string, args []string) error {
+	flags, apiUrl := apiFlags(name)
+	file := flags.String("file", "", "path of the JSON file, stdout when not set")
+	if err := parseFlags(flags, args); err != nil {
+		return err
+	}
+
+	var rules []model.Rule
+	if err := newApiClient(*apiUrl).do("GET", "/api/rules", nil, &rules); err != nil {
+		return err
+	}
+
+	exported := make([]exportedRule, 0, len(rules))
+	for _, r := range rules {
+		exported = append(exported, exportedRule{
+			Name:         r.Name,
+			PatternType:  r.PatternType,
+			Pattern:      r.Pattern,
+			Severity:     r.Severity,
+			Enabled:      r.Enabled,
+			Description:  r.Description,
+			SQLState:     r.SQLState,
+			ErrorMessage: r.ErrorMessage,
+		})
+	}
+
+	if *file == "" {
+		return printJSON(exported)
+	}
+
+	data, err := json.MarshalIndent(exported, "", "  ")
+	if err != nil {
+		return err
+	}
+	return os.WriteFile(*file, append(data, '\n'), 0644)
+}
+
+// importRules creates rules of the file, rules with the name of an existing
+// one replace it. Importing the same file again changes nothing.
+func importRules(name string, args []string) error {
+	flags, apiUrl := apiFlags(name)
+	file := flags.String("file", "", "path of the JSON file, stdin when not set")
+	if err := parseFlags(flags, args); err != nil {
+		return err
+	}
+
+	var reader io.Reader = os.Stdin
+	if *file != "" {
+		f, err := os.Open(*file)
+		if err != nil {
+			return err
+		}
+		defer f.Close()
+		reader = f
+	}
+
+	var imported []exportedRule
+	if err := json.NewDecoder(reader).Decode(&imported); err != nil {
+		return fmt.Errorf("invalid rules file: %v", err)
+	}
+
+	client := newApiClient(*apiUrl)
+	var existing []model.Rule
+	if err := client.do("GET", "/api/rules", nil, &existing); err != nil {
+		return err
+	}
+	idsByName := make(map[string]uint, len(existing))
+	for _, r := range existing {
+		idsByName[r.Name] = r.ID
+	}
+
+	created, updated := 0, 0
+	for _, r := range imported {
+		if r.Name == "" {
+			return fmt.Errorf("rules need a name")
+		}
+
+		rule := model.Rule{
+			Name:         r.Name,
+			PatternType:  r.PatternType,
+			Pattern:      r.Pattern,
+			Severity:     r.Severity,
+			Enabled:      r.Enabled,
+			Description:  r.Description,
+			SQLState:     r.SQLState,
+			ErrorMessage: r.ErrorMessage,
+		}
+		if id, present := idsByName[r.Name]; present {
+			if err := client.do("PUT", fmt.Sprintf("/api/rules/%d", id), rule, nil); err != nil {
+				return fmt.Errorf("failed to update rule %s: %v", r.Name, err)
+			}
+			updated++
+			continue
+		}
+
+		if err := client.do("POST", "/api/rules", rule, &rule); err != nil {
+			return fmt.Errorf("failed to create rule %s: %v", r.Name, err)
+		}
+		idsByName[r.Name] = rule.ID
+		created++
+	}
+
+	fmt.Printf("created %d rules, updated %d\n", created, updated)
+	return nil
+}
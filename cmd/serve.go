@@ -0,0 +1,24 @@
+package main
+
+import (
+	"fmt"
+	"proxy-engineering-thesis/internal/config"
+	"proxy-engineering-thesis/server"
+)
+
+func serve(name string, args []string) error {
+	flags := config.NewFlagSet(name)
+	cfg, err := config.LoadFlags(flags, args)
+	if err == config.ErrUsage {
+		return errUsage
+	}
+	if err != nil {
+		return err
+	}
+	if flags.NArg() > 0 {
+		return fmt.Errorf("unexpected arguments: %v", flags.Args())
+	}
+
+	server.StartServer(cfg)
+	return nil
+}
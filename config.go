@@ -2,26 +2,26 @@ package utils
 
 import (
 	"bufio"
-	"embed"
 	"io"
+	"os"
 	"strings"
 )
 
-//go:embed resources
-var resourcesDir embed.FS
-
 type Config map[string]string
 
+// ReadPropertiesBasedConfig reads key=value lines of the file, looked up on
+// the filesystem when the program runs.
 func ReadPropertiesBasedConfig(filename string) (Config, error) {
 	// init with some bogus data
 	config := Config{}
 	if len(filename) == 0 {
 		return config, nil
 	}
-	file, err := resourcesDir.Open(filename)
+	file, err := os.Open(filename)
 	if err != nil {
 		return nil, err
 	}
+	defer file.Close()
 
 	reader := bufio.NewReader(file)
 
@@ -0,0 +1,76 @@
+package alerting
+
+import (
+	"fmt"
+	"proxy-engineering-thesis/internal/logger"
+	"proxy-engineering-thesis/model"
+	"strings"
+	"time"
+)
+
+var log = logger.New("alerting")
+
+// Alert is sent to sinks for every malicious query detected by a proxy. Test
+// alerts are sent on demand, to check the configuration of a sink.
+type Alert struct {
+	Timestamp     time.Time
+	ProxyID       uint
+	ProxyName     string
+	SessionID     string
+	ClientAddress string
+	ClientIP      string
+	DbUser        string
+	Database      string
+	Query         string
+	Severity      string
+	Score         int
+	Rules         []string
+	Verdict       string
+	Action        string
+	Test          bool
+}
+
+func NewAlert(event model.SecurityEvent) Alert {
+	var rules []string
+	if event.Rules != "" {
+		rules = strings.Split(event.Rules, ",")
+	}
+
+	return Alert{
+		Timestamp:     event.Timestamp,
+		ProxyID:       event.ProxyID,
+		ProxyName:     event.ProxyName,
+		SessionID:     event.SessionID,
+		ClientAddress: event.ClientAddress,
+		ClientIP:      event.ClientIP,
+		DbUser:        event.DbUser,
+		Database:      event.Database,
+		Query:         event.Query,
+		Severity:      event.Severity,
+		Score:         event.Score,
+		Rules:         rules,
+		Verdict:       event.Verdict,
+		Action:        event.Action,
+	}
+}
+
+// Summary describes the alert in a single line, for sinks taking plain text.
+func (a Alert) Summary() string {
+	if a.Test {
+		return fmt.Sprintf("Test alert of proxy %s", a.ProxyName)
+	}
+	return fmt.Sprintf("Malicious query; IP - %s; user - %s; database - %s; %s",
+		a.ClientAddress, a.DbUser, a.Database, a.Verdict)
+}
+
+// NewTestAlert returns an alert used to check whether a sink is reachable.
+func NewTestAlert(proxyId uint, proxyName string) Alert {
+	return Alert{
+		Timestamp: time.Now(),
+		ProxyID:   proxyId,
+		ProxyName: proxyName,
+		Severity:  "low",
+		Action:    model.ActionLogged,
+		Test:      true,
+	}
+}
@@ -0,0 +1,33 @@
+package alerting
+
+import (
+	"proxy-engineering-thesis/internal/aws"
+	"time"
+)
+
+// CloudWatchSink sends summaries of alerts to a CloudWatch log stream. Alerts
+// are shipped in batches in the background, the last ones when the sink is
+// closed.
+type CloudWatchSink struct {
+	shipper *aws.LogShipper
+}
+
+func NewCloudWatchSink(config aws.ShipperConfig) (*CloudWatchSink, error) {
+	shipper, err := aws.NewLogShipper(config)
+	if err != nil {
+		return nil, err
+	}
+	return &CloudWatchSink{shipper: shipper}, nil
+}
+
+func (c *CloudWatchSink) Send(alert Alert) error {
+	timestamp := alert.Timestamp
+	if timestamp.IsZero() {
+		timestamp = time.Now()
+	}
+	return c.shipper.Send(alert.Summary(), timestamp)
+}
+
+func (c *CloudWatchSink) Close() error {
+	return c.shipper.Close()
+}
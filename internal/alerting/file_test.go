@@ -0,0 +1,65 @@
+package alerting
+
+import (
+	"bufio"
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestFileSinkAppendsJSONLines(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "alerts", "p1.jsonl")
+
+	// alerts of a previous run are kept
+	for run, proxies := range [][]string{{"p1", "p2"}, {"p3"}} {
+		sink, err := NewFileSink(path)
+		if err != nil {
+			t.Fatalf("run %d: unexpected error: %v", run, err)
+		}
+		for _, proxy := range proxies {
+			if err := sink.Send(Alert{ProxyName: proxy, Query: "select 1\nunion select 2"}); err != nil {
+				t.Fatalf("run %d: unexpected error: %v", run, err)
+			}
+		}
+		if err := sink.Close(); err != nil {
+			t.Fatalf("run %d: unexpected error: %v", run, err)
+		}
+	}
+
+	file, err := os.Open(path)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	defer file.Close()
+
+	var proxies []string
+	scanner := bufio.NewScanner(file)
+	for scanner.Scan() {
+		var alert Alert
+		if err := json.Unmarshal(scanner.Bytes(), &alert); err != nil {
+			t.Fatalf("line %d isn't a JSON object: %q", len(proxies)+1, scanner.Text())
+		}
+		proxies = append(proxies, alert.ProxyName)
+	}
+	if len(proxies) != 3 || proxies[0] != "p1" || proxies[1] != "p2" || proxies[2] != "p3" {
+		t.Errorf("got alerts of %v, want p1, p2 and p3", proxies)
+	}
+}
+
+func TestFileSinkPermissions(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "p1.jsonl")
+	sink, err := NewFileSink(path)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	defer sink.Close()
+
+	info, err := os.Stat(path)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if mode := info.Mode().Perm(); mode != 0600 {
+		t.Errorf("file mode %v, want 0600", mode)
+	}
+}
@@ -0,0 +1,235 @@
+package alerting
+
+import (
+	"fmt"
+	"proxy-engineering-thesis/internal/aws"
+	"proxy-engineering-thesis/internal/proxy/detection"
+	"proxy-engineering-thesis/model"
+	"strings"
+	"sync"
+	"time"
+
+	"gorm.io/gorm"
+)
+
+// alerts waiting for a slow sink are dropped once its queue is full, so that
+// sessions never wait for alerting
+const queueSize = 256
+
+// AlertSink delivers alerts to an external system.
+type AlertSink interface {
+	Send(alert Alert) error
+	Close() error
+}
+
+// NewSink creates the sink described by the config. Connections are opened
+// lazily, when the first alert is sent.
+func NewSink(config model.AlertSink) (AlertSink, error) {
+	if err := ValidateConfig(config); err != nil {
+		return nil, err
+	}
+
+	switch strings.ToLower(config.Type) {
+	case model.CloudWatchSink:
+		flushInterval, _ := parseFlushInterval(config.FlushInterval)
+		return NewCloudWatchSink(aws.ShipperConfig{
+			LogGroupName:  config.LogGroupName,
+			LogStreamName: config.LogStreamName,
+			Region:        config.Region,
+			Profile:       config.Profile,
+			Endpoint:      config.Endpoint,
+			FlushInterval: flushInterval,
+		})
+	case model.WebhookSink:
+		return NewWebhookSink(config.Target, config.Secret, config.MaxRetries), nil
+	case model.SyslogSink:
+		return NewSyslogSink(config.Network, config.Target), nil
+	case model.FileSink:
+		return NewFileSink(config.Target)
+	}
+	return nil, fmt.Errorf("unknown alert sink type: %s", config.Type)
+}
+
+// ValidateConfig checks the config of a sink without connecting anywhere.
+func ValidateConfig(config model.AlertSink) error {
+	if config.MinSeverity != "" && detection.GetSeverityWeight(config.MinSeverity) == 0 {
+		return fmt.Errorf("unknown severity: %s", config.MinSeverity)
+	}
+	if config.MaxRetries < 0 {
+		return fmt.Errorf("max retries can't be negative")
+	}
+
+	switch strings.ToLower(config.Type) {
+	case model.CloudWatchSink:
+		if config.LogGroupName == "" || config.LogStreamName == "" {
+			return fmt.Errorf("CloudWatch sink requires log group and log stream names")
+		}
+		if config.Endpoint != "" && !strings.HasPrefix(config.Endpoint, "http://") && !strings.HasPrefix(config.Endpoint, "https://") {
+			return fmt.Errorf("CloudWatch endpoint must be an http or https URL: %s", config.Endpoint)
+		}
+		if _, err := parseFlushInterval(config.FlushInterval); err != nil {
+			return err
+		}
+	case model.WebhookSink:
+		if !strings.HasPrefix(config.Target, "http://") && !strings.HasPrefix(config.Target, "https://") {
+			return fmt.Errorf("webhook sink requires an http or https URL: %s", config.Target)
+		}
+	case model.SyslogSink:
+		switch strings.ToLower(config.Network) {
+		case "", "udp", "tcp":
+		default:
+			return fmt.Errorf("unknown syslog network: %s", config.Network)
+		}
+		if config.Target == "" {
+			return fmt.Errorf("syslog sink requires an address")
+		}
+	case model.FileSink:
+		if config.Target == "" {
+			return fmt.Errorf("file sink requires a path")
+		}
+	default:
+		return fmt.Errorf("unknown alert sink type: %s", config.Type)
+	}
+	return nil
+}
+
+func parseFlushInterval(flushInterval string) (time.Duration, error) {
+	if flushInterval == "" {
+		return 0, nil
+	}
+	interval, err := time.ParseDuration(flushInterval)
+	if err != nil || interval <= 0 {
+		return 0, fmt.Errorf("invalid flush interval: %s", flushInterval)
+	}
+	return interval, nil
+}
+
+// sinkWorker delivers alerts of at least the minimum severity to a sink, one
+// at a time and in order.
+type sinkWorker struct {
+	name        string
+	config      model.AlertSink
+	sink        AlertSink
+	minSeverity int
+	alerts      chan Alert
+	done        chan struct{}
+}
+
+func newSinkWorker(name string, sink AlertSink, config model.AlertSink) *sinkWorker {
+	w := &sinkWorker{
+		name:        name,
+		config:      sinkKey(config),
+		sink:        sink,
+		minSeverity: detection.GetSeverityWeight(config.MinSeverity),
+		alerts:      make(chan Alert, queueSize),
+		done:        make(chan struct{}),
+	}
+	go w.run()
+	return w
+}
+
+func (w *sinkWorker) run() {
+	defer close(w.done)
+	for alert := range w.alerts {
+		if err := w.sink.Send(alert); err != nil {
+			log.Warn("failed to send alert", "sink", w.name, "error", err)
+		}
+	}
+	if err := w.sink.Close(); err != nil {
+		log.Warn("failed to close alert sink", "sink", w.name, "error", err)
+	}
+}
+
+func (w *sinkWorker) accepts(alert Alert) bool {
+	return detection.GetSeverityWeight(alert.Severity) >= w.minSeverity
+}
+
+// sinkKey returns the config without timestamps, for finding sinks whose
+// config didn't change.
+func sinkKey(config model.AlertSink) model.AlertSink {
+	config.CreatedAt = time.Time{}
+	config.UpdatedAt = time.Time{}
+	config.DeletedAt = gorm.DeletedAt{}
+	return config
+}
+
+// stop waits until queued alerts are sent and closes the sink.
+func (w *sinkWorker) stop() {
+	close(w.alerts)
+	<-w.done
+}
+
+// Dispatcher sends alerts of a proxy to its sinks in the background. Sinks can
+// be replaced while the proxy is running.
+type Dispatcher struct {
+	workers []*sinkWorker
+	lock    sync.RWMutex
+	// setLock serializes replacing of sinks, which may take a while
+	setLock sync.Mutex
+}
+
+func NewDispatcher() *Dispatcher {
+	return &Dispatcher{}
+}
+
+// SetSinks replaces sinks of the dispatcher. Sinks whose config didn't change
+// are kept, so they live as long as the proxy. Previous sinks are closed after
+// sending alerts already queued for them.
+func (d *Dispatcher) SetSinks(configs []model.AlertSink) {
+	d.setLock.Lock()
+	defer d.setLock.Unlock()
+
+	d.lock.RLock()
+	unchanged := make(map[model.AlertSink]*sinkWorker, len(d.workers))
+	for _, w := range d.workers {
+		unchanged[w.config] = w
+	}
+	d.lock.RUnlock()
+
+	var workers []*sinkWorker
+	for _, config := range configs {
+		if w, present := unchanged[sinkKey(config)]; present {
+			workers = append(workers, w)
+			delete(unchanged, w.config)
+			continue
+		}
+
+		name := fmt.Sprintf("%s/%d", strings.ToLower(config.Type), config.ID)
+		sink, err := NewSink(config)
+		if err != nil {
+			log.Error("failed to create alert sink", "sink", name, "error", err)
+			continue
+		}
+		workers = append(workers, newSinkWorker(name, sink, config))
+	}
+
+	d.lock.Lock()
+	d.workers = workers
+	d.lock.Unlock()
+
+	for _, w := range unchanged {
+		w.stop()
+	}
+}
+
+// Dispatch queues the alert for every sink interested in its severity.
+func (d *Dispatcher) Dispatch(alert Alert) {
+	d.lock.RLock()
+	defer d.lock.RUnlock()
+
+	for _, w := range d.workers {
+		if !w.accepts(alert) {
+			continue
+		}
+		select {
+		case w.alerts <- alert:
+		default:
+			log.Warn("alert queue is full, dropping alert", "sink", w.name, "proxy", alert.ProxyName)
+		}
+	}
+}
+
+// Close sends queued alerts and closes all sinks.
+func (d *Dispatcher) Close() {
+	d.SetSinks(nil)
+}
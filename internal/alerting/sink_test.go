@@ -0,0 +1,133 @@
+package alerting
+
+import (
+	"proxy-engineering-thesis/model"
+	"sync"
+	"testing"
+	"time"
+)
+
+// recordingSink records alerts it's sent. Sends block while the sink is held.
+type recordingSink struct {
+	lock    sync.Mutex
+	alerts  []Alert
+	release chan struct{}
+	closed  bool
+}
+
+func newRecordingSink(held bool) *recordingSink {
+	sink := &recordingSink{release: make(chan struct{})}
+	if !held {
+		close(sink.release)
+	}
+	return sink
+}
+
+func (s *recordingSink) Send(alert Alert) error {
+	<-s.release
+	s.lock.Lock()
+	defer s.lock.Unlock()
+	s.alerts = append(s.alerts, alert)
+	return nil
+}
+
+func (s *recordingSink) Close() error {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+	s.closed = true
+	return nil
+}
+
+func (s *recordingSink) received() int {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+	return len(s.alerts)
+}
+
+// newTestDispatcher creates a dispatcher of the sinks, each with its own min
+// severity.
+func newTestDispatcher(sinks map[*recordingSink]string) *Dispatcher {
+	dispatcher := NewDispatcher()
+	var id uint
+	for sink, minSeverity := range sinks {
+		id++
+		config := model.AlertSink{Type: "test", MinSeverity: minSeverity}
+		config.ID = id
+		dispatcher.workers = append(dispatcher.workers, newSinkWorker("test", sink, config))
+	}
+	return dispatcher
+}
+
+func waitFor(t *testing.T, condition func() bool) {
+	t.Helper()
+	deadline := time.Now().Add(5 * time.Second)
+	for !condition() {
+		if time.Now().After(deadline) {
+			t.Fatal("condition not met in time")
+		}
+		time.Sleep(time.Millisecond)
+	}
+}
+
+func TestDispatcherIsolatesSlowSinks(t *testing.T) {
+	slow, fast := newRecordingSink(true), newRecordingSink(false)
+	dispatcher := newTestDispatcher(map[*recordingSink]string{slow: "", fast: ""})
+
+	for i := 0; i < 10; i++ {
+		dispatcher.Dispatch(Alert{Severity: "high", Score: i})
+	}
+
+	// the fast sink gets every alert while the slow one is still stuck
+	waitFor(t, func() bool { return fast.received() == 10 })
+	if received := slow.received(); received != 0 {
+		t.Fatalf("held sink received %d alerts", received)
+	}
+
+	// queued alerts are sent on close, in order
+	close(slow.release)
+	dispatcher.Close()
+	if slow.received() != 10 || !slow.closed || !fast.closed {
+		t.Fatalf("slow sink received %d alerts, closed %t %t", slow.received(), slow.closed, fast.closed)
+	}
+	for i, alert := range slow.alerts {
+		if alert.Score != i {
+			t.Fatalf("alert %d sent as %d", alert.Score, i)
+		}
+	}
+}
+
+func TestDispatcherDropsAlertsOfFullQueues(t *testing.T) {
+	slow := newRecordingSink(true)
+	dispatcher := newTestDispatcher(map[*recordingSink]string{slow: ""})
+
+	dispatched := queueSize + 100
+	start := time.Now()
+	for i := 0; i < dispatched; i++ {
+		dispatcher.Dispatch(Alert{Severity: "high"})
+	}
+	if elapsed := time.Since(start); elapsed > time.Second {
+		t.Errorf("dispatching to a held sink took %v", elapsed)
+	}
+
+	close(slow.release)
+	dispatcher.Close()
+
+	// the queue is full, besides one alert the worker is stuck sending
+	if received := slow.received(); received > queueSize+1 || received < queueSize {
+		t.Errorf("received %d of %d alerts, want the queue of %d", received, dispatched, queueSize)
+	}
+}
+
+func TestDispatcherMinSeverity(t *testing.T) {
+	all, high := newRecordingSink(false), newRecordingSink(false)
+	dispatcher := newTestDispatcher(map[*recordingSink]string{all: "", high: "high"})
+
+	for _, severity := range []string{"low", "medium", "high", "critical"} {
+		dispatcher.Dispatch(Alert{Severity: severity})
+	}
+	dispatcher.Close()
+
+	if all.received() != 4 || high.received() != 2 {
+		t.Errorf("sinks received %d and %d alerts, want 4 and 2", all.received(), high.received())
+	}
+}
@@ -0,0 +1,129 @@
+package alerting
+
+import (
+	"fmt"
+	"net"
+	"os"
+	"proxy-engineering-thesis/internal/proxy/detection"
+	"strings"
+	"sync"
+	"time"
+)
+
+const (
+	syslogAppName = "goharder"
+	syslogMsgId   = "ALERT"
+	// structured data id, 32473 is the enterprise number reserved for examples
+	syslogSDId = "alert@32473"
+	// security/authorization messages
+	syslogFacility = 4
+	syslogTimeout  = 5 * time.Second
+)
+
+// syslog severities of alerts
+var syslogSeverities = map[string]int{
+	detection.CriticalSeverity: 2,
+	detection.HighSeverity:     3,
+	detection.MediumSeverity:   4,
+	detection.LowSeverity:      5,
+}
+
+// SyslogSink sends alerts as RFC 5424 messages over UDP or TCP. TCP messages
+// are framed with octet counting, as described in RFC 6587.
+type SyslogSink struct {
+	network  string
+	address  string
+	hostname string
+	conn     net.Conn
+	lock     sync.Mutex
+}
+
+func NewSyslogSink(network, address string) *SyslogSink {
+	network = strings.ToLower(network)
+	if network == "" {
+		network = "udp"
+	}
+
+	hostname, err := os.Hostname()
+	if err != nil || hostname == "" {
+		hostname = "-"
+	}
+	return &SyslogSink{network: network, address: address, hostname: hostname}
+}
+
+func (s *SyslogSink) Send(alert Alert) error {
+	message := s.format(alert)
+	if s.network == "tcp" {
+		message = fmt.Sprintf("%d %s", len(message), message)
+	}
+
+	s.lock.Lock()
+	defer s.lock.Unlock()
+
+	// a broken TCP connection is only noticed when writing, so the message is
+	// written once more over a new connection
+	var err error
+	for attempt := 0; attempt < 2; attempt++ {
+		if s.conn == nil {
+			s.conn, err = net.DialTimeout(s.network, s.address, syslogTimeout)
+			if err != nil {
+				return err
+			}
+		}
+
+		s.conn.SetWriteDeadline(time.Now().Add(syslogTimeout))
+		if _, err = s.conn.Write([]byte(message)); err == nil {
+			return nil
+		}
+		s.conn.Close()
+		s.conn = nil
+	}
+	return err
+}
+
+func (s *SyslogSink) format(alert Alert) string {
+	severity, present := syslogSeverities[strings.ToLower(alert.Severity)]
+	if !present {
+		severity = syslogSeverities[detection.LowSeverity]
+	}
+
+	params := []string{
+		sdParam("proxy", alert.ProxyName),
+		sdParam("session", alert.SessionID),
+		sdParam("client", alert.ClientAddress),
+		sdParam("user", alert.DbUser),
+		sdParam("database", alert.Database),
+		sdParam("severity", alert.Severity),
+		sdParam("score", fmt.Sprint(alert.Score)),
+		sdParam("rules", strings.Join(alert.Rules, ",")),
+		sdParam("action", alert.Action),
+	}
+
+	return fmt.Sprintf("<%d>1 %s %s %s %d %s [%s %s] %s",
+		syslogFacility*8+severity,
+		alert.Timestamp.UTC().Format("2006-01-02T15:04:05.000000Z07:00"),
+		s.hostname, syslogAppName, os.Getpid(), syslogMsgId,
+		syslogSDId, strings.Join(params, " "),
+		alert.Summary())
+}
+
+// sdParam formats a structured data parameter, escaping characters which
+// RFC 5424 requires to be escaped in values.
+func sdParam(name, value string) string {
+	value = strings.ReplaceAll(value, `\`, `\\`)
+	value = strings.ReplaceAll(value, `"`, `\"`)
+	value = strings.ReplaceAll(value, `]`, `\]`)
+	return fmt.Sprintf(`%s="%s"`, name, value)
+}
+
+func (s *SyslogSink) Close() error {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+
+	if s.conn == nil {
+		return nil
+	}
+	err := s.conn.Close()
+	s.conn = nil
+	return err
+}
@@ -0,0 +1,178 @@
+package alerting
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"net"
+	"os"
+	"regexp"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+)
+
+var testAlert = Alert{
+	Timestamp:     time.Date(2024, 5, 6, 7, 8, 9, 123456000, time.UTC),
+	ProxyName:     `p"1]`,
+	SessionID:     "s1",
+	ClientAddress: "10.0.0.1:5000",
+	DbUser:        `a\b`,
+	Database:      "db",
+	Severity:      "high",
+	Score:         7,
+	Rules:         []string{"union-select", "or-tautology"},
+	Verdict:       "union-select (high)",
+	Action:        "blocked",
+}
+
+// rfc5424Message matches the header of a message and captures its priority,
+// hostname, process id and the rest of the message.
+var rfc5424Message = regexp.MustCompile(`^<(\d+)>1 2024-05-06T07:08:09\.123456Z (\S+) goharder (\d+) ALERT (.*)$`)
+
+func checkSyslogMessage(t *testing.T, message string) {
+	t.Helper()
+	groups := rfc5424Message.FindStringSubmatch(message)
+	if groups == nil {
+		t.Fatalf("not an RFC 5424 message: %q", message)
+	}
+	// security/authorization facility, error severity
+	if groups[1] != "35" {
+		t.Errorf("priority %s, want 35", groups[1])
+	}
+	if groups[3] != strconv.Itoa(os.Getpid()) {
+		t.Errorf("process id %s", groups[3])
+	}
+
+	wantData := `[alert@32473 proxy="p\"1\]" session="s1" client="10.0.0.1:5000" user="a\\b" database="db" ` +
+		`severity="high" score="7" rules="union-select,or-tautology" action="blocked"] `
+	if !strings.HasPrefix(groups[4], wantData) {
+		t.Errorf("structured data of %q, want %q", groups[4], wantData)
+	}
+	if summary := strings.TrimPrefix(groups[4], wantData); summary != testAlert.Summary() {
+		t.Errorf("message %q, want %q", summary, testAlert.Summary())
+	}
+}
+
+func TestSyslogSinkUDP(t *testing.T) {
+	conn, err := net.ListenPacket("udp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatalf("failed to listen: %v", err)
+	}
+	defer conn.Close()
+
+	sink := NewSyslogSink("", conn.LocalAddr().String())
+	defer sink.Close()
+
+	for i := 0; i < 2; i++ {
+		if err := sink.Send(testAlert); err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+
+		// every datagram is a single message, without framing
+		buff := make([]byte, 4096)
+		conn.SetReadDeadline(time.Now().Add(5 * time.Second))
+		n, _, err := conn.ReadFrom(buff)
+		if err != nil {
+			t.Fatalf("failed to read message: %v", err)
+		}
+		checkSyslogMessage(t, string(buff[:n]))
+	}
+}
+
+func TestSyslogSinkTCP(t *testing.T) {
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatalf("failed to listen: %v", err)
+	}
+	defer listener.Close()
+
+	sink := NewSyslogSink("TCP", listener.Addr().String())
+	defer sink.Close()
+
+	for i := 0; i < 2; i++ {
+		if err := sink.Send(testAlert); err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+	}
+
+	conn, err := listener.Accept()
+	if err != nil {
+		t.Fatalf("failed to accept: %v", err)
+	}
+	defer conn.Close()
+	conn.SetReadDeadline(time.Now().Add(5 * time.Second))
+	reader := bufio.NewReader(conn)
+
+	// messages sent over a single connection are framed by octet counting
+	for i := 0; i < 2; i++ {
+		length, err := reader.ReadString(' ')
+		if err != nil {
+			t.Fatalf("failed to read message length: %v", err)
+		}
+		count, err := strconv.Atoi(strings.TrimSuffix(length, " "))
+		if err != nil {
+			t.Fatalf("invalid message length %q", length)
+		}
+		message := make([]byte, count)
+		if _, err := io.ReadFull(reader, message); err != nil {
+			t.Fatalf("failed to read message: %v", err)
+		}
+		checkSyslogMessage(t, string(message))
+	}
+}
+
+func TestSyslogSinkTCPReconnects(t *testing.T) {
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatalf("failed to listen: %v", err)
+	}
+	defer listener.Close()
+
+	sink := NewSyslogSink("tcp", listener.Addr().String())
+	defer sink.Close()
+
+	if err := sink.Send(testAlert); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	first, err := listener.Accept()
+	if err != nil {
+		t.Fatalf("failed to accept: %v", err)
+	}
+	first.Close()
+
+	// the broken connection is noticed by a later write at the latest
+	received := make(chan string, 1)
+	go func() {
+		conn, err := listener.Accept()
+		if err != nil {
+			return
+		}
+		defer conn.Close()
+		var length int
+		reader := bufio.NewReader(conn)
+		if _, err := fmt.Fscanf(reader, "%d ", &length); err != nil {
+			return
+		}
+		message := make([]byte, length)
+		io.ReadFull(reader, message)
+		received <- string(message)
+	}()
+
+	deadline := time.Now().Add(5 * time.Second)
+	for {
+		if err := sink.Send(testAlert); err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+		select {
+		case message := <-received:
+			checkSyslogMessage(t, message)
+			return
+		case <-time.After(50 * time.Millisecond):
+		}
+		if time.Now().After(deadline) {
+			t.Fatal("no message received over a new connection")
+		}
+	}
+}
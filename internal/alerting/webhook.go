@@ -0,0 +1,106 @@
+package alerting
+
+import (
+	"bytes"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"time"
+)
+
+const (
+	DefaultWebhookRetries = 3
+
+	SignatureHeader = "X-Goharder-Signature"
+
+	webhookTimeout = 5 * time.Second
+	webhookBackoff = 500 * time.Millisecond
+)
+
+// WebhookSink posts alerts as JSON. With a secret, the payload is signed with
+// HMAC-SHA256 and the signature is sent in the X-Goharder-Signature header as
+// "sha256=<hex>". Failed deliveries are retried with exponential backoff,
+// except for rejections by the receiver.
+type WebhookSink struct {
+	url        string
+	secret     string
+	maxRetries int
+	// backoff is the delay before the first retry, it doubles with every next
+	backoff time.Duration
+	client  *http.Client
+}
+
+// NewWebhookSink creates a webhook sink, zero maxRetries uses the default.
+func NewWebhookSink(url, secret string, maxRetries int) *WebhookSink {
+	if maxRetries == 0 {
+		maxRetries = DefaultWebhookRetries
+	}
+	return &WebhookSink{
+		url:        url,
+		secret:     secret,
+		maxRetries: maxRetries,
+		backoff:    webhookBackoff,
+		client:     &http.Client{Timeout: webhookTimeout},
+	}
+}
+
+func (w *WebhookSink) Send(alert Alert) error {
+	payload, err := json.Marshal(alert)
+	if err != nil {
+		return err
+	}
+
+	for attempt := 0; ; attempt++ {
+		retry, err := w.post(payload)
+		if err == nil {
+			return nil
+		}
+		if !retry || attempt >= w.maxRetries {
+			return err
+		}
+		time.Sleep(w.backoff << attempt)
+	}
+}
+
+// post delivers the payload once and tells whether a failed delivery is worth
+// retrying.
+func (w *WebhookSink) post(payload []byte) (bool, error) {
+	req, err := http.NewRequest(http.MethodPost, w.url, bytes.NewReader(payload))
+	if err != nil {
+		return false, err
+	}
+	req.Header.Set("Content-Type", "application/json")
+	if w.secret != "" {
+		req.Header.Set(SignatureHeader, "sha256="+Sign(w.secret, payload))
+	}
+
+	resp, err := w.client.Do(req)
+	if err != nil {
+		return true, err
+	}
+	defer resp.Body.Close()
+	io.Copy(io.Discard, resp.Body)
+
+	if resp.StatusCode >= 200 && resp.StatusCode < 300 {
+		return false, nil
+	}
+	retry := resp.StatusCode >= 500 || resp.StatusCode == http.StatusTooManyRequests
+	return retry, fmt.Errorf("webhook responded with %s", resp.Status)
+}
+
+func (w *WebhookSink) Close() error {
+	w.client.CloseIdleConnections()
+	return nil
+}
+
+// Sign returns the hex encoded HMAC-SHA256 of the payload, receivers of
+// webhooks can use it to verify signatures.
+func Sign(secret string, payload []byte) string {
+	mac := hmac.New(sha256.New, []byte(secret))
+	mac.Write(payload)
+	return hex.EncodeToString(mac.Sum(nil))
+}
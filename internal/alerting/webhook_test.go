@@ -0,0 +1,143 @@
+package alerting
+
+import (
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"testing"
+	"time"
+)
+
+const testBackoff = 20 * time.Millisecond
+
+// webhookReceiver answers webhook requests with the given statuses in turn,
+// repeating the last one, and records the requests.
+type webhookReceiver struct {
+	statuses []int
+	lock     sync.Mutex
+	bodies   [][]byte
+	headers  []http.Header
+	times    []time.Time
+}
+
+func (r *webhookReceiver) ServeHTTP(w http.ResponseWriter, req *http.Request) {
+	body, _ := io.ReadAll(req.Body)
+
+	r.lock.Lock()
+	attempt := len(r.bodies)
+	r.bodies = append(r.bodies, body)
+	r.headers = append(r.headers, req.Header.Clone())
+	r.times = append(r.times, time.Now())
+	r.lock.Unlock()
+
+	if attempt >= len(r.statuses) {
+		attempt = len(r.statuses) - 1
+	}
+	w.WriteHeader(r.statuses[attempt])
+}
+
+func newTestWebhook(t *testing.T, secret string, maxRetries int, statuses ...int) (*WebhookSink, *webhookReceiver) {
+	t.Helper()
+	receiver := &webhookReceiver{statuses: statuses}
+	server := httptest.NewServer(receiver)
+	t.Cleanup(server.Close)
+
+	sink := NewWebhookSink(server.URL, secret, maxRetries)
+	sink.backoff = testBackoff
+	t.Cleanup(func() { sink.Close() })
+	return sink, receiver
+}
+
+func TestWebhookSinkSignature(t *testing.T) {
+	sink, receiver := newTestWebhook(t, "s3cret", 0, http.StatusNoContent)
+	alert := Alert{ProxyName: "p1", Severity: "high", Rules: []string{"union-select"}}
+
+	if err := sink.Send(alert); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if len(receiver.bodies) != 1 {
+		t.Fatalf("got %d requests, want 1", len(receiver.bodies))
+	}
+
+	body, header := receiver.bodies[0], receiver.headers[0]
+	mac := hmac.New(sha256.New, []byte("s3cret"))
+	mac.Write(body)
+	if got, want := header.Get(SignatureHeader), "sha256="+hex.EncodeToString(mac.Sum(nil)); got != want {
+		t.Errorf("signature %q, want %q", got, want)
+	}
+	if got := header.Get("Content-Type"); got != "application/json" {
+		t.Errorf("content type %q", got)
+	}
+
+	var received Alert
+	if err := json.Unmarshal(body, &received); err != nil {
+		t.Fatalf("invalid payload %q: %v", body, err)
+	}
+	if received.ProxyName != "p1" || received.Rules[0] != "union-select" {
+		t.Errorf("unexpected payload %+v", received)
+	}
+}
+
+func TestWebhookSinkWithoutSecret(t *testing.T) {
+	sink, receiver := newTestWebhook(t, "", 0, http.StatusOK)
+	if err := sink.Send(Alert{}); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if signature := receiver.headers[0].Get(SignatureHeader); signature != "" {
+		t.Errorf("unexpected signature %q", signature)
+	}
+}
+
+func TestWebhookSinkRetries(t *testing.T) {
+	tests := []struct {
+		name         string
+		maxRetries   int
+		statuses     []int
+		wantErr      bool
+		wantAttempts int
+	}{
+		{name: "retried after server errors", maxRetries: 3, statuses: []int{500, 503, 200}, wantAttempts: 3},
+		{name: "retried when throttled", maxRetries: 3, statuses: []int{429, 200}, wantAttempts: 2},
+		{name: "retries run out", maxRetries: 2, statuses: []int{502}, wantErr: true, wantAttempts: 3},
+		{name: "rejection isn't retried", maxRetries: 3, statuses: []int{400}, wantErr: true, wantAttempts: 1},
+		{name: "missing receiver isn't retried", maxRetries: 3, statuses: []int{404}, wantErr: true, wantAttempts: 1},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			sink, receiver := newTestWebhook(t, "s3cret", tt.maxRetries, tt.statuses...)
+
+			err := sink.Send(Alert{ProxyName: "p1"})
+			if tt.wantErr != (err != nil) {
+				t.Fatalf("error %v, want error %t", err, tt.wantErr)
+			}
+			if len(receiver.bodies) != tt.wantAttempts {
+				t.Fatalf("got %d attempts, want %d", len(receiver.bodies), tt.wantAttempts)
+			}
+
+			// the backoff doubles after every attempt
+			for i := 1; i < len(receiver.times); i++ {
+				if gap, want := receiver.times[i].Sub(receiver.times[i-1]), testBackoff<<(i-1); gap < want {
+					t.Errorf("retry %d after %v, want at least %v", i, gap, want)
+				}
+			}
+		})
+	}
+}
+
+func TestWebhookSinkUnreachable(t *testing.T) {
+	server := httptest.NewServer(http.NotFoundHandler())
+	url := server.URL
+	server.Close()
+
+	sink := NewWebhookSink(url, "", 1)
+	sink.backoff = testBackoff
+	if err := sink.Send(Alert{}); err == nil {
+		t.Error("expected an error")
+	}
+}
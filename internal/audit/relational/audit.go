@@ -2,23 +2,25 @@ package relational
 
 import (
 	"database/sql"
-	"log"
+	"proxy-engineering-thesis/internal/logger"
 	"proxy-engineering-thesis/model/relational"
 )
 
+var log = logger.New("audit")
+
 func PerformAudit(ds DataSourceConnectionData, config relational.AuditConfiguration) (relational.AuditData, error) {
 	var auditResult = relational.AuditData{}
 
 	conn, err := GetDBConnection(ds)
 	if err != nil {
-		log.Printf("failed to create connection for audit: %v\n", err)
+		log.Error("failed to create connection for audit", "error", err)
 		return auditResult, err
 	}
 
 	if config.CheckAuditExtension {
 		isEnabled, err := checkPgauditInstalled(conn)
 		if err != nil {
-			log.Printf("failed to check if pgaudit extension is installed: %v\n", err)
+			log.Error("failed to check if pgaudit extension is installed", "error", err)
 		} else {
 			auditResult.IsAuditExtensionEnabled = isEnabled
 		}
@@ -27,7 +29,7 @@ func PerformAudit(ds DataSourceConnectionData, config relational.AuditConfigurat
 	if config.CheckSuperusers {
 		superusers, err := getSuperuserUsernames(conn)
 		if err != nil {
-			log.Printf("failed to retrieve superusers: %v\n", err)
+			log.Error("failed to retrieve superusers", "error", err)
 		}
 		auditResult.Superusers = superusers
 	}
@@ -35,7 +37,7 @@ func PerformAudit(ds DataSourceConnectionData, config relational.AuditConfigurat
 	if config.CheckAuditLogs {
 		isLoggingEnabled, err := isPgauditLoggingEnabled(conn)
 		if err != nil {
-			log.Printf("failed to check if pgaudit logging is enabled: %v\n", err)
+			log.Error("failed to check if pgaudit logging is enabled", "error", err)
 		}
 		auditResult.IsAuditLoggingEnabled = isLoggingEnabled
 	}
@@ -43,7 +45,7 @@ func PerformAudit(ds DataSourceConnectionData, config relational.AuditConfigurat
 	if config.CheckAuthenticationMethod {
 		authMethod, err := getAuthenticationMethod(conn)
 		if err != nil {
-			log.Printf("failed to retrieve authentication method: %v\n", err)
+			log.Error("failed to retrieve authentication method", "error", err)
 		}
 
 		auditResult.AuthenticationMethod = authMethod
@@ -52,7 +54,7 @@ func PerformAudit(ds DataSourceConnectionData, config relational.AuditConfigurat
 	if config.CheckRemoteAccess {
 		remoteAccessAddresses, err := getDatabaseListeningAddresses(conn)
 		if err != nil {
-			log.Printf("failed to retrieve remote access addresses: %v\n", err)
+			log.Error("failed to retrieve remote access addresses", "error", err)
 		}
 		auditResult.DatabaseHosts = remoteAccessAddresses
 	}
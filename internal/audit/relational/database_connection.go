@@ -13,11 +13,21 @@ type DataSourceConnectionData struct {
 	Port     string
 	Username string
 	Password string
+	SSLMode  string
+	RootCert string
 }
 
 func GetDBConnection(ds DataSourceConnectionData) (*sql.DB, error) {
-	connStr := fmt.Sprintf("host=%s port=%s user=%s password=%s sslmode=disable",
-		ds.Host, ds.Port, ds.Username, ds.Password)
+	sslMode := ds.SSLMode
+	if sslMode == "" {
+		sslMode = "disable"
+	}
+
+	connStr := fmt.Sprintf("host=%s port=%s user=%s password=%s sslmode=%s",
+		ds.Host, ds.Port, ds.Username, ds.Password, sslMode)
+	if ds.RootCert != "" {
+		connStr = fmt.Sprintf("%s sslrootcert=%s", connStr, ds.RootCert)
+	}
 
 	db, err := sql.Open("postgres", connStr)
 	if err != nil {
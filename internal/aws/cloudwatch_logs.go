@@ -2,94 +2,342 @@ package aws
 
 import (
 	"context"
+	"errors"
 	"fmt"
-	"log"
+	"net"
+	"proxy-engineering-thesis/internal/logger"
+	"sort"
+	"sync"
 	"time"
+	"unicode/utf8"
 
 	"github.com/aws/aws-sdk-go-v2/aws"
+	awshttp "github.com/aws/aws-sdk-go-v2/aws/transport/http"
 	"github.com/aws/aws-sdk-go-v2/config"
 	"github.com/aws/aws-sdk-go-v2/service/cloudwatchlogs"
 	"github.com/aws/aws-sdk-go-v2/service/cloudwatchlogs/types"
+	"github.com/aws/smithy-go"
 )
 
 const (
-	region = "eu-central-1"
+	DefaultRegion        = "eu-central-1"
+	DefaultFlushInterval = 5 * time.Second
+
+	// PutLogEvents limits, every event counts 26 bytes on top of its message
+	maxBatchEvents = 10000
+	maxBatchBytes  = 1048576
+	eventOverhead  = 26
+	maxEventBytes  = 262144 - eventOverhead
+	maxBatchSpan   = 24 * time.Hour
+
+	queueSize   = 10000
+	maxRetries  = 5
+	maxBackoff  = 10 * time.Second
+	callTimeout = 30 * time.Second
+)
+
+var (
+	log = logger.New("cloudwatch")
+
+	// retryBackoff is the delay before the first retry of a batch
+	retryBackoff = 200 * time.Millisecond
+
+	ErrQueueFull = errors.New("CloudWatch log queue is full")
+	ErrClosed    = errors.New("CloudWatch log shipper is closed")
 )
 
-type CloudWatchConfiguration struct {
-	Client        *cloudwatchlogs.Client
+// ShipperConfig configures a LogShipper. Region and credentials are resolved
+// the way the AWS SDK does, e.g. from AWS_REGION and the shared config files,
+// Profile picks the profile of those files. Endpoint overrides the CloudWatch
+// Logs endpoint of the region, e.g. to use a local mock.
+type ShipperConfig struct {
 	LogGroupName  string
 	LogStreamName string
+	Region        string
+	Profile       string
+	Endpoint      string
+	FlushInterval time.Duration
+}
+
+// LogShipper delivers log events to a CloudWatch log stream in the
+// background. Events are batched until a batch is full or the flush interval
+// passes, and batches are sent one at a time, so events keep their order.
+// The log group and stream are created before the first batch.
+type LogShipper struct {
+	config    ShipperConfig
+	client    *cloudwatchlogs.Client
+	events    chan types.InputLogEvent
+	done      chan struct{}
+	storeOk   bool
+	lastErr   error
+	closed    bool
+	closeLock sync.RWMutex
 }
 
-func NewCloudWatchConfiguration(groupName, streamName string) *CloudWatchConfiguration {
-	// Initialize AWS SDK configuration
-	cfg, err := config.LoadDefaultConfig(context.TODO(), config.WithRegion(region))
+func NewLogShipper(shipperConfig ShipperConfig) (*LogShipper, error) {
+	if shipperConfig.FlushInterval <= 0 {
+		shipperConfig.FlushInterval = DefaultFlushInterval
+	}
+
+	var options []func(*config.LoadOptions) error
+	if shipperConfig.Region != "" {
+		options = append(options, config.WithRegion(shipperConfig.Region))
+	}
+	if shipperConfig.Profile != "" {
+		options = append(options, config.WithSharedConfigProfile(shipperConfig.Profile))
+	}
+
+	cfg, err := config.LoadDefaultConfig(context.Background(), options...)
 	if err != nil {
-		log.Printf("failed to load configuration, %v", err)
+		return nil, fmt.Errorf("failed to load AWS configuration: %v", err)
+	}
+	if cfg.Region == "" {
+		cfg.Region = DefaultRegion
 	}
 
-	log.Printf("CloudWatch logging configuration completed successfully")
+	client := cloudwatchlogs.NewFromConfig(cfg, func(o *cloudwatchlogs.Options) {
+		if shipperConfig.Endpoint != "" {
+			o.BaseEndpoint = aws.String(shipperConfig.Endpoint)
+		}
+		// failed batches are retried by the shipper, keeping later batches
+		// waiting so the order is kept
+		o.Retryer = aws.NopRetryer{}
+	})
 
-	return &CloudWatchConfiguration{
-		cloudwatchlogs.NewFromConfig(cfg),
-		groupName,
-		streamName}
+	s := &LogShipper{
+		config: shipperConfig,
+		client: client,
+		events: make(chan types.InputLogEvent, queueSize),
+		done:   make(chan struct{}),
+	}
+	go s.run()
+	return s, nil
 }
 
-func (c *CloudWatchConfiguration) InitLogStore() {
-	err := c.CreateLogGroup()
-	if err != nil {
-		fmt.Printf("error when creating log group: %v\n", err)
+// Send queues the message without waiting for its delivery.
+func (s *LogShipper) Send(message string, timestamp time.Time) error {
+	s.closeLock.RLock()
+	defer s.closeLock.RUnlock()
+
+	if s.closed {
+		return ErrClosed
 	}
 
-	err = c.CreateLogStream()
-	if err != nil {
-		fmt.Printf("error when creating log stream: %v\n", err)
+	event := types.InputLogEvent{
+		Message:   aws.String(truncate(message, maxEventBytes)),
+		Timestamp: aws.Int64(timestamp.UnixNano() / int64(time.Millisecond)),
+	}
+	select {
+	case s.events <- event:
+		return nil
+	default:
+		return ErrQueueFull
+	}
+}
+
+// Close sends events still queued and stops the shipper. It returns the error
+// of the last batch which failed to be sent, if any.
+func (s *LogShipper) Close() error {
+	s.closeLock.Lock()
+	if !s.closed {
+		s.closed = true
+		close(s.events)
+	}
+	s.closeLock.Unlock()
+
+	<-s.done
+	return s.lastErr
+}
+
+func (s *LogShipper) run() {
+	defer close(s.done)
+
+	ticker := time.NewTicker(s.config.FlushInterval)
+	defer ticker.Stop()
+
+	var b batch
+	for {
+		select {
+		case event, ok := <-s.events:
+			if !ok {
+				s.flush(b.events)
+				return
+			}
+
+			if !b.fits(event) {
+				s.flush(b.events)
+				b = batch{}
+			}
+			b.add(event)
+		case <-ticker.C:
+			s.flush(b.events)
+			b = batch{}
+		}
+	}
+}
+
+// batch collects events within the limits of a single PutLogEvents call.
+type batch struct {
+	events []types.InputLogEvent
+	bytes  int
+	oldest int64
+	newest int64
+}
+
+func (b *batch) fits(event types.InputLogEvent) bool {
+	if len(b.events) == 0 {
+		return true
+	}
+	if len(b.events) == maxBatchEvents || b.bytes+len(*event.Message)+eventOverhead > maxBatchBytes {
+		return false
+	}
+
+	oldest, newest := b.oldest, b.newest
+	if *event.Timestamp < oldest {
+		oldest = *event.Timestamp
+	}
+	if *event.Timestamp > newest {
+		newest = *event.Timestamp
+	}
+	return time.Duration(newest-oldest)*time.Millisecond < maxBatchSpan
+}
+
+func (b *batch) add(event types.InputLogEvent) {
+	if len(b.events) == 0 || *event.Timestamp < b.oldest {
+		b.oldest = *event.Timestamp
 	}
+	if len(b.events) == 0 || *event.Timestamp > b.newest {
+		b.newest = *event.Timestamp
+	}
+	b.events = append(b.events, event)
+	b.bytes += len(*event.Message) + eventOverhead
 }
 
-func (c *CloudWatchConfiguration) CreateLogGroup() error {
-	// Create or update the log group
-	_, err := c.Client.CreateLogGroup(context.TODO(), &cloudwatchlogs.CreateLogGroupInput{
-		LogGroupName: aws.String(c.LogGroupName),
+func (s *LogShipper) flush(batch []types.InputLogEvent) {
+	if len(batch) == 0 {
+		return
+	}
+	s.lastErr = s.send(batch)
+}
+
+// send puts the batch, retrying with exponential backoff while CloudWatch is
+// throttling or unavailable. Batches rejected for other reasons are dropped.
+func (s *LogShipper) send(batch []types.InputLogEvent) error {
+	// events of a batch have to be in chronological order
+	sort.SliceStable(batch, func(i, j int) bool {
+		return *batch[i].Timestamp < *batch[j].Timestamp
 	})
-	if err != nil {
-		return err
+
+	backoff := retryBackoff
+	for attempt := 0; ; attempt++ {
+		err := s.put(batch)
+		if err == nil {
+			return nil
+		}
+
+		if !isRetryable(err) || attempt >= maxRetries {
+			log.Error("dropping CloudWatch log events", "group", s.config.LogGroupName,
+				"stream", s.config.LogStreamName, "events", len(batch), "error", err)
+			return err
+		}
+
+		log.Warn("failed to send CloudWatch log events, retrying", "error", err, "backoff", backoff)
+		time.Sleep(backoff)
+		if backoff *= 2; backoff > maxBackoff {
+			backoff = maxBackoff
+		}
 	}
+}
 
-	return nil
+func (s *LogShipper) put(batch []types.InputLogEvent) error {
+	if !s.storeOk {
+		if err := s.createLogStore(); err != nil {
+			return err
+		}
+		s.storeOk = true
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), callTimeout)
+	defer cancel()
+
+	_, err := s.client.PutLogEvents(ctx, &cloudwatchlogs.PutLogEventsInput{
+		LogGroupName:  aws.String(s.config.LogGroupName),
+		LogStreamName: aws.String(s.config.LogStreamName),
+		LogEvents:     batch,
+	})
+
+	if hasErrorCode(err, "ResourceNotFoundException") {
+		// the group or stream was deleted, it's created again on retry
+		s.storeOk = false
+	}
+	return err
 }
 
-func (c *CloudWatchConfiguration) CreateLogStream() error {
-	// Create or update the log stream
-	_, err := c.Client.CreateLogStream(context.TODO(), &cloudwatchlogs.CreateLogStreamInput{
-		LogGroupName:  aws.String(c.LogGroupName),
-		LogStreamName: aws.String(c.LogStreamName),
+// createLogStore creates the log group and stream unless they exist already.
+func (s *LogShipper) createLogStore() error {
+	ctx, cancel := context.WithTimeout(context.Background(), callTimeout)
+	defer cancel()
+
+	_, err := s.client.CreateLogGroup(ctx, &cloudwatchlogs.CreateLogGroupInput{
+		LogGroupName: aws.String(s.config.LogGroupName),
 	})
-	if err != nil {
-		return err
+	if err != nil && !hasErrorCode(err, "ResourceAlreadyExistsException") {
+		return fmt.Errorf("failed to create log group: %w", err)
 	}
 
+	_, err = s.client.CreateLogStream(ctx, &cloudwatchlogs.CreateLogStreamInput{
+		LogGroupName:  aws.String(s.config.LogGroupName),
+		LogStreamName: aws.String(s.config.LogStreamName),
+	})
+	if err != nil && !hasErrorCode(err, "ResourceAlreadyExistsException") {
+		return fmt.Errorf("failed to create log stream: %w", err)
+	}
+
+	log.Info("CloudWatch log stream is ready", "group", s.config.LogGroupName, "stream", s.config.LogStreamName)
 	return nil
 }
 
-func (c *CloudWatchConfiguration) SendLog(message string) {
-	_, err := c.Client.PutLogEvents(context.TODO(), &cloudwatchlogs.PutLogEventsInput{
-		LogGroupName:  aws.String(c.LogGroupName),
-		LogStreamName: aws.String(c.LogStreamName),
-		LogEvents: []types.InputLogEvent{
-			{
-				Message:   aws.String(message),
-				Timestamp: aws.Int64(nowMillis()),
-			},
-		},
-	})
-	if err != nil {
-		log.Printf("failed to send log to CloudWatch: %v\n", err)
+// retryableCodes are codes of API errors worth retrying. Errors not modeled
+// for an operation, like throttling, aren't returned as typed exceptions, so
+// they're matched by code.
+var retryableCodes = []string{
+	"ThrottlingException",
+	"Throttling",
+	"ServiceUnavailableException",
+	"ResourceNotFoundException",
+}
+
+func hasErrorCode(err error, code string) bool {
+	var apiErr smithy.APIError
+	return errors.As(err, &apiErr) && apiErr.ErrorCode() == code
+}
+
+func isRetryable(err error) bool {
+	for _, code := range retryableCodes {
+		if hasErrorCode(err, code) {
+			return true
+		}
 	}
+
+	var responseErr *awshttp.ResponseError
+	if errors.As(err, &responseErr) {
+		status := responseErr.HTTPStatusCode()
+		return status >= 500 || status == 429
+	}
+
+	var netErr net.Error
+	return errors.As(err, &netErr)
 }
 
-func nowMillis() int64 {
-	return time.Now().UnixNano() / int64(time.Millisecond)
+// truncate cuts the message to at most max bytes, without splitting a UTF-8
+// encoded character.
+func truncate(message string, max int) string {
+	if len(message) <= max {
+		return message
+	}
+	message = message[:max]
+	for len(message) > 0 && !utf8.ValidString(message) {
+		message = message[:len(message)-1]
+	}
+	return message
 }
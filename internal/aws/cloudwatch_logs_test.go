@@ -0,0 +1,289 @@
+package aws
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"path/filepath"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+)
+
+type putLogEventsRequest struct {
+	LogGroupName  string `json:"logGroupName"`
+	LogStreamName string `json:"logStreamName"`
+	LogEvents     []struct {
+		Message   string `json:"message"`
+		Timestamp int64  `json:"timestamp"`
+	} `json:"logEvents"`
+}
+
+// fakeCloudWatch serves the CloudWatch Logs operations used by the shipper.
+// Calls of PutLogEvents fail with the given error codes in turn.
+type fakeCloudWatch struct {
+	lock    sync.Mutex
+	calls   []string
+	batches []putLogEventsRequest
+	times   []time.Time
+	errors  []string
+}
+
+func (f *fakeCloudWatch) ServeHTTP(w http.ResponseWriter, req *http.Request) {
+	operation := strings.TrimPrefix(req.Header.Get("X-Amz-Target"), "Logs_20140328.")
+
+	f.lock.Lock()
+	defer f.lock.Unlock()
+	f.calls = append(f.calls, operation)
+
+	w.Header().Set("Content-Type", "application/x-amz-json-1.1")
+	if operation == "PutLogEvents" {
+		f.times = append(f.times, time.Now())
+		if len(f.errors) > 0 {
+			code := f.errors[0]
+			f.errors = f.errors[1:]
+			status := http.StatusBadRequest
+			if code == "InternalFailure" {
+				status = http.StatusInternalServerError
+			}
+			w.WriteHeader(status)
+			w.Write([]byte(`{"__type":"` + code + `","message":"test"}`))
+			return
+		}
+
+		var batch putLogEventsRequest
+		if err := json.NewDecoder(req.Body).Decode(&batch); err != nil {
+			w.WriteHeader(http.StatusBadRequest)
+			return
+		}
+		f.batches = append(f.batches, batch)
+	}
+	w.Write([]byte(`{}`))
+}
+
+func (f *fakeCloudWatch) batchSizes() []int {
+	f.lock.Lock()
+	defer f.lock.Unlock()
+	sizes := make([]int, 0, len(f.batches))
+	for _, batch := range f.batches {
+		sizes = append(sizes, len(batch.LogEvents))
+	}
+	return sizes
+}
+
+func newTestShipper(t *testing.T, flushInterval time.Duration, errors ...string) (*LogShipper, *fakeCloudWatch) {
+	t.Helper()
+	// credentials and region come from the environment only
+	dir := t.TempDir()
+	t.Setenv("AWS_ACCESS_KEY_ID", "test")
+	t.Setenv("AWS_SECRET_ACCESS_KEY", "test")
+	t.Setenv("AWS_REGION", "eu-central-1")
+	t.Setenv("AWS_CONFIG_FILE", filepath.Join(dir, "config"))
+	t.Setenv("AWS_SHARED_CREDENTIALS_FILE", filepath.Join(dir, "credentials"))
+
+	backoff := retryBackoff
+	retryBackoff = time.Millisecond
+	t.Cleanup(func() { retryBackoff = backoff })
+
+	fake := &fakeCloudWatch{errors: errors}
+	server := httptest.NewServer(fake)
+	t.Cleanup(server.Close)
+
+	shipper, err := NewLogShipper(ShipperConfig{
+		LogGroupName:  "group",
+		LogStreamName: "stream",
+		Endpoint:      server.URL,
+		FlushInterval: flushInterval,
+	})
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	return shipper, fake
+}
+
+func send(t *testing.T, shipper *LogShipper, message string, timestamp time.Time) {
+	t.Helper()
+	for {
+		err := shipper.Send(message, timestamp)
+		if err == nil {
+			return
+		}
+		if err != ErrQueueFull {
+			t.Fatalf("unexpected error: %v", err)
+		}
+		time.Sleep(time.Millisecond)
+	}
+}
+
+func equalSizes(got, want []int) bool {
+	if len(got) != len(want) {
+		return false
+	}
+	for i := range got {
+		if got[i] != want[i] {
+			return false
+		}
+	}
+	return true
+}
+
+func TestLogShipperFlushInterval(t *testing.T) {
+	shipper, fake := newTestShipper(t, 50*time.Millisecond)
+	defer shipper.Close()
+
+	now := time.Now()
+	send(t, shipper, "second", now)
+	send(t, shipper, "first", now.Add(-time.Second))
+	send(t, shipper, "third", now.Add(time.Second))
+
+	// the batch is sent once the flush interval passes, without closing
+	deadline := time.Now().Add(5 * time.Second)
+	for len(fake.batchSizes()) == 0 {
+		if time.Now().After(deadline) {
+			t.Fatal("no batch sent within the flush interval")
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+
+	fake.lock.Lock()
+	defer fake.lock.Unlock()
+	if got := strings.Join(fake.calls, ","); got != "CreateLogGroup,CreateLogStream,PutLogEvents" {
+		t.Errorf("got calls %s", got)
+	}
+	batch := fake.batches[0]
+	if batch.LogGroupName != "group" || batch.LogStreamName != "stream" {
+		t.Errorf("sent to %s/%s", batch.LogGroupName, batch.LogStreamName)
+	}
+	// events are sorted by time
+	var messages []string
+	for _, event := range batch.LogEvents {
+		messages = append(messages, event.Message)
+	}
+	if got := strings.Join(messages, ","); got != "first,second,third" {
+		t.Errorf("got events %s", got)
+	}
+	if batch.LogEvents[0].Timestamp != now.Add(-time.Second).UnixNano()/int64(time.Millisecond) {
+		t.Errorf("got timestamp %d", batch.LogEvents[0].Timestamp)
+	}
+}
+
+func TestLogShipperBatchLimits(t *testing.T) {
+	now := time.Now()
+	large := strings.Repeat("x", maxEventBytes)
+
+	tests := []struct {
+		name     string
+		events   int
+		message  string
+		interval time.Duration
+		want     []int
+	}{
+		{name: "count limit", events: maxBatchEvents + 5, message: "event", want: []int{maxBatchEvents, 5}},
+		// four events of the max size take up the whole batch
+		{name: "size limit", events: 5, message: large, want: []int{4, 1}},
+		{name: "oversize events are truncated", events: 5, message: large + "truncated", want: []int{4, 1}},
+		{name: "time span limit", events: 3, message: "event", interval: 13 * time.Hour, want: []int{2, 1}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			shipper, fake := newTestShipper(t, time.Hour)
+			for i := 0; i < tt.events; i++ {
+				send(t, shipper, tt.message, now.Add(time.Duration(i)*tt.interval))
+			}
+			if err := shipper.Close(); err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+
+			if got := fake.batchSizes(); !equalSizes(got, tt.want) {
+				t.Fatalf("got batches of %v events, want %v", got, tt.want)
+			}
+			for _, batch := range fake.batches {
+				for _, event := range batch.LogEvents {
+					if len(event.Message) > maxEventBytes {
+						t.Fatalf("event of %d bytes", len(event.Message))
+					}
+				}
+			}
+		})
+	}
+}
+
+func TestLogShipperRetries(t *testing.T) {
+	tests := []struct {
+		name         string
+		errors       []string
+		wantErr      bool
+		wantAttempts int
+		wantCalls    string
+	}{
+		{name: "throttling", errors: []string{"ThrottlingException", "ThrottlingException"}, wantAttempts: 3},
+		{name: "server error", errors: []string{"InternalFailure"}, wantAttempts: 2},
+		{
+			name:         "deleted log stream is created again",
+			errors:       []string{"ResourceNotFoundException"},
+			wantAttempts: 2,
+			wantCalls:    "CreateLogGroup,CreateLogStream,PutLogEvents,CreateLogGroup,CreateLogStream,PutLogEvents",
+		},
+		{name: "retries run out", errors: []string{"ThrottlingException", "ThrottlingException", "ThrottlingException",
+			"ThrottlingException", "ThrottlingException", "ThrottlingException"}, wantErr: true, wantAttempts: maxRetries + 1},
+		{name: "rejected batch is dropped", errors: []string{"InvalidParameterException"}, wantErr: true, wantAttempts: 1},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			shipper, fake := newTestShipper(t, time.Hour, tt.errors...)
+			send(t, shipper, "event", time.Now())
+			err := shipper.Close()
+			if tt.wantErr != (err != nil) {
+				t.Fatalf("error %v, want error %t", err, tt.wantErr)
+			}
+
+			if len(fake.times) != tt.wantAttempts {
+				t.Fatalf("got %d attempts, want %d", len(fake.times), tt.wantAttempts)
+			}
+			// the backoff doubles after every attempt
+			for i := 1; i < len(fake.times); i++ {
+				if gap, want := fake.times[i].Sub(fake.times[i-1]), retryBackoff<<(i-1); gap < want {
+					t.Errorf("retry %d after %v, want at least %v", i, gap, want)
+				}
+			}
+			if tt.wantCalls != "" && strings.Join(fake.calls, ",") != tt.wantCalls {
+				t.Errorf("got calls %s", strings.Join(fake.calls, ","))
+			}
+			if want := 1; !tt.wantErr && len(fake.batches) != want {
+				t.Errorf("event delivered %d times", len(fake.batches))
+			}
+		})
+	}
+}
+
+func TestLogShipperClosed(t *testing.T) {
+	shipper, _ := newTestShipper(t, time.Hour)
+	if err := shipper.Close(); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if err := shipper.Send("event", time.Now()); err != ErrClosed {
+		t.Errorf("got %v, want %v", err, ErrClosed)
+	}
+}
+
+func TestTruncate(t *testing.T) {
+	tests := []struct {
+		message string
+		max     int
+		want    string
+	}{
+		{"short", 10, "short"},
+		{"exactly", 7, "exactly"},
+		{"longer", 4, "long"},
+		// the two byte ó isn't split
+		{"zaó", 3, "za"},
+	}
+	for _, tt := range tests {
+		if got := truncate(tt.message, tt.max); got != tt.want {
+			t.Errorf("truncate(%q, %d) = %q, want %q", tt.message, tt.max, got, tt.want)
+		}
+	}
+}
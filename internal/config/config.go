@@ -0,0 +1,165 @@
+package config
+
+import (
+	"fmt"
+	"net/url"
+	"proxy-engineering-thesis/internal/logger"
+	"time"
+)
+
+// Config holds operational settings of the server, the proxies, the blacklist
+// and alerts. It's loaded by Load, from defaults overridden by a YAML file,
+// environment variables and command line flags, in that order.
+type Config struct {
+	Server    ServerConfig    `yaml:"server"`
+	Database  DatabaseConfig  `yaml:"database"`
+	Proxy     ProxyConfig     `yaml:"proxy"`
+	Blacklist BlacklistConfig `yaml:"blacklist"`
+	Alerts    AlertsConfig    `yaml:"alerts"`
+	Log       LogConfig       `yaml:"log"`
+}
+
+type ServerConfig struct {
+	Address        string        `yaml:"address"`
+	ReadTimeout    time.Duration `yaml:"readTimeout"`
+	WriteTimeout   time.Duration `yaml:"writeTimeout"`
+	MaxHeaderBytes int           `yaml:"maxHeaderBytes"`
+	// WebAppUrl is where requests outside of the API are proxied to, empty
+	// turns the proxying off
+	WebAppUrl string `yaml:"webAppUrl"`
+	// ShutdownTimeout is how long sessions are drained on shutdown
+	ShutdownTimeout time.Duration `yaml:"shutdownTimeout"`
+}
+
+type DatabaseConfig struct {
+	// Path of the SQLite database file
+	Path string `yaml:"path"`
+}
+
+type ProxyConfig struct {
+	// BufferSize of reads from clients and targets of sessions
+	BufferSize int `yaml:"bufferSize"`
+	// MaxMessageSize is the longest message accepted from clients
+	MaxMessageSize int `yaml:"maxMessageSize"`
+}
+
+type BlacklistConfig struct {
+	CacheSize int `yaml:"cacheSize"`
+	// BanDuration is how long a client is limited after its first offence,
+	// it doubles with every next one up to MaxBanDuration
+	BanDuration    time.Duration `yaml:"banDuration"`
+	MaxBanDuration time.Duration `yaml:"maxBanDuration"`
+}
+
+type AlertsConfig struct {
+	// FileSinkDir is the directory file sinks write alerts to, their paths
+	// can't lead out of it
+	FileSinkDir string `yaml:"fileSinkDir"`
+}
+
+type LogConfig struct {
+	Level      string `yaml:"level"`
+	Format     string `yaml:"format"`
+	Output     string `yaml:"output"`
+	MaxSizeMB  int    `yaml:"maxSizeMB"`
+	MaxBackups int    `yaml:"maxBackups"`
+}
+
+const (
+	minBufferSize = 1024
+	// maxMessageSize is the longest message allowed by the protocol
+	maxMessageSize = 1 << 30
+)
+
+func Default() *Config {
+	logConfig := logger.DefaultConfig()
+	return &Config{
+		Server: ServerConfig{
+			Address:         ":8888",
+			ReadTimeout:     10 * time.Second,
+			WriteTimeout:    10 * time.Second,
+			MaxHeaderBytes:  1 << 20,
+			WebAppUrl:       "http://frontend-domain:3000",
+			ShutdownTimeout: 30 * time.Second,
+		},
+		Database: DatabaseConfig{
+			Path: "gorm.db",
+		},
+		Proxy: ProxyConfig{
+			BufferSize:     16400,
+			MaxMessageSize: 64 << 20,
+		},
+		Blacklist: BlacklistConfig{
+			CacheSize:      10000,
+			BanDuration:    4 * time.Second,
+			MaxBanDuration: 128 * time.Second,
+		},
+		Alerts: AlertsConfig{
+			FileSinkDir: "alerts",
+		},
+		Log: LogConfig{
+			Level:      logConfig.Level,
+			Format:     logConfig.Format,
+			Output:     logConfig.Output,
+			MaxSizeMB:  logConfig.MaxSizeMB,
+			MaxBackups: logConfig.MaxBackups,
+		},
+	}
+}
+
+// Logger returns the config of the logging.
+func (c *Config) Logger() logger.Config {
+	return logger.Config{
+		Level:      c.Log.Level,
+		Format:     c.Log.Format,
+		Output:     c.Log.Output,
+		MaxSizeMB:  c.Log.MaxSizeMB,
+		MaxBackups: c.Log.MaxBackups,
+	}
+}
+
+// Validate checks the settings, the logging ones are checked when the logging
+// is configured.
+func (c *Config) Validate() error {
+	if c.Server.Address == "" {
+		return fmt.Errorf("server address can't be empty")
+	}
+	if c.Server.ReadTimeout <= 0 || c.Server.WriteTimeout <= 0 {
+		return fmt.Errorf("server timeouts have to be positive")
+	}
+	if c.Server.MaxHeaderBytes <= 0 {
+		return fmt.Errorf("max header bytes have to be positive")
+	}
+	if c.Server.ShutdownTimeout < 0 {
+		return fmt.Errorf("shutdown timeout can't be negative")
+	}
+	if c.Server.WebAppUrl != "" {
+		webAppUrl, err := url.Parse(c.Server.WebAppUrl)
+		if err != nil || (webAppUrl.Scheme != "http" && webAppUrl.Scheme != "https") || webAppUrl.Host == "" {
+			return fmt.Errorf("web app URL must be an http or https URL: %s", c.Server.WebAppUrl)
+		}
+	}
+
+	if c.Database.Path == "" {
+		return fmt.Errorf("database path can't be empty")
+	}
+
+	if c.Proxy.BufferSize < minBufferSize {
+		return fmt.Errorf("proxy buffer size can't be less than %d", minBufferSize)
+	}
+	if c.Proxy.MaxMessageSize < minBufferSize || c.Proxy.MaxMessageSize > maxMessageSize {
+		return fmt.Errorf("proxy max message size has to be between %d and %d", minBufferSize, maxMessageSize)
+	}
+
+	if c.Blacklist.CacheSize <= 0 {
+		return fmt.Errorf("blacklist cache size has to be positive")
+	}
+	if c.Blacklist.BanDuration <= 0 || c.Blacklist.MaxBanDuration < c.Blacklist.BanDuration {
+		return fmt.Errorf("ban duration has to be positive and not longer than max ban duration")
+	}
+
+	if c.Alerts.FileSinkDir == "" {
+		return fmt.Errorf("alert file sink directory can't be empty")
+	}
+	return nil
+}
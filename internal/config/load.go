@@ -0,0 +1,133 @@
+package config
+
+import (
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"os"
+
+	"gopkg.in/yaml.v3"
+)
+
+// FileEnv names the environment variable with path of the config file, the
+// -config flag takes precedence over it.
+const FileEnv = "GOHARDER_CONFIG"
+
+// ErrUsage is returned for args which can't be parsed, the flag set prints
+// the problem and the usage itself.
+var ErrUsage = errors.New("invalid command line flags")
+
+// setting is a single option, which can be set by an environment variable and
+// a command line flag.
+type setting struct {
+	flag  string
+	env   string
+	usage string
+	value flag.Value
+}
+
+func (c *Config) settings() []setting {
+	return []setting{
+		{"address", "SERVER_ADDRESS", "address of the REST API", stringValue{&c.Server.Address}},
+		{"read-timeout", "SERVER_READ_TIMEOUT", "timeout of reading API requests", durationValue{&c.Server.ReadTimeout}},
+		{"write-timeout", "SERVER_WRITE_TIMEOUT", "timeout of writing API responses", durationValue{&c.Server.WriteTimeout}},
+		{"max-header-bytes", "SERVER_MAX_HEADER_BYTES", "max size of API request headers", intValue{&c.Server.MaxHeaderBytes}},
+		{"web-app-url", "WEB_APP_URL", "URL of the web app, empty turns proxying to it off", stringValue{&c.Server.WebAppUrl}},
+		{"shutdown-timeout", "SHUTDOWN_TIMEOUT", "how long sessions are drained on shutdown", durationValue{&c.Server.ShutdownTimeout}},
+		{"database", "DATABASE_PATH", "path of the SQLite database", stringValue{&c.Database.Path}},
+		{"buffer-size", "PROXY_BUFFER_SIZE", "size of read buffers of proxy sessions", intValue{&c.Proxy.BufferSize}},
+		{"max-message-size", "PROXY_MAX_MESSAGE_SIZE", "max size of messages accepted from clients", intValue{&c.Proxy.MaxMessageSize}},
+		{"blacklist-cache-size", "BLACKLIST_CACHE_SIZE", "max number of tracked offending clients", intValue{&c.Blacklist.CacheSize}},
+		{"ban-duration", "BLACKLIST_BAN_DURATION", "how long a client is limited after its first offence", durationValue{&c.Blacklist.BanDuration}},
+		{"max-ban-duration", "BLACKLIST_MAX_BAN_DURATION", "max duration of limiting a repeated offender", durationValue{&c.Blacklist.MaxBanDuration}},
+		{"alert-file-dir", "ALERT_FILE_DIR", "directory file alert sinks write to", stringValue{&c.Alerts.FileSinkDir}},
+		{"log-level", "LOG_LEVEL", "debug, info, warn or error", stringValue{&c.Log.Level}},
+		{"log-format", "LOG_FORMAT", "logfmt or json", stringValue{&c.Log.Format}},
+		{"log-output", "LOG_OUTPUT", "stdout, stderr or path of a log file", stringValue{&c.Log.Output}},
+		{"log-max-size-mb", "LOG_MAX_SIZE_MB", "size of a log file before it's rotated", intValue{&c.Log.MaxSizeMB}},
+		{"log-max-backups", "LOG_MAX_BACKUPS", "number of rotated log files kept", intValue{&c.Log.MaxBackups}},
+	}
+}
+
+// Load reads the config from the file given by the -config flag or the
+// GOHARDER_CONFIG environment variable, then applies environment variables
+// and flags of args. Defaults are used for settings not set anywhere.
+func Load(name string, args []string) (*Config, error) {
+	flags := NewFlagSet(name)
+	return LoadFlags(flags, args)
+}
+
+// NewFlagSet returns flags of all settings, for commands which add flags of
+// their own.
+func NewFlagSet(name string) *flag.FlagSet {
+	flags := flag.NewFlagSet(name, flag.ContinueOnError)
+	flags.String("config", "", "path of a YAML config file, overrides "+FileEnv)
+	for _, s := range Default().settings() {
+		flags.Var(s.value, s.flag, fmt.Sprintf("%s (%s)", s.usage, s.env))
+	}
+	return flags
+}
+
+// LoadFlags parses args with flags made by NewFlagSet and loads the config.
+func LoadFlags(flags *flag.FlagSet, args []string) (*Config, error) {
+	if err := flags.Parse(args); err != nil {
+		if err == flag.ErrHelp {
+			return nil, err
+		}
+		return nil, ErrUsage
+	}
+
+	setFlags := make(map[string]string)
+	flags.Visit(func(f *flag.Flag) {
+		setFlags[f.Name] = f.Value.String()
+	})
+
+	config := Default()
+	path := os.Getenv(FileEnv)
+	if value, present := setFlags["config"]; present {
+		path = value
+	}
+	if path != "" {
+		if err := config.readFile(path); err != nil {
+			return nil, err
+		}
+	}
+
+	// a variable set to an empty value overrides the file too, e.g. to clear
+	// the web app url
+	settings := config.settings()
+	for _, s := range settings {
+		if value, present := os.LookupEnv(s.env); present {
+			if err := s.value.Set(value); err != nil {
+				return nil, fmt.Errorf("invalid %s: %v", s.env, err)
+			}
+		}
+	}
+	for _, s := range settings {
+		if value, present := setFlags[s.flag]; present {
+			s.value.Set(value)
+		}
+	}
+
+	if err := config.Validate(); err != nil {
+		return nil, err
+	}
+	return config, nil
+}
+
+func (c *Config) readFile(path string) error {
+	file, err := os.Open(path)
+	if err != nil {
+		return fmt.Errorf("failed to open config file: %v", err)
+	}
+	defer file.Close()
+
+	// unknown keys are rejected, so that typos don't go unnoticed
+	decoder := yaml.NewDecoder(file)
+	decoder.KnownFields(true)
+	if err := decoder.Decode(c); err != nil && !errors.Is(err, io.EOF) {
+		return fmt.Errorf("invalid config file %s: %v", path, err)
+	}
+	return nil
+}
@@ -0,0 +1,83 @@
+package config
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+func TestLoadPrecedence(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "config.yaml")
+	file := "server:\n  webAppUrl: http://localhost:3000\n  shutdownTimeout: 10s\nlog:\n  level: debug\n"
+	if err := os.WriteFile(path, []byte(file), 0600); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	tests := []struct {
+		name          string
+		env           map[string]string
+		args          []string
+		wantWebAppUrl string
+		wantShutdown  time.Duration
+		wantLogLevel  string
+		wantErr       bool
+	}{
+		{
+			name:          "file",
+			wantWebAppUrl: "http://localhost:3000", wantShutdown: 10 * time.Second, wantLogLevel: "debug",
+		},
+		{
+			name:          "env overrides file",
+			env:           map[string]string{"WEB_APP_URL": "http://web:3000", "SHUTDOWN_TIMEOUT": "20s"},
+			wantWebAppUrl: "http://web:3000", wantShutdown: 20 * time.Second, wantLogLevel: "debug",
+		},
+		{
+			name:          "empty env clears file",
+			env:           map[string]string{"WEB_APP_URL": "", "LOG_LEVEL": ""},
+			wantWebAppUrl: "", wantShutdown: 10 * time.Second, wantLogLevel: "",
+		},
+		{
+			name:          "flags override env",
+			env:           map[string]string{"WEB_APP_URL": "http://web:3000", "SHUTDOWN_TIMEOUT": "20s"},
+			args:          []string{"-web-app-url=", "-shutdown-timeout=30s"},
+			wantWebAppUrl: "", wantShutdown: 30 * time.Second, wantLogLevel: "debug",
+		},
+		{
+			name:    "empty env of a duration",
+			env:     map[string]string{"SHUTDOWN_TIMEOUT": ""},
+			wantErr: true,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			t.Setenv(FileEnv, path)
+			// variables of the settings are unset unless the test sets them
+			for _, s := range Default().settings() {
+				t.Setenv(s.env, "")
+				os.Unsetenv(s.env)
+			}
+			for name, value := range tt.env {
+				t.Setenv(name, value)
+			}
+
+			config, err := Load("test", tt.args)
+			if tt.wantErr != (err != nil) {
+				t.Fatalf("error %v, want error %t", err, tt.wantErr)
+			}
+			if err != nil {
+				return
+			}
+			if config.Server.WebAppUrl != tt.wantWebAppUrl {
+				t.Errorf("web app url %q, want %q", config.Server.WebAppUrl, tt.wantWebAppUrl)
+			}
+			if config.Server.ShutdownTimeout != tt.wantShutdown {
+				t.Errorf("shutdown timeout %v, want %v", config.Server.ShutdownTimeout, tt.wantShutdown)
+			}
+			if config.Log.Level != tt.wantLogLevel {
+				t.Errorf("log level %q, want %q", config.Log.Level, tt.wantLogLevel)
+			}
+		})
+	}
+}
@@ -0,0 +1,65 @@
+package config
+
+import (
+	"strconv"
+	"time"
+)
+
+// flag.Value implementations writing straight to fields of a Config. Targets
+// are nil in values made by the flag package for printing defaults.
+
+type stringValue struct {
+	target *string
+}
+
+func (v stringValue) Set(value string) error {
+	*v.target = value
+	return nil
+}
+
+func (v stringValue) String() string {
+	if v.target == nil {
+		return ""
+	}
+	return *v.target
+}
+
+type intValue struct {
+	target *int
+}
+
+func (v intValue) Set(value string) error {
+	parsed, err := strconv.Atoi(value)
+	if err != nil {
+		return err
+	}
+	*v.target = parsed
+	return nil
+}
+
+func (v intValue) String() string {
+	if v.target == nil {
+		return "0"
+	}
+	return strconv.Itoa(*v.target)
+}
+
+type durationValue struct {
+	target *time.Duration
+}
+
+func (v durationValue) Set(value string) error {
+	parsed, err := time.ParseDuration(value)
+	if err != nil {
+		return err
+	}
+	*v.target = parsed
+	return nil
+}
+
+func (v durationValue) String() string {
+	if v.target == nil {
+		return "0s"
+	}
+	return v.target.String()
+}
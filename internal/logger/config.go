@@ -0,0 +1,49 @@
+package logger
+
+import (
+	"fmt"
+	"io"
+	"os"
+)
+
+const (
+	StdoutOutput = "stdout"
+	StderrOutput = "stderr"
+
+	DefaultMaxSizeMB  = 100
+	DefaultMaxBackups = 5
+)
+
+// Config of the logging. Output is stdout, stderr or path of a file, which is
+// rotated once it grows over MaxSizeMB, keeping MaxBackups previous files.
+type Config struct {
+	Level      string
+	Format     string
+	Output     string
+	MaxSizeMB  int
+	MaxBackups int
+}
+
+func DefaultConfig() Config {
+	return Config{
+		Level:      "info",
+		Format:     LogfmtFormat,
+		Output:     StdoutOutput,
+		MaxSizeMB:  DefaultMaxSizeMB,
+		MaxBackups: DefaultMaxBackups,
+	}
+}
+
+func newWriter(config Config) (io.Writer, error) {
+	switch config.Output {
+	case "", StdoutOutput:
+		return os.Stdout, nil
+	case StderrOutput:
+		return os.Stderr, nil
+	}
+
+	if config.MaxSizeMB < 0 || config.MaxBackups < 0 {
+		return nil, fmt.Errorf("log rotation settings can't be negative")
+	}
+	return newRotatingFile(config.Output, int64(config.MaxSizeMB)<<20, config.MaxBackups)
+}
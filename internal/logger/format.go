@@ -0,0 +1,112 @@
+package logger
+
+import (
+	"encoding/json"
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+)
+
+const (
+	JSONFormat   = "json"
+	LogfmtFormat = "logfmt"
+)
+
+type record struct {
+	time   time.Time
+	level  Level
+	msg    string
+	fields []field
+}
+
+type formatter interface {
+	format(rec record) []byte
+}
+
+func newFormatter(format string) (formatter, error) {
+	switch strings.ToLower(format) {
+	case "", LogfmtFormat:
+		return logfmtFormatter{}, nil
+	case JSONFormat:
+		return jsonFormatter{}, nil
+	}
+	return nil, fmt.Errorf("unknown log format: %s", format)
+}
+
+func formatValue(value interface{}) string {
+	switch v := value.(type) {
+	case string:
+		return v
+	case error:
+		return v.Error()
+	case time.Duration:
+		return v.String()
+	case fmt.Stringer:
+		return v.String()
+	}
+	return fmt.Sprint(value)
+}
+
+// logfmtFormatter writes records as key=value pairs, quoting values when
+// needed.
+type logfmtFormatter struct{}
+
+func (logfmtFormatter) format(rec record) []byte {
+	var b strings.Builder
+	b.WriteString("time=")
+	b.WriteString(rec.time.Format(time.RFC3339Nano))
+	b.WriteString(" level=")
+	b.WriteString(rec.level.String())
+	b.WriteString(" msg=")
+	b.WriteString(logfmtValue(rec.msg))
+	for _, f := range rec.fields {
+		b.WriteByte(' ')
+		b.WriteString(f.key)
+		b.WriteByte('=')
+		b.WriteString(logfmtValue(formatValue(f.value)))
+	}
+	b.WriteByte('\n')
+	return []byte(b.String())
+}
+
+func logfmtValue(value string) string {
+	if value == "" || strings.ContainsAny(value, " =\"\\\t\r\n") {
+		return strconv.Quote(value)
+	}
+	return value
+}
+
+// jsonFormatter writes records as JSON objects, one per line.
+type jsonFormatter struct{}
+
+func (jsonFormatter) format(rec record) []byte {
+	var b strings.Builder
+	b.WriteString(`{"time":`)
+	b.Write(jsonValue(rec.time.Format(time.RFC3339Nano)))
+	b.WriteString(`,"level":`)
+	b.Write(jsonValue(rec.level.String()))
+	b.WriteString(`,"msg":`)
+	b.Write(jsonValue(rec.msg))
+	for _, f := range rec.fields {
+		b.WriteByte(',')
+		b.Write(jsonValue(f.key))
+		b.WriteByte(':')
+		switch v := f.value.(type) {
+		case bool, int, int32, int64, uint, uint32, uint64, float32, float64:
+			b.Write(jsonValue(v))
+		default:
+			b.Write(jsonValue(formatValue(v)))
+		}
+	}
+	b.WriteString("}\n")
+	return []byte(b.String())
+}
+
+func jsonValue(value interface{}) []byte {
+	encoded, err := json.Marshal(value)
+	if err != nil {
+		return []byte(strconv.Quote(fmt.Sprint(value)))
+	}
+	return encoded
+}
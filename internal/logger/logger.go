@@ -1,15 +1,193 @@
 package logger
 
 import (
+	"fmt"
+	"io"
 	"log"
 	"os"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+type Level int32
+
+const (
+	DebugLevel Level = iota
+	InfoLevel
+	WarnLevel
+	ErrorLevel
+)
+
+var levelNames = map[Level]string{
+	DebugLevel: "debug",
+	InfoLevel:  "info",
+	WarnLevel:  "warn",
+	ErrorLevel: "error",
+}
+
+func (l Level) String() string {
+	if name, present := levelNames[l]; present {
+		return name
+	}
+	return fmt.Sprintf("level(%d)", int32(l))
+}
+
+func ParseLevel(level string) (Level, error) {
+	switch strings.ToLower(level) {
+	case "debug":
+		return DebugLevel, nil
+	case "", "info":
+		return InfoLevel, nil
+	case "warn", "warning":
+		return WarnLevel, nil
+	case "error":
+		return ErrorLevel, nil
+	}
+	return InfoLevel, fmt.Errorf("unknown log level: %s", level)
+}
+
+// output is where all loggers write to, it's swapped as a whole when the
+// logging is configured.
+type output struct {
+	level     Level
+	formatter formatter
+	writer    io.Writer
+}
+
+var (
+	current atomic.Value
+	// outputLock serializes writes of records and replacing the output, so
+	// that nothing is written to a log file after it's closed
+	outputLock sync.Mutex
 )
 
 func init() {
-	file, err := os.OpenFile("logs.txt", os.O_APPEND|os.O_CREATE|os.O_WRONLY, 0666)
+	current.Store(&output{level: InfoLevel, formatter: logfmtFormatter{}, writer: os.Stdout})
+}
+
+func getOutput() *output {
+	return current.Load().(*output)
+}
+
+// Logger writes leveled records with a message and key-value fields. Loggers
+// are cheap to derive with With, so every component and session can carry
+// its own fields.
+type Logger struct {
+	fields []field
+}
+
+type field struct {
+	key   string
+	value interface{}
+}
+
+// New returns a logger of the component, e.g. "proxy" or "server".
+func New(component string) *Logger {
+	return &Logger{fields: []field{{"component", component}}}
+}
+
+// With returns a logger adding the given key-value pairs to every record.
+func (l *Logger) With(keyvals ...interface{}) *Logger {
+	fields := make([]field, 0, len(l.fields)+len(keyvals)/2)
+	fields = append(fields, l.fields...)
+	return &Logger{fields: appendFields(fields, keyvals)}
+}
+
+func appendFields(fields []field, keyvals []interface{}) []field {
+	for i := 0; i < len(keyvals); i += 2 {
+		key := fmt.Sprint(keyvals[i])
+		if i+1 == len(keyvals) {
+			fields = append(fields, field{key, "(missing)"})
+			break
+		}
+		fields = append(fields, field{key, keyvals[i+1]})
+	}
+	return fields
+}
+
+// Enabled tells whether records of the level are written, so that costly
+// fields can be skipped.
+func (l *Logger) Enabled(level Level) bool {
+	return level >= getOutput().level
+}
+
+func (l *Logger) log(level Level, msg string, keyvals []interface{}) {
+	out := getOutput()
+	if level < out.level {
+		return
+	}
+
+	fields := make([]field, 0, len(l.fields)+len(keyvals)/2)
+	fields = append(fields, l.fields...)
+	fields = appendFields(fields, keyvals)
+
+	rec := record{time: time.Now(), level: level, msg: redactText(msg), fields: redactFields(fields)}
+	line := out.formatter.format(rec)
+
+	outputLock.Lock()
+	defer outputLock.Unlock()
+	// the output could have been replaced while the record was formatted
+	if _, err := getOutput().writer.Write(line); err != nil {
+		fmt.Fprintf(os.Stderr, "failed to write log record: %v\n", err)
+	}
+}
+
+func (l *Logger) Debug(msg string, keyvals ...interface{}) {
+	l.log(DebugLevel, msg, keyvals)
+}
+
+func (l *Logger) Info(msg string, keyvals ...interface{}) {
+	l.log(InfoLevel, msg, keyvals)
+}
+
+func (l *Logger) Warn(msg string, keyvals ...interface{}) {
+	l.log(WarnLevel, msg, keyvals)
+}
+
+func (l *Logger) Error(msg string, keyvals ...interface{}) {
+	l.log(ErrorLevel, msg, keyvals)
+}
+
+// Configure replaces the output of all loggers. Records written by the
+// standard log package, e.g. by libraries, go through it as well.
+func Configure(config Config) error {
+	level, err := ParseLevel(config.Level)
+	if err != nil {
+		return err
+	}
+
+	formatter, err := newFormatter(config.Format)
+	if err != nil {
+		return err
+	}
+
+	writer, err := newWriter(config)
 	if err != nil {
-		log.Fatal(err)
+		return err
+	}
+
+	outputLock.Lock()
+	previous := getOutput()
+	current.Store(&output{level: level, formatter: formatter, writer: writer})
+	// only log files are closed, stdout and stderr stay open
+	if file, ok := previous.writer.(*rotatingFile); ok {
+		file.Close()
 	}
+	outputLock.Unlock()
+
+	log.SetFlags(0)
+	log.SetOutput(stdlibWriter{New("stdlib")})
+	return nil
+}
+
+// stdlibWriter turns lines of the standard log package into info records.
+type stdlibWriter struct {
+	logger *Logger
+}
 
-	log.SetOutput(file)
+func (w stdlibWriter) Write(p []byte) (int, error) {
+	w.logger.Info(strings.TrimRight(string(p), "\n"))
+	return len(p), nil
 }
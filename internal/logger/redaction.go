@@ -0,0 +1,78 @@
+package logger
+
+import (
+	"regexp"
+	"strings"
+	"sync/atomic"
+)
+
+const redacted = "[REDACTED]"
+
+var (
+	// key=value and key: value pairs, e.g. in connection strings
+	passwordPairPattern = regexp.MustCompile(`(?i)\b(password|passwd|pwd)(\s*[=:]\s*)('[^']*'|"[^"]*"|[^\s,;]+)`)
+	// PASSWORD 'secret' clause of CREATE and ALTER ROLE
+	passwordClausePattern = regexp.MustCompile(`(?i)\b(password\s+)'(?:[^']|'')*'`)
+	// user:secret@ part of URLs
+	urlPasswordPattern = regexp.MustCompile(`(\w+://[^:/@\s]+:)[^@\s]+@`)
+)
+
+// queryKeys are fields holding query text, passed through the query redactor.
+var queryKeys = map[string]bool{
+	"query":     true,
+	"statement": true,
+}
+
+var sensitiveKeyParts = []string{"password", "passwd", "secret", "token"}
+
+var queryRedactor atomic.Value
+
+func init() {
+	queryRedactor.Store(func(string) string { return redacted })
+}
+
+// SetQueryRedactor sets the function removing literals from queries logged in
+// query fields. Until it's set, such fields are redacted as a whole.
+func SetQueryRedactor(redactor func(query string) string) {
+	queryRedactor.Store(redactor)
+}
+
+func redactQuery(query string) string {
+	return queryRedactor.Load().(func(string) string)(query)
+}
+
+func redactFields(fields []field) []field {
+	for i, f := range fields {
+		key := strings.ToLower(f.key)
+		switch {
+		case queryKeys[key]:
+			fields[i].value = redactQuery(formatValue(f.value))
+		case isSensitiveKey(key):
+			fields[i].value = redacted
+		default:
+			if value, ok := f.value.(string); ok {
+				fields[i].value = redactText(value)
+			} else if err, ok := f.value.(error); ok {
+				fields[i].value = redactText(err.Error())
+			}
+		}
+	}
+	return fields
+}
+
+func isSensitiveKey(key string) bool {
+	for _, part := range sensitiveKeyParts {
+		if strings.Contains(key, part) {
+			return true
+		}
+	}
+	return false
+}
+
+// redactText hides passwords in free text, e.g. messages of errors returned
+// by database drivers.
+func redactText(text string) string {
+	text = passwordClausePattern.ReplaceAllString(text, "${1}'"+redacted+"'")
+	text = passwordPairPattern.ReplaceAllString(text, "${1}${2}"+redacted)
+	return urlPasswordPattern.ReplaceAllString(text, "${1}"+redacted+"@")
+}
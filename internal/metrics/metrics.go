@@ -0,0 +1,237 @@
+package metrics
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"math"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+)
+
+// DefaultBuckets are upper bounds of histogram buckets, in seconds.
+var DefaultBuckets = []float64{.005, .01, .025, .05, .1, .25, .5, 1, 2.5, 5, 10}
+
+type family interface {
+	write(w *bufio.Writer)
+}
+
+// Registry collects metric families and writes them in the Prometheus text
+// exposition format.
+type Registry struct {
+	families []family
+	lock     sync.Mutex
+}
+
+func NewRegistry() *Registry {
+	return &Registry{}
+}
+
+func (r *Registry) register(f family) {
+	r.lock.Lock()
+	r.families = append(r.families, f)
+	r.lock.Unlock()
+}
+
+func (r *Registry) Write(w io.Writer) error {
+	r.lock.Lock()
+	families := append([]family(nil), r.families...)
+	r.lock.Unlock()
+
+	buffered := bufio.NewWriter(w)
+	for _, f := range families {
+		f.write(buffered)
+	}
+	return buffered.Flush()
+}
+
+type metric struct {
+	name   string
+	help   string
+	labels []string
+}
+
+func (m metric) writeHeader(w *bufio.Writer, kind string) {
+	fmt.Fprintf(w, "# HELP %s %s\n", m.name, m.help)
+	fmt.Fprintf(w, "# TYPE %s %s\n", m.name, kind)
+}
+
+// key joins label values, so that they can be used as a map key.
+func (m metric) key(labelValues []string) string {
+	if len(labelValues) != len(m.labels) {
+		panic(fmt.Sprintf("metric %s expects %d label values, got %d", m.name, len(m.labels), len(labelValues)))
+	}
+	return strings.Join(labelValues, "\xff")
+}
+
+func (m metric) formatLabels(key string, extra ...string) string {
+	var pairs []string
+	if len(m.labels) > 0 {
+		for i, value := range strings.Split(key, "\xff") {
+			pairs = append(pairs, fmt.Sprintf(`%s="%s"`, m.labels[i], escapeLabelValue(value)))
+		}
+	}
+	for i := 0; i+1 < len(extra); i += 2 {
+		pairs = append(pairs, fmt.Sprintf(`%s="%s"`, extra[i], escapeLabelValue(extra[i+1])))
+	}
+	if len(pairs) == 0 {
+		return ""
+	}
+	return "{" + strings.Join(pairs, ",") + "}"
+}
+
+func escapeLabelValue(value string) string {
+	value = strings.ReplaceAll(value, `\`, `\\`)
+	value = strings.ReplaceAll(value, `"`, `\"`)
+	return strings.ReplaceAll(value, "\n", `\n`)
+}
+
+func formatValue(value float64) string {
+	switch {
+	case math.IsInf(value, 1):
+		return "+Inf"
+	case math.IsInf(value, -1):
+		return "-Inf"
+	}
+	return strconv.FormatFloat(value, 'g', -1, 64)
+}
+
+func sortedKeys(values map[string]float64) []string {
+	keys := make([]string, 0, len(values))
+	for key := range values {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+	return keys
+}
+
+// valueVec is a set of series of a counter or gauge, one for every
+// combination of label values.
+type valueVec struct {
+	metric
+	kind   string
+	values map[string]float64
+	lock   sync.Mutex
+}
+
+func (v *valueVec) add(value float64, labelValues []string) {
+	key := v.key(labelValues)
+	v.lock.Lock()
+	v.values[key] += value
+	v.lock.Unlock()
+}
+
+func (v *valueVec) write(w *bufio.Writer) {
+	v.lock.Lock()
+	defer v.lock.Unlock()
+
+	v.writeHeader(w, v.kind)
+	for _, key := range sortedKeys(v.values) {
+		fmt.Fprintf(w, "%s%s %s\n", v.name, v.formatLabels(key), formatValue(v.values[key]))
+	}
+}
+
+type CounterVec struct {
+	valueVec
+}
+
+func NewCounterVec(registry *Registry, name, help string, labels ...string) *CounterVec {
+	c := &CounterVec{valueVec{metric: metric{name, help, labels}, kind: "counter", values: make(map[string]float64)}}
+	registry.register(c)
+	return c
+}
+
+func (c *CounterVec) Inc(labelValues ...string) {
+	c.add(1, labelValues)
+}
+
+// Add increases the counter, negative values are ignored.
+func (c *CounterVec) Add(value float64, labelValues ...string) {
+	if value < 0 {
+		return
+	}
+	c.add(value, labelValues)
+}
+
+type GaugeVec struct {
+	valueVec
+}
+
+func NewGaugeVec(registry *Registry, name, help string, labels ...string) *GaugeVec {
+	g := &GaugeVec{valueVec{metric: metric{name, help, labels}, kind: "gauge", values: make(map[string]float64)}}
+	registry.register(g)
+	return g
+}
+
+func (g *GaugeVec) Add(value float64, labelValues ...string) {
+	g.add(value, labelValues)
+}
+
+func (g *GaugeVec) Set(value float64, labelValues ...string) {
+	key := g.key(labelValues)
+	g.lock.Lock()
+	g.values[key] = value
+	g.lock.Unlock()
+}
+
+type histogram struct {
+	counts []uint64
+	count  uint64
+	sum    float64
+}
+
+type HistogramVec struct {
+	metric
+	buckets    []float64
+	histograms map[string]*histogram
+	lock       sync.Mutex
+}
+
+func NewHistogramVec(registry *Registry, name, help string, buckets []float64, labels ...string) *HistogramVec {
+	h := &HistogramVec{metric: metric{name, help, labels}, buckets: buckets, histograms: make(map[string]*histogram)}
+	registry.register(h)
+	return h
+}
+
+func (h *HistogramVec) Observe(value float64, labelValues ...string) {
+	key := h.key(labelValues)
+	h.lock.Lock()
+	defer h.lock.Unlock()
+
+	series, present := h.histograms[key]
+	if !present {
+		series = &histogram{counts: make([]uint64, len(h.buckets))}
+		h.histograms[key] = series
+	}
+	for i, bound := range h.buckets {
+		if value <= bound {
+			series.counts[i]++
+		}
+	}
+	series.count++
+	series.sum += value
+}
+
+func (h *HistogramVec) write(w *bufio.Writer) {
+	h.lock.Lock()
+	defer h.lock.Unlock()
+
+	h.writeHeader(w, "histogram")
+	keys := make([]string, 0, len(h.histograms))
+	for key := range h.histograms {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+
+	for _, key := range keys {
+		series := h.histograms[key]
+		for i, bound := range h.buckets {
+			fmt.Fprintf(w, "%s_bucket%s %d\n", h.name, h.formatLabels(key, "le", formatValue(bound)), series.counts[i])
+		}
+		fmt.Fprintf(w, "%s_bucket%s %d\n", h.name, h.formatLabels(key, "le", "+Inf"), series.count)
+		fmt.Fprintf(w, "%s_sum%s %s\n", h.name, h.formatLabels(key), formatValue(series.sum))
+		fmt.Fprintf(w, "%s_count%s %d\n", h.name, h.formatLabels(key), series.count)
+	}
+}
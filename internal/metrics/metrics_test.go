@@ -0,0 +1,119 @@
+package metrics
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestRegistryWrite(t *testing.T) {
+	tests := []struct {
+		name   string
+		record func(registry *Registry)
+		want   string
+	}{
+		{
+			name: "counter with labels sorted by values",
+			record: func(registry *Registry) {
+				counter := NewCounterVec(registry, "queries_total", "Queries seen.", "proxy", "direction")
+				counter.Inc("p2", "in")
+				counter.Inc("p1", "out")
+				counter.Add(2.5, "p1", "out")
+				counter.Add(-1, "p1", "out")
+			},
+			want: `# HELP queries_total Queries seen.
+# TYPE queries_total counter
+queries_total{proxy="p1",direction="out"} 3.5
+queries_total{proxy="p2",direction="in"} 1
+`,
+		},
+		{
+			name: "gauge without labels",
+			record: func(registry *Registry) {
+				gauge := NewGaugeVec(registry, "sessions", "Open sessions.")
+				gauge.Add(3)
+				gauge.Add(-1)
+			},
+			want: `# HELP sessions Open sessions.
+# TYPE sessions gauge
+sessions 2
+`,
+		},
+		{
+			name: "gauge set",
+			record: func(registry *Registry) {
+				gauge := NewGaugeVec(registry, "ratio", "Ratio.", "proxy")
+				gauge.Add(5, "p1")
+				gauge.Set(0.25, "p1")
+			},
+			want: `# HELP ratio Ratio.
+# TYPE ratio gauge
+ratio{proxy="p1"} 0.25
+`,
+		},
+		{
+			name: "escaped label values",
+			record: func(registry *Registry) {
+				NewCounterVec(registry, "rules_total", "Rule matches.", "rule").Inc("a\"b\\c\nd")
+			},
+			want: `# HELP rules_total Rule matches.
+# TYPE rules_total counter
+rules_total{rule="a\"b\\c\nd"} 1
+`,
+		},
+		{
+			name: "histogram buckets are cumulative",
+			record: func(registry *Registry) {
+				histogram := NewHistogramVec(registry, "duration_seconds", "Durations.", []float64{0.1, 1}, "proxy")
+				histogram.Observe(0.05, "p1")
+				histogram.Observe(0.5, "p1")
+				histogram.Observe(3, "p1")
+			},
+			want: `# HELP duration_seconds Durations.
+# TYPE duration_seconds histogram
+duration_seconds_bucket{proxy="p1",le="0.1"} 1
+duration_seconds_bucket{proxy="p1",le="1"} 2
+duration_seconds_bucket{proxy="p1",le="+Inf"} 3
+duration_seconds_sum{proxy="p1"} 3.55
+duration_seconds_count{proxy="p1"} 3
+`,
+		},
+		{
+			name: "families in order of registration",
+			record: func(registry *Registry) {
+				NewCounterVec(registry, "b_total", "B.").Inc()
+				NewGaugeVec(registry, "a", "A.")
+			},
+			want: `# HELP b_total B.
+# TYPE b_total counter
+b_total 1
+# HELP a A.
+# TYPE a gauge
+`,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			registry := NewRegistry()
+			tt.record(registry)
+
+			var out strings.Builder
+			if err := registry.Write(&out); err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+			if out.String() != tt.want {
+				t.Errorf("got\n%s\nwant\n%s", out.String(), tt.want)
+			}
+		})
+	}
+}
+
+func TestLabelValuesCountMismatch(t *testing.T) {
+	counter := NewCounterVec(NewRegistry(), "c_total", "C.", "proxy")
+	defer func() {
+		if recover() == nil {
+			t.Error("expected a panic")
+		}
+	}()
+	counter.Inc("p1", "extra")
+}
@@ -0,0 +1,64 @@
+package metrics
+
+const (
+	Inbound  = "inbound"
+	Outbound = "outbound"
+
+	ConnectionAccepted = "accepted"
+	ConnectionRejected = "rejected"
+
+	DenyListHit = "deny"
+	OffenderHit = "offender"
+)
+
+// ProxyMetrics groups metrics of all proxies, every series is labeled with
+// name of the proxy.
+type ProxyMetrics struct {
+	Registry           *Registry
+	ActiveSessions     *GaugeVec
+	Connections        *CounterVec
+	TargetDialFailures *CounterVec
+	BytesForwarded     *CounterVec
+	Messages           *CounterVec
+	QueriesInspected   *CounterVec
+	Detections         *CounterVec
+	BlockedQueries     *CounterVec
+	BlacklistHits      *CounterVec
+	RateLimited        *CounterVec
+	QueryDuration      *HistogramVec
+	SlowQueries        *CounterVec
+	SlowQueriesDropped *CounterVec
+}
+
+func NewProxyMetrics() *ProxyMetrics {
+	registry := NewRegistry()
+	return &ProxyMetrics{
+		Registry: registry,
+		ActiveSessions: NewGaugeVec(registry, "goharder_active_sessions",
+			"Number of open sessions.", "proxy"),
+		Connections: NewCounterVec(registry, "goharder_connections_total",
+			"Client connections accepted or rejected by the proxy.", "proxy", "result"),
+		TargetDialFailures: NewCounterVec(registry, "goharder_target_dial_failures_total",
+			"Failed attempts to connect to the target database.", "proxy"),
+		BytesForwarded: NewCounterVec(registry, "goharder_bytes_forwarded_total",
+			"Bytes of protocol messages received from the client (inbound) or the target (outbound).", "proxy", "direction"),
+		Messages: NewCounterVec(registry, "goharder_messages_total",
+			"Protocol messages by direction and type.", "proxy", "direction", "type"),
+		QueriesInspected: NewCounterVec(registry, "goharder_queries_inspected_total",
+			"Queries inspected by the detector.", "proxy"),
+		Detections: NewCounterVec(registry, "goharder_detections_total",
+			"Rules matched by malicious queries.", "proxy", "rule", "severity"),
+		BlockedQueries: NewCounterVec(registry, "goharder_blocked_queries_total",
+			"Malicious queries answered with an error instead of being forwarded.", "proxy"),
+		BlacklistHits: NewCounterVec(registry, "goharder_blacklist_hits_total",
+			"Connections from denied addresses and requests of recent offenders.", "proxy", "kind"),
+		RateLimited: NewCounterVec(registry, "goharder_rate_limited_total",
+			"Requests over the rate limit by the action taken.", "proxy", "action"),
+		QueryDuration: NewHistogramVec(registry, "goharder_query_duration_seconds",
+			"Time between forwarding a query and ReadyForQuery sent by the target.", DefaultBuckets, "proxy"),
+		SlowQueries: NewCounterVec(registry, "goharder_slow_queries_total",
+			"Statements which took longer than the slow query threshold of the proxy.", "proxy"),
+		SlowQueriesDropped: NewCounterVec(registry, "goharder_slow_queries_dropped_total",
+			"Slow queries which weren't stored, the queue of the database was full.", "proxy"),
+	}
+}
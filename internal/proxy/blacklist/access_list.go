@@ -0,0 +1,118 @@
+package blacklist
+
+import (
+	"fmt"
+	"net"
+	"proxy-engineering-thesis/model"
+	"strings"
+	"sync"
+	"time"
+)
+
+type accessEntry struct {
+	allow     bool
+	network   *net.IPNet
+	expiresAt *time.Time
+}
+
+// AccessList holds manually managed allow and deny entries. When several
+// entries match an address, the most specific one wins and deny wins a tie.
+type AccessList struct {
+	entries []accessEntry
+	lock    sync.RWMutex
+}
+
+func NewAccessList() *AccessList {
+	return &AccessList{}
+}
+
+// ParseNetwork parses a single IP address or a CIDR range.
+func ParseNetwork(address string) (*net.IPNet, error) {
+	address = strings.TrimSpace(address)
+	if strings.Contains(address, "/") {
+		_, network, err := net.ParseCIDR(address)
+		if err != nil {
+			return nil, fmt.Errorf("invalid CIDR range: %s", address)
+		}
+		return network, nil
+	}
+
+	ip := net.ParseIP(address)
+	if ip == nil {
+		return nil, fmt.Errorf("invalid IP address: %s", address)
+	}
+	if ipv4 := ip.To4(); ipv4 != nil {
+		return &net.IPNet{IP: ipv4, Mask: net.CIDRMask(32, 32)}, nil
+	}
+	return &net.IPNet{IP: ip, Mask: net.CIDRMask(128, 128)}, nil
+}
+
+// ValidateEntry checks the entry type and address.
+func ValidateEntry(entry model.BlacklistEntry) error {
+	switch strings.ToLower(entry.Type) {
+	case model.AllowEntry, model.DenyEntry:
+	default:
+		return fmt.Errorf("unknown entry type: %s", entry.Type)
+	}
+
+	_, err := ParseNetwork(entry.Address)
+	return err
+}
+
+// Update replaces all entries of the list.
+func (al *AccessList) Update(entries []model.BlacklistEntry) error {
+	compiled := make([]accessEntry, 0, len(entries))
+	for _, entry := range entries {
+		if err := ValidateEntry(entry); err != nil {
+			return fmt.Errorf("invalid entry %d: %v", entry.ID, err)
+		}
+		network, _ := ParseNetwork(entry.Address)
+		compiled = append(compiled, accessEntry{
+			allow:     strings.ToLower(entry.Type) == model.AllowEntry,
+			network:   network,
+			expiresAt: entry.ExpiresAt,
+		})
+	}
+
+	al.lock.Lock()
+	al.entries = compiled
+	al.lock.Unlock()
+	return nil
+}
+
+func (al *AccessList) IsAllowed(clientIP string) bool {
+	allow, matched := al.lookup(clientIP)
+	return matched && allow
+}
+
+func (al *AccessList) IsDenied(clientIP string) bool {
+	allow, matched := al.lookup(clientIP)
+	return matched && !allow
+}
+
+func (al *AccessList) lookup(clientIP string) (bool, bool) {
+	ip := net.ParseIP(clientIP)
+	if ip == nil {
+		return false, false
+	}
+
+	al.lock.RLock()
+	defer al.lock.RUnlock()
+
+	now := time.Now()
+	allow, matched, bestPrefix := false, false, -1
+	for _, entry := range al.entries {
+		if entry.expiresAt != nil && now.After(*entry.expiresAt) {
+			continue
+		}
+		if !entry.network.Contains(ip) {
+			continue
+		}
+
+		prefix, _ := entry.network.Mask.Size()
+		if prefix > bestPrefix || (prefix == bestPrefix && !entry.allow) {
+			allow, matched, bestPrefix = entry.allow, true, prefix
+		}
+	}
+	return allow, matched
+}
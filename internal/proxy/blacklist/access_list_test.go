@@ -0,0 +1,83 @@
+package blacklist
+
+import (
+	"proxy-engineering-thesis/model"
+	"testing"
+	"time"
+)
+
+func TestAccessListMostSpecificWins(t *testing.T) {
+	past := time.Now().Add(-time.Minute)
+	future := time.Now().Add(time.Hour)
+	entries := []model.BlacklistEntry{
+		{Type: model.DenyEntry, Address: "10.0.0.0/8"},
+		{Type: model.AllowEntry, Address: "10.1.0.0/16"},
+		{Type: model.DenyEntry, Address: "10.1.2.3"},
+		{Type: model.AllowEntry, Address: "192.168.0.0/24"},
+		{Type: model.DenyEntry, Address: "192.168.0.0/24"},
+		{Type: model.AllowEntry, Address: "172.16.0.1", ExpiresAt: &past},
+		{Type: model.DenyEntry, Address: "172.16.0.0/12"},
+		{Type: model.DenyEntry, Address: "172.20.0.1", ExpiresAt: &future},
+		{Type: model.AllowEntry, Address: "2001:db8::/32"},
+		{Type: model.DenyEntry, Address: "2001:db8::bad"},
+	}
+
+	accessList := NewAccessList()
+	if err := accessList.Update(entries); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	tests := []struct {
+		name        string
+		ip          string
+		wantAllowed bool
+		wantDenied  bool
+	}{
+		{name: "range", ip: "10.200.0.1", wantDenied: true},
+		{name: "narrower range", ip: "10.1.200.1", wantAllowed: true},
+		{name: "single address", ip: "10.1.2.3", wantDenied: true},
+		{name: "deny wins a tie", ip: "192.168.0.10", wantDenied: true},
+		{name: "expired entry is skipped", ip: "172.16.0.1", wantDenied: true},
+		{name: "entry before expiry", ip: "172.20.0.1", wantDenied: true},
+		{name: "ipv6 range", ip: "2001:db8::1", wantAllowed: true},
+		{name: "ipv6 address", ip: "2001:db8::bad", wantDenied: true},
+		{name: "unlisted", ip: "8.8.8.8"},
+		{name: "invalid address", ip: "not-an-ip"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := accessList.IsAllowed(tt.ip); got != tt.wantAllowed {
+				t.Errorf("IsAllowed = %t, want %t", got, tt.wantAllowed)
+			}
+			if got := accessList.IsDenied(tt.ip); got != tt.wantDenied {
+				t.Errorf("IsDenied = %t, want %t", got, tt.wantDenied)
+			}
+		})
+	}
+}
+
+func TestAccessListUpdateRejectsInvalidEntries(t *testing.T) {
+	tests := []struct {
+		name  string
+		entry model.BlacklistEntry
+	}{
+		{name: "unknown type", entry: model.BlacklistEntry{Type: "block", Address: "10.0.0.1"}},
+		{name: "invalid address", entry: model.BlacklistEntry{Type: model.DenyEntry, Address: "10.0.0"}},
+		{name: "invalid range", entry: model.BlacklistEntry{Type: model.DenyEntry, Address: "10.0.0.0/33"}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			accessList := NewAccessList()
+			accessList.Update([]model.BlacklistEntry{{Type: model.DenyEntry, Address: "10.0.0.1"}})
+			if err := accessList.Update([]model.BlacklistEntry{tt.entry}); err == nil {
+				t.Fatal("expected an error")
+			}
+			// a failed update keeps the previous entries
+			if !accessList.IsDenied("10.0.0.1") {
+				t.Error("previous entries were replaced")
+			}
+		})
+	}
+}
@@ -1,91 +1,133 @@
 package blacklist
 
 import (
+	"fmt"
 	"github.com/bluele/gcache"
-	"log"
 	"math"
+	"proxy-engineering-thesis/internal/logger"
+	"sync"
 	"time"
 )
 
+const (
+	GlobalScope = "global"
+	ProxyScope  = "proxy"
+
+	// offences are counted up to the limit, the ban is long enough by then
+	maxOffences = 32
+)
+
+var log = logger.New("blacklist")
+
+// Config sets how many clients are tracked and how long they're limited. The
+// ban starts at BanDuration and doubles with every offence up to
+// MaxBanDuration.
+type Config struct {
+	CacheSize      int
+	BanDuration    time.Duration
+	MaxBanDuration time.Duration
+}
+
+func DefaultConfig() Config {
+	return Config{
+		CacheSize:      10000,
+		BanDuration:    4 * time.Second,
+		MaxBanDuration: 128 * time.Second,
+	}
+}
+
+// BlackListManager tracks offending clients. A single manager is shared by all
+// proxies, so clients are tracked across their connections.
 type BlackListManager struct {
-	Cache                  gcache.Cache
-	LastAccess             map[string]time.Time
-	MinimalRequestInterval time.Duration
+	Cache      gcache.Cache
+	AccessList *AccessList
+	config     Config
+	lock       sync.Mutex
 }
 
-func NewBlackListManager(interval time.Duration) *BlackListManager {
+func NewBlackListManager(accessList *AccessList, config Config) *BlackListManager {
 	return &BlackListManager{
-		Cache:                  newBlackListCache(),
-		LastAccess:             make(map[string]time.Time),
-		MinimalRequestInterval: interval,
+		Cache:      newBlackListCache(config.CacheSize),
+		AccessList: accessList,
+		config:     config,
+	}
+}
+
+// Key identifies a client address in the blacklist. Clients of proxies using
+// the proxy scope are tracked separately for every proxy.
+func Key(clientIP string, proxyId uint, scope string) string {
+	if scope == ProxyScope {
+		return fmt.Sprintf("%d/%s", proxyId, clientIP)
 	}
+	return clientIP
 }
 
-func newBlackListCache() gcache.Cache {
-	return gcache.New(100).LFU().LoaderFunc(func(key interface{}) (interface{}, error) {
-		return 0, nil
+// IsAllowed tells whether the client is allowlisted and so exempt from
+// automatic blocking.
+func (blm *BlackListManager) IsAllowed(clientIP string) bool {
+	return blm.AccessList.IsAllowed(clientIP)
+}
+
+// IsDenied tells whether connections from the client are refused.
+func (blm *BlackListManager) IsDenied(clientIP string) bool {
+	return blm.AccessList.IsDenied(clientIP)
+}
+
+// ban of an offending source, every offence makes it longer
+type ban struct {
+	offences float64
+	until    time.Time
+}
+
+func newBlackListCache(cacheSize int) gcache.Cache {
+	return gcache.New(cacheSize).LFU().LoaderFunc(func(key interface{}) (interface{}, error) {
+		return ban{}, nil
 	}).EvictedFunc(func(key, val interface{}) {
 		strKey, ok := key.(string)
 		if ok {
-			log.Printf("evicted entry from cache - %s can connect again without delay", strKey)
+			log.Info("source is no longer limited", "source", strKey)
 		}
 
 	}).Build()
 }
 
-func (blm *BlackListManager) UpdateLastAccess(key string) {
-	blm.LastAccess[key] = time.Now()
-}
-
 func (blm *BlackListManager) UpdateCache(key string) {
-	cacheValue, _ := blm.Cache.Get(key)
-	floatValue := convertCacheValueToFloat64(cacheValue)
-	exp := math.Min(floatValue+1, 6)
-	newExpirationTime := time.Second * time.Duration(math.Pow(2, exp+1))
-	blm.Cache.SetWithExpire(key, exp, newExpirationTime)
-	log.Printf("next request from source %s will be blocked for %d seconds", key, newExpirationTime/2e9)
+	// the counter is read and written back, so updates of concurrent sessions
+	// of the same client can't get lost
+	blm.lock.Lock()
+	defer blm.lock.Unlock()
+
+	offences := math.Min(blm.getBan(key).offences+1, maxOffences)
+	newExpirationTime := blm.banDuration(offences)
+	blm.Cache.SetWithExpire(key, ban{offences: offences, until: time.Now().Add(newExpirationTime)}, newExpirationTime)
+	log.Warn("source will be limited", "source", key, "duration", newExpirationTime)
 }
 
-func (blm *BlackListManager) isIntervalSufficient(key string) bool {
-	var isSufficient = true
-	lastAccessTime, present := blm.LastAccess[key]
-	interval := time.Now().Sub(lastAccessTime).Seconds()
-	if present {
-		isSufficient = interval >= blm.MinimalRequestInterval.Seconds()
-		if !isSufficient {
-			log.Printf("INSUFFICIENT INTERVAL: %f\n", interval)
-		}
+func (blm *BlackListManager) banDuration(offences float64) time.Duration {
+	duration := float64(blm.config.BanDuration) * math.Pow(2, offences-1)
+	if duration > float64(blm.config.MaxBanDuration) {
+		return blm.config.MaxBanDuration
 	}
-
-	return isSufficient
+	return time.Duration(duration)
 }
 
+// ShouldRequestBeBlocked tells whether the source is a recent offender.
 func (blm *BlackListManager) ShouldRequestBeBlocked(key string) bool {
-	val, _ := blm.Cache.Get(key)
+	return blm.getBan(key).offences > 0
+}
 
-	return !blm.isIntervalSufficient(key) || convertCacheValueToFloat64(val) > 0
+// BannedUntil returns when the ban of the source ends, or zero time if the
+// source isn't banned. Every offence starts a new ban, ending later.
+func (blm *BlackListManager) BannedUntil(key string) time.Time {
+	return blm.getBan(key).until
 }
 
 func (blm *BlackListManager) PurgeCache() {
 	blm.Cache.Purge()
 }
 
-func (blm *BlackListManager) BlockProcessingTraffic(key string) {
-	cacheValue, _ := blm.Cache.Get(key)
-	floatValue := convertCacheValueToFloat64(cacheValue)
-	time.Sleep(getSufficientSleepTime(floatValue))
-}
-
-func getSufficientSleepTime(exp float64) time.Duration {
-	finalExp := math.Max(exp, 4)
-	return time.Duration(math.Pow(2, finalExp)) * time.Second
-}
-
-func convertCacheValueToFloat64(value interface{}) float64 {
-	convertedValue, ok := value.(float64)
-	if ok {
-		return convertedValue
-	}
-
-	return 0
+func (blm *BlackListManager) getBan(key string) ban {
+	value, _ := blm.Cache.Get(key)
+	b, _ := value.(ban)
+	return b
 }
@@ -0,0 +1,96 @@
+package detection
+
+import (
+	"strings"
+)
+
+const AnomalyRuleName = "unknown-query-fingerprint"
+
+// Baseline holds fingerprints of queries known to be sent by database users
+// through a proxy.
+type Baseline interface {
+	// Learning tells whether new fingerprints should be added to the baseline
+	// instead of being flagged.
+	Learning() bool
+	Contains(user, fingerprint string) bool
+	Learn(user, fingerprint string)
+}
+
+// BaselineStore provides baselines of particular proxies.
+type BaselineStore interface {
+	ForProxy(proxyId uint) Baseline
+}
+
+// AnomalyDetector flags queries whose fingerprint was never seen for the user
+// while the baseline was learned. Bound queries are fingerprinted by their
+// prepared statement.
+type AnomalyDetector struct {
+	baseline Baseline
+}
+
+func NewAnomalyDetector(baseline Baseline) AnomalyDetector {
+	return AnomalyDetector{baseline: baseline}
+}
+
+func (d AnomalyDetector) GetMaliciousQueries() []string {
+	return nil
+}
+
+func (d AnomalyDetector) DetectMaliciousContent(payload []byte, ctx QueryContext) Verdict {
+	query := NewNormalizedQuery(strings.TrimRight(string(payload), "\x00"))
+	fingerprint := Fingerprint(query.Tokens)
+	if ctx.Statement != "" {
+		fingerprint = Fingerprint(Tokenize(ctx.Statement))
+	}
+	if fingerprint == "" {
+		return NewVerdict(query.Text, nil)
+	}
+
+	if d.baseline.Contains(ctx.User, fingerprint) {
+		return NewVerdict(query.Text, nil)
+	}
+
+	if d.baseline.Learning() {
+		log.Info("learned new query fingerprint", "user", ctx.User, "fingerprint", fingerprint)
+		d.baseline.Learn(ctx.User, fingerprint)
+		return NewVerdict(query.Text, nil)
+	}
+
+	return NewVerdict(query.Text, []RuleMatch{{
+		RuleName: AnomalyRuleName,
+		Severity: MediumSeverity,
+		Start:    0,
+		End:      len(query.Text),
+		Fragment: query.Text,
+	}})
+}
+
+// CompositeDetector runs several detectors and combines their verdicts.
+type CompositeDetector struct {
+	detectors []Detector
+}
+
+func NewCompositeDetector(detectors ...Detector) CompositeDetector {
+	return CompositeDetector{detectors: detectors}
+}
+
+func (d CompositeDetector) GetMaliciousQueries() []string {
+	var queries []string
+	for _, detector := range d.detectors {
+		queries = append(queries, detector.GetMaliciousQueries()...)
+	}
+	return queries
+}
+
+func (d CompositeDetector) DetectMaliciousContent(payload []byte, ctx QueryContext) Verdict {
+	var normalizedQuery string
+	var matches []RuleMatch
+	for _, detector := range d.detectors {
+		verdict := detector.DetectMaliciousContent(payload, ctx)
+		if normalizedQuery == "" {
+			normalizedQuery = verdict.NormalizedQuery
+		}
+		matches = append(matches, verdict.Matches...)
+	}
+	return NewVerdict(normalizedQuery, matches)
+}
@@ -0,0 +1,57 @@
+package detection
+
+import "testing"
+
+type testBaseline struct {
+	learning     bool
+	fingerprints map[string]bool
+}
+
+func (b *testBaseline) Learning() bool {
+	return b.learning
+}
+
+func (b *testBaseline) Contains(user, fingerprint string) bool {
+	return b.fingerprints[user+"/"+fingerprint]
+}
+
+func (b *testBaseline) Learn(user, fingerprint string) {
+	b.fingerprints[user+"/"+fingerprint] = true
+}
+
+func TestAnomalyDetectorBoundQueries(t *testing.T) {
+	baseline := &testBaseline{learning: true, fingerprints: map[string]bool{}}
+	detector := NewAnomalyDetector(baseline)
+	statement := "SELECT * FROM users WHERE name = $1"
+
+	// values substituted unquoted would each make a new fingerprint
+	for _, query := range []string{"SELECT * FROM users WHERE name = alice", "SELECT * FROM users WHERE name = bob"} {
+		ctx := QueryContext{User: "app", Statement: statement}
+		if verdict := detector.DetectMaliciousContent([]byte(query), ctx); verdict.IsMalicious() {
+			t.Fatalf("flagged while learning: %s", query)
+		}
+	}
+	if len(baseline.fingerprints) != 1 {
+		t.Fatalf("learned %d fingerprints, want 1: %v", len(baseline.fingerprints), baseline.fingerprints)
+	}
+
+	baseline.learning = false
+	tests := []struct {
+		name      string
+		query     string
+		statement string
+		want      bool
+	}{
+		{"known statement with other value", "SELECT * FROM users WHERE name = carol", statement, false},
+		{"unknown statement", "SELECT * FROM orders WHERE id = 1", "SELECT * FROM orders WHERE id = $1", true},
+		{"simple query of the statement", "SELECT * FROM users WHERE name = 'dave'", "", true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			ctx := QueryContext{User: "app", Statement: tt.statement}
+			if got := detector.DetectMaliciousContent([]byte(tt.query), ctx).IsMalicious(); got != tt.want {
+				t.Errorf("malicious = %t, want %t", got, tt.want)
+			}
+		})
+	}
+}
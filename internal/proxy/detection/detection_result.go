@@ -1,7 +1,106 @@
 package detection
 
+import (
+	"fmt"
+	"strings"
+)
+
 const (
 	MALICIOUS     = 0
 	SAFE          = 1
 	CANNOT_DEFINE = 2
 )
+
+var severityWeights = map[string]int{
+	LowSeverity:      1,
+	MediumSeverity:   3,
+	HighSeverity:     7,
+	CriticalSeverity: 10,
+}
+
+// RuleMatch describes a fragment of the normalized query matched by a rule.
+// SQLState and Message are the error the rule wants reported to the client.
+type RuleMatch struct {
+	RuleId   uint
+	RuleName string
+	Severity string
+	Start    int
+	End      int
+	Fragment string
+	SQLState string
+	Message  string
+}
+
+// Verdict is the outcome of inspecting a query. Severity is the highest one of
+// matched rules and Score sums up weights of their severities.
+type Verdict struct {
+	Status          int
+	Severity        string
+	Score           int
+	Matches         []RuleMatch
+	NormalizedQuery string
+}
+
+func NewVerdict(normalizedQuery string, matches []RuleMatch) Verdict {
+	verdict := Verdict{Status: SAFE, Matches: matches, NormalizedQuery: normalizedQuery}
+	for _, match := range matches {
+		verdict.Status = MALICIOUS
+		verdict.Score += GetSeverityWeight(match.Severity)
+		if GetSeverityWeight(match.Severity) > GetSeverityWeight(verdict.Severity) {
+			verdict.Severity = match.Severity
+		}
+	}
+	return verdict
+}
+
+func (v Verdict) IsMalicious() bool {
+	return v.Status == MALICIOUS
+}
+
+// RuleNames returns names of the matched rules.
+func (v Verdict) RuleNames() []string {
+	names := make([]string, 0, len(v.Matches))
+	for _, match := range v.Matches {
+		names = append(names, match.RuleName)
+	}
+	return names
+}
+
+func (v Verdict) RuleIds() []uint {
+	ids := make([]uint, 0, len(v.Matches))
+	for _, match := range v.Matches {
+		ids = append(ids, match.RuleId)
+	}
+	return ids
+}
+
+// ClientError returns SQLSTATE and message configured by the most severe matched
+// rule. Both are empty when none of the rules configures them.
+func (v Verdict) ClientError() (string, string) {
+	var sqlState, message string
+	sqlStateWeight, messageWeight := -1, -1
+	for _, match := range v.Matches {
+		weight := GetSeverityWeight(match.Severity)
+		if match.SQLState != "" && weight > sqlStateWeight {
+			sqlState, sqlStateWeight = match.SQLState, weight
+		}
+		if match.Message != "" && weight > messageWeight {
+			message, messageWeight = match.Message, weight
+		}
+	}
+	return sqlState, message
+}
+
+// Explain summarizes matched rules and fragments in a single line. Literals
+// of the fragments are redacted, as the summary ends up in alerts.
+func (v Verdict) Explain() string {
+	explanations := make([]string, 0, len(v.Matches))
+	for _, match := range v.Matches {
+		explanations = append(explanations, fmt.Sprintf("%s (%s) at %d-%d: '%s'", match.RuleName, match.Severity, match.Start, match.End, RedactQuery(match.Fragment)))
+	}
+	return fmt.Sprintf("severity - %s; score - %d; rules - %s", v.Severity, v.Score, strings.Join(explanations, ", "))
+}
+
+func GetSeverityWeight(severity string) int {
+	return severityWeights[strings.ToLower(severity)]
+}
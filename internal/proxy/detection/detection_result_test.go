@@ -0,0 +1,26 @@
+package detection
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestVerdictExplainRedactsFragments(t *testing.T) {
+	verdict := NewVerdict("select * from t where a = 'x' or '1' = '1'", []RuleMatch{
+		{RuleName: "or-tautology", Severity: HighSeverity, Start: 30, End: 42, Fragment: "or '1' = '1'"},
+		{RuleName: "numeric", Severity: LowSeverity, Start: 0, End: 8, Fragment: "or 42 = 42"},
+	})
+
+	explanation := verdict.Explain()
+	for _, literal := range []string{"'1'", "= 42"} {
+		if strings.Contains(explanation, literal) {
+			t.Errorf("explanation %q contains literal %s", explanation, literal)
+		}
+	}
+	if !strings.Contains(explanation, "or-tautology (high) at 30-42: 'or ? = ?'") {
+		t.Errorf("unexpected explanation %q", explanation)
+	}
+	if got := strings.Join(verdict.RuleNames(), ","); got != "or-tautology,numeric" {
+		t.Errorf("rule names %q", got)
+	}
+}
@@ -1,13 +1,66 @@
 package detection
 
 import (
-	"log"
+	"fmt"
+	"proxy-engineering-thesis/internal/logger"
 	"strings"
 )
 
+const (
+	SignatureEngine = "signature"
+	TokenEngine     = "token"
+	AnomalyEngine   = "anomaly"
+)
+
+var log = logger.New("detection")
+
 type Detector interface {
 	GetMaliciousQueries() []string
-	DetectMaliciousContent([]byte) int
+	DetectMaliciousContent([]byte, QueryContext) Verdict
+}
+
+// QueryContext describes the session an inspected query was sent in.
+type QueryContext struct {
+	ClientAddress   string
+	User            string
+	Database        string
+	ApplicationName string
+	// Statement is the text of the prepared statement a bound query was built
+	// from. Bound values are substituted unquoted, so the statement stands in
+	// for the query where its values don't matter.
+	Statement string
+}
+
+// NewDetector creates the detector implementation with given name. The
+// token-based engine, using rules from given rule set, is used by default.
+// Several engines separated with commas, e.g. "token,anomaly", are combined.
+func NewDetector(engine string, ruleSet *RuleSet, baseline Baseline) (Detector, error) {
+	engines := strings.Split(engine, ",")
+	if len(engines) > 1 {
+		detectors := make([]Detector, 0, len(engines))
+		for _, e := range engines {
+			detector, err := NewDetector(strings.TrimSpace(e), ruleSet, baseline)
+			if err != nil {
+				return nil, err
+			}
+			detectors = append(detectors, detector)
+		}
+		return NewCompositeDetector(detectors...), nil
+	}
+
+	switch strings.ToLower(engine) {
+	case "", TokenEngine:
+		return NewTokenDetector(ruleSet), nil
+	case SignatureEngine:
+		return SqlDetector{}, nil
+	case AnomalyEngine:
+		if baseline == nil {
+			return nil, fmt.Errorf("anomaly detection requires a query baseline")
+		}
+		return NewAnomalyDetector(baseline), nil
+	}
+
+	return nil, fmt.Errorf("unknown detection engine: %s", engine)
 }
 
 type SqlDetector struct {
@@ -35,18 +88,25 @@ func (d SqlDetector) GetMaliciousQueries() []string {
 	}
 }
 
-func (d SqlDetector) DetectMaliciousContent(payload []byte) int {
+func (d SqlDetector) DetectMaliciousContent(payload []byte, ctx QueryContext) Verdict {
 	query := strings.ToLower(string(payload))
-	log.Printf("Packet with DQL type: %s", query)
+	log.Debug("inspecting query", "user", ctx.User, "database", ctx.Database, "query", query)
+	var matches []RuleMatch
 	for _, v := range d.GetMaliciousQueries() {
-		if strings.Contains(query, v) {
-			return MALICIOUS
+		if start := strings.Index(query, v); start >= 0 {
+			matches = append(matches, RuleMatch{
+				RuleName: v,
+				Severity: HighSeverity,
+				Start:    start,
+				End:      start + len(v),
+				Fragment: v,
+			})
 		}
 	}
 
-	return SAFE
+	return NewVerdict(query, matches)
 }
 
-func (d LdapDetector) DetectMaliciousContent(payload []byte) int {
-	return CANNOT_DEFINE
+func (d LdapDetector) DetectMaliciousContent(payload []byte, ctx QueryContext) Verdict {
+	return Verdict{Status: CANNOT_DEFINE}
 }
@@ -0,0 +1,149 @@
+package detection
+
+import "strings"
+
+// maxEmbeddingDepth limits how deep code embedded in strings is inspected,
+// e.g. an EXECUTE string inside the body of a DO block is two levels deep.
+const maxEmbeddingDepth = 4
+
+// NormalizedQuery is the canonical text of a query together with the position
+// of each of its tokens in that text. Comments are left out of the text, so
+// they take no space in it.
+type NormalizedQuery struct {
+	Text   string
+	Tokens []Token
+	// Embedded holds code carried in string literals of the query, which is
+	// run by the database rather than compared as a value.
+	Embedded []EmbeddedQuery
+	spans    [][2]int
+}
+
+// EmbeddedQuery is code carried by the string tokens from First to Last of the
+// query it's embedded in.
+type EmbeddedQuery struct {
+	First int
+	Last  int
+	Query NormalizedQuery
+}
+
+func NewNormalizedQuery(query string) NormalizedQuery {
+	return newNormalizedQuery(query, 0)
+}
+
+func newNormalizedQuery(query string, depth int) NormalizedQuery {
+	tokens := Tokenize(query)
+	text, spans := render(tokens, false)
+	normalized := NormalizedQuery{Text: text, Tokens: tokens, spans: spans}
+	if depth < maxEmbeddingDepth {
+		for _, code := range embeddedCode(query, tokens) {
+			normalized.Embedded = append(normalized.Embedded, EmbeddedQuery{
+				First: code.first,
+				Last:  code.last,
+				Query: newNormalizedQuery(code.text, depth+1),
+			})
+		}
+	}
+	return normalized
+}
+
+type codeString struct {
+	first int
+	last  int
+	text  string
+}
+
+// embeddedCode finds strings of the query which hold code: bodies of
+// dollar-quoted strings, which are mostly function and DO bodies, and string
+// arguments of DO and EXECUTE. Strings concatenated with || are joined, so
+// code can't be hidden by splitting it.
+func embeddedCode(query string, tokens []Token) []codeString {
+	var code []codeString
+	statementStart := true
+	arguments := false
+	for i := 0; i < len(tokens); i++ {
+		token := tokens[i]
+		switch {
+		case token.Kind == CommentToken:
+			continue
+		case token.Kind == PunctuationToken && token.Value == ";":
+			statementStart = true
+			arguments = false
+			continue
+		case token.Kind == WordToken && (token.Value == "execute" || (token.Value == "do" && statementStart)):
+			arguments = true
+		case token.Kind == StringToken && (arguments || query[token.Start] == '$'):
+			last := i
+			text := token.Value
+			for arguments && last+2 < len(tokens) && tokens[last+1].Kind == OperatorToken && tokens[last+1].Value == "||" &&
+				tokens[last+2].Kind == StringToken {
+				last += 2
+				text += tokens[last].Value
+			}
+			code = append(code, codeString{first: i, last: last, text: text})
+			i = last
+		}
+		statementStart = false
+	}
+	return code
+}
+
+// Span returns offsets in the normalized text covering tokens from first to
+// last, inclusive.
+func (q NormalizedQuery) Span(first, last int) (int, int) {
+	return q.spans[first][0], q.spans[last][1]
+}
+
+// Normalize renders tokens back as a canonical query: comments are removed,
+// words are lower cased and tokens are separated with single spaces.
+func Normalize(tokens []Token) string {
+	text, _ := render(tokens, false)
+	return text
+}
+
+// Fingerprint renders tokens like Normalize, additionally replacing literals
+// with placeholders, so queries differing only in values are equal.
+func Fingerprint(tokens []Token) string {
+	text, _ := render(tokens, true)
+	return text
+}
+
+func render(tokens []Token, stripLiterals bool) (string, [][2]int) {
+	var builder strings.Builder
+	spans := make([][2]int, len(tokens))
+	for i, token := range tokens {
+		if token.Kind == CommentToken {
+			spans[i] = [2]int{builder.Len(), builder.Len()}
+			continue
+		}
+		if builder.Len() > 0 {
+			builder.WriteByte(' ')
+		}
+		start := builder.Len()
+		switch token.Kind {
+		case StringToken:
+			if stripLiterals {
+				builder.WriteByte('?')
+			} else {
+				builder.WriteString("'" + strings.ReplaceAll(token.Value, "'", "''") + "'")
+			}
+		case NumberToken:
+			if stripLiterals {
+				builder.WriteByte('?')
+			} else {
+				builder.WriteString(token.Value)
+			}
+		case QuotedIdentifierToken:
+			builder.WriteString("\"" + strings.ReplaceAll(token.Value, "\"", "\"\"") + "\"")
+		default:
+			builder.WriteString(token.Value)
+		}
+		spans[i] = [2]int{start, builder.Len()}
+	}
+	return builder.String(), spans
+}
+
+// RedactQuery replaces literals of the query with placeholders, so it can be
+// logged without the values it carries.
+func RedactQuery(query string) string {
+	return Fingerprint(Tokenize(query))
+}
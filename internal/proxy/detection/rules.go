@@ -0,0 +1,197 @@
+package detection
+
+import (
+	"fmt"
+	"proxy-engineering-thesis/model"
+	"regexp"
+	"strings"
+	"sync"
+)
+
+const (
+	SubstringPattern = "substring"
+	RegexPattern     = "regex"
+	TokenPattern     = "token"
+)
+
+const (
+	LowSeverity      = "low"
+	MediumSeverity   = "medium"
+	HighSeverity     = "high"
+	CriticalSeverity = "critical"
+)
+
+// sqlStatePattern matches five character SQLSTATE error codes, e.g. 42501.
+var sqlStatePattern = regexp.MustCompile(`^[0-9A-Z]{5}$`)
+
+// CompiledRule is a detection rule ready to be matched against queries.
+type CompiledRule struct {
+	Id           uint
+	Name         string
+	PatternType  string
+	Pattern      string
+	Severity     string
+	SQLState     string
+	ErrorMessage string
+	tokenRule    TokenRule
+	regex        *regexp.Regexp
+}
+
+// CompileRule validates the rule and prepares its pattern for matching.
+func CompileRule(rule model.Rule) (CompiledRule, error) {
+	compiled := CompiledRule{
+		Id:           rule.ID,
+		Name:         rule.Name,
+		PatternType:  strings.ToLower(rule.PatternType),
+		Pattern:      rule.Pattern,
+		Severity:     strings.ToLower(rule.Severity),
+		SQLState:     strings.ToUpper(rule.SQLState),
+		ErrorMessage: rule.ErrorMessage,
+	}
+
+	if strings.TrimSpace(rule.Pattern) == "" {
+		return compiled, fmt.Errorf("rule pattern can't be empty")
+	}
+
+	switch compiled.Severity {
+	case LowSeverity, MediumSeverity, HighSeverity, CriticalSeverity:
+	default:
+		return compiled, fmt.Errorf("unknown rule severity: %s", rule.Severity)
+	}
+
+	if compiled.SQLState != "" && !sqlStatePattern.MatchString(compiled.SQLState) {
+		return compiled, fmt.Errorf("invalid SQLSTATE: %s", rule.SQLState)
+	}
+
+	switch compiled.PatternType {
+	case SubstringPattern:
+		compiled.Pattern = strings.ToLower(rule.Pattern)
+	case RegexPattern:
+		regex, err := regexp.Compile(rule.Pattern)
+		if err != nil {
+			return compiled, fmt.Errorf("invalid regular expression: %v", err)
+		}
+		compiled.regex = regex
+	case TokenPattern:
+		compiled.tokenRule = NewTokenRule(rule.Pattern)
+	default:
+		return compiled, fmt.Errorf("unknown rule pattern type: %s", rule.PatternType)
+	}
+
+	return compiled, nil
+}
+
+// Match checks the rule against a query and returns offsets of the matched
+// fragment in its normalized text.
+func (r CompiledRule) Match(query NormalizedQuery) (int, int, bool) {
+	switch r.PatternType {
+	case SubstringPattern:
+		start := strings.Index(strings.ToLower(query.Text), r.Pattern)
+		if start < 0 {
+			return 0, 0, false
+		}
+		return start, start + len(r.Pattern), true
+	case RegexPattern:
+		location := r.regex.FindStringIndex(query.Text)
+		if location == nil {
+			return 0, 0, false
+		}
+		return location[0], location[1], true
+	case TokenPattern:
+		if first, last, matched := r.tokenRule.Match(query.Tokens); matched {
+			start, end := query.Span(first, last)
+			return start, end, true
+		}
+		// code embedded in strings is reported as the strings carrying it
+		for _, embedded := range query.Embedded {
+			if _, _, matched := r.Match(embedded.Query); matched {
+				start, end := query.Span(embedded.First, embedded.Last)
+				return start, end, true
+			}
+		}
+	}
+
+	return 0, 0, false
+}
+
+// RuleSet holds the enabled rules shared by every running proxy. It's replaced
+// as a whole whenever rules are changed, so that sessions never see a partial
+// update.
+type RuleSet struct {
+	lock  sync.RWMutex
+	rules []CompiledRule
+}
+
+func NewRuleSet() *RuleSet {
+	return &RuleSet{}
+}
+
+// NewDefaultRuleSet creates a rule set with the built-in rules.
+func NewDefaultRuleSet() *RuleSet {
+	ruleSet := NewRuleSet()
+	if err := ruleSet.Update(DefaultRules()); err != nil {
+		panic(err)
+	}
+	return ruleSet
+}
+
+// Update compiles enabled rules and replaces the current ones with them.
+func (rs *RuleSet) Update(rules []model.Rule) error {
+	compiled := make([]CompiledRule, 0, len(rules))
+	for _, rule := range rules {
+		if !rule.Enabled {
+			continue
+		}
+		compiledRule, err := CompileRule(rule)
+		if err != nil {
+			return fmt.Errorf("rule '%s': %v", rule.Name, err)
+		}
+		compiled = append(compiled, compiledRule)
+	}
+
+	rs.lock.Lock()
+	rs.rules = compiled
+	rs.lock.Unlock()
+	return nil
+}
+
+func (rs *RuleSet) Rules() []CompiledRule {
+	rs.lock.RLock()
+	defer rs.lock.RUnlock()
+	return rs.rules
+}
+
+// DefaultRules returns the built-in rules, used to seed the rule store.
+func DefaultRules() []model.Rule {
+	newRule := func(name, pattern, severity, description string) model.Rule {
+		return model.Rule{
+			Name:        name,
+			PatternType: TokenPattern,
+			Pattern:     pattern,
+			Severity:    severity,
+			Enabled:     true,
+			Description: description,
+		}
+	}
+
+	return []model.Rule{
+		newRule("union-all-select", "union all select", HighSeverity, "UNION based injection"),
+		newRule("union-select", "union select", HighSeverity, "UNION based injection"),
+		newRule("pg-read-file", "pg_read_file (", CriticalSeverity, "reading server files"),
+		newRule("pg-read-binary-file", "pg_read_binary_file (", CriticalSeverity, "reading server files"),
+		newRule("pg-ls-dir", "pg_ls_dir (", HighSeverity, "listing server directories"),
+		newRule("copy-file-store", "copy file_store", HighSeverity, "copying data into a file store table"),
+		newRule("copy-from-program", "copy <any> from program", CriticalSeverity, "executing shell commands"),
+		newRule("copy-to-program", "copy <any> to program", CriticalSeverity, "executing shell commands"),
+		newRule("plperlu-function", "language plperlu", CriticalSeverity, "creating untrusted perl functions"),
+		newRule("version", "version ( )", LowSeverity, "database fingerprinting"),
+		newRule("pg-sleep", "pg_sleep (", MediumSeverity, "time based blind injection"),
+		newRule("current-user", "select current_user", LowSeverity, "user enumeration"),
+		newRule("session-user", "select session_user", LowSeverity, "user enumeration"),
+		newRule("getpgusername", "getpgusername ( )", LowSeverity, "user enumeration"),
+		newRule("null-comment", "null <comment>", MediumSeverity, "query truncated with a comment"),
+		newRule("chr", "chr (", LowSeverity, "character encoding used to evade filters"),
+		newRule("ascii", "ascii (", LowSeverity, "boolean based blind injection"),
+		newRule("or-tautology", "or <literal> = <literal>", HighSeverity, "tautology based injection"),
+	}
+}
@@ -0,0 +1,147 @@
+package detection
+
+import (
+	"strings"
+)
+
+const (
+	anyPlaceholder     = "<any>"
+	stringPlaceholder  = "<string>"
+	numberPlaceholder  = "<number>"
+	literalPlaceholder = "<literal>"
+	commentPlaceholder = "<comment>"
+)
+
+// TokenRule matches a contiguous sequence of tokens. The pattern is written as
+// SQL, e.g. "union all select", and can contain placeholders: <any>, <string>,
+// <number>, <literal> (a string or a number) and <comment>. Comments in the
+// query are skipped unless the pattern asks for them explicitly.
+type TokenRule struct {
+	Pattern  string
+	elements []patternElement
+}
+
+type patternElement struct {
+	placeholder string
+	token       Token
+}
+
+func NewTokenRule(pattern string) TokenRule {
+	var elements []patternElement
+	for _, field := range strings.Fields(pattern) {
+		if strings.HasPrefix(field, "<") && strings.HasSuffix(field, ">") && len(field) > 2 {
+			elements = append(elements, patternElement{placeholder: strings.ToLower(field)})
+			continue
+		}
+		for _, token := range Tokenize(field) {
+			elements = append(elements, patternElement{token: token})
+		}
+	}
+	return TokenRule{Pattern: pattern, elements: elements}
+}
+
+// Match returns indexes of the first and the last token of the first fragment
+// of the query matched by the rule.
+func (r TokenRule) Match(tokens []Token) (int, int, bool) {
+	if len(r.elements) == 0 {
+		return 0, 0, false
+	}
+
+	for i := range tokens {
+		if tokens[i].Kind == CommentToken && r.elements[0].placeholder != commentPlaceholder {
+			continue
+		}
+
+		position := i
+		matched := true
+		for _, element := range r.elements {
+			if element.placeholder != commentPlaceholder {
+				for position < len(tokens) && tokens[position].Kind == CommentToken {
+					position++
+				}
+			}
+			if position >= len(tokens) || !element.matches(tokens[position]) {
+				matched = false
+				break
+			}
+			position++
+		}
+
+		if matched {
+			return i, position - 1, true
+		}
+	}
+
+	return 0, 0, false
+}
+
+func (e patternElement) matches(token Token) bool {
+	switch e.placeholder {
+	case "":
+	case anyPlaceholder:
+		return true
+	case stringPlaceholder:
+		return token.Kind == StringToken
+	case numberPlaceholder:
+		return token.Kind == NumberToken
+	case literalPlaceholder:
+		return token.Kind == StringToken || token.Kind == NumberToken
+	case commentPlaceholder:
+		return token.Kind == CommentToken
+	default:
+		return false
+	}
+
+	// a word in the pattern matches the same name written as a quoted identifier
+	if e.token.Kind == WordToken {
+		return (token.Kind == WordToken || token.Kind == QuotedIdentifierToken) && token.Value == e.token.Value
+	}
+	return token.Kind == e.token.Kind && token.Value == e.token.Value
+}
+
+// TokenDetector matches rules against the token stream of a query, so that
+// comments, whitespace, letter case and quoting can't be used to evade them.
+// Rules are read from a shared rule set on every query, so changes to rules
+// apply to running sessions immediately.
+type TokenDetector struct {
+	ruleSet *RuleSet
+}
+
+func NewTokenDetector(ruleSet *RuleSet) TokenDetector {
+	return TokenDetector{ruleSet: ruleSet}
+}
+
+func (d TokenDetector) GetMaliciousQueries() []string {
+	rules := d.ruleSet.Rules()
+	patterns := make([]string, 0, len(rules))
+	for _, rule := range rules {
+		patterns = append(patterns, rule.Pattern)
+	}
+	return patterns
+}
+
+func (d TokenDetector) DetectMaliciousContent(payload []byte, ctx QueryContext) Verdict {
+	query := NewNormalizedQuery(strings.TrimRight(string(payload), "\x00"))
+	log.Debug("inspecting query", "user", ctx.User, "database", ctx.Database, "query", query.Text)
+
+	var matches []RuleMatch
+	for _, rule := range d.ruleSet.Rules() {
+		start, end, matched := rule.Match(query)
+		if !matched {
+			continue
+		}
+		log.Debug("query matched rule", "rule", rule.Name)
+		matches = append(matches, RuleMatch{
+			RuleId:   rule.Id,
+			RuleName: rule.Name,
+			Severity: rule.Severity,
+			Start:    start,
+			End:      end,
+			Fragment: query.Text[start:end],
+			SQLState: rule.SQLState,
+			Message:  rule.ErrorMessage,
+		})
+	}
+
+	return NewVerdict(query.Text, matches)
+}
@@ -0,0 +1,79 @@
+package detection
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestTokenDetectorEmbeddedCode(t *testing.T) {
+	detector := NewTokenDetector(NewDefaultRuleSet())
+
+	tests := []struct {
+		name         string
+		query        string
+		wantRule     string
+		wantFragment string
+	}{
+		{
+			name:         "DO block body",
+			query:        "DO $$BEGIN PERFORM pg_sleep(10); END$$",
+			wantRule:     "pg-sleep",
+			wantFragment: "'BEGIN PERFORM pg_sleep(10); END'",
+		},
+		{
+			name:     "tagged DO block body",
+			query:    "do language plpgsql $body$ begin perform PG_SLEEP (10); end $body$;",
+			wantRule: "pg-sleep",
+		},
+		{
+			name:     "DO block in a plain string",
+			query:    "DO 'BEGIN PERFORM pg_sleep(10); END'",
+			wantRule: "pg-sleep",
+		},
+		{
+			name:     "EXECUTE in a DO block",
+			query:    "DO $$BEGIN EXECUTE 'SELECT pg_read_file(''/etc/passwd'')'; END$$",
+			wantRule: "pg-read-file",
+		},
+		{
+			name:         "EXECUTE of concatenated strings",
+			query:        "EXECUTE 'SELECT pg_' || 'sleep(10)'",
+			wantRule:     "pg-sleep",
+			wantFragment: "'SELECT pg_' || 'sleep(10)'",
+		},
+		{
+			name:     "function body",
+			query:    "CREATE FUNCTION f() RETURNS void AS $f$ SELECT 1 UNION ALL SELECT 2 $f$ LANGUAGE sql",
+			wantRule: "union-all-select",
+		},
+		{
+			name:  "plain string value",
+			query: "SELECT 'pg_sleep(10)'",
+		},
+		{
+			name:  "string of ON CONFLICT DO UPDATE",
+			query: "INSERT INTO t VALUES (1) ON CONFLICT DO UPDATE SET note = 'pg_sleep(10)'",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			verdict := detector.DetectMaliciousContent([]byte(tt.query), QueryContext{})
+			if tt.wantRule == "" {
+				if verdict.IsMalicious() {
+					t.Fatalf("flagged by %v", verdict.RuleNames())
+				}
+				return
+			}
+			if !verdict.IsMalicious() {
+				t.Fatal("not flagged")
+			}
+			if names := strings.Join(verdict.RuleNames(), ","); !strings.Contains(names, tt.wantRule) {
+				t.Errorf("matched rules %s, want %s", names, tt.wantRule)
+			}
+			if tt.wantFragment != "" && verdict.Matches[0].Fragment != tt.wantFragment {
+				t.Errorf("fragment %q, want %q", verdict.Matches[0].Fragment, tt.wantFragment)
+			}
+		})
+	}
+}
@@ -0,0 +1,280 @@
+package detection
+
+import (
+	"strings"
+	"unicode"
+	"unicode/utf8"
+)
+
+const (
+	WordToken = iota
+	QuotedIdentifierToken
+	StringToken
+	NumberToken
+	ParameterToken
+	OperatorToken
+	PunctuationToken
+	CommentToken
+)
+
+// Token is a single lexical element of a SQL query. Value of words is lower
+// cased and value of strings and quoted identifiers is unescaped, so the same
+// query written in different ways produces the same tokens.
+type Token struct {
+	Kind  int
+	Value string
+	Start int
+	End   int
+}
+
+const operatorCharacters = "+-*/<>=~!@#%^&|`?"
+
+// Tokenize splits PostgreSQL SQL into tokens. Whitespace is dropped, comments
+// are kept as separate tokens.
+func Tokenize(query string) []Token {
+	t := tokenizer{query: query}
+	for t.pos < len(t.query) {
+		t.next()
+	}
+	return t.tokens
+}
+
+type tokenizer struct {
+	query  string
+	pos    int
+	tokens []Token
+}
+
+func (t *tokenizer) next() {
+	c := t.query[t.pos]
+	start := t.pos
+
+	switch {
+	case c == ' ' || c == '\t' || c == '\n' || c == '\r' || c == '\f' || c == '\v':
+		t.pos++
+	case strings.HasPrefix(t.query[t.pos:], "--"):
+		end := strings.IndexByte(t.query[t.pos:], '\n')
+		if end < 0 {
+			t.pos = len(t.query)
+		} else {
+			t.pos += end
+		}
+		t.emit(CommentToken, t.query[start:t.pos], start)
+	case strings.HasPrefix(t.query[t.pos:], "/*"):
+		t.readBlockComment()
+		t.emit(CommentToken, t.query[start:t.pos], start)
+	case c == '\'':
+		t.emit(StringToken, t.readQuoted('\'', false), start)
+	case c == '"':
+		t.emit(QuotedIdentifierToken, t.readQuoted('"', false), start)
+	case (c == 'e' || c == 'E') && t.peek(1) == '\'':
+		t.pos++
+		t.emit(StringToken, t.readQuoted('\'', true), start)
+	case (c == 'b' || c == 'B' || c == 'x' || c == 'X' || c == 'n' || c == 'N') && t.peek(1) == '\'':
+		t.pos++
+		t.emit(StringToken, t.readQuoted('\'', false), start)
+	case (c == 'u' || c == 'U') && t.peek(1) == '&' && (t.peek(2) == '\'' || t.peek(2) == '"'):
+		t.pos += 2
+		if t.query[t.pos] == '\'' {
+			t.emit(StringToken, t.readQuoted('\'', false), start)
+		} else {
+			t.emit(QuotedIdentifierToken, t.readQuoted('"', false), start)
+		}
+	case c == '$':
+		t.readDollar()
+	case isDigit(c) || (c == '.' && isDigit(t.peek(1))):
+		t.readNumber(start)
+		t.emit(NumberToken, t.query[start:t.pos], start)
+	case isWordStart(t.query[t.pos:]):
+		t.readWord()
+		t.emit(WordToken, strings.ToLower(t.query[start:t.pos]), start)
+	case strings.IndexByte(operatorCharacters, c) >= 0 || c == ':':
+		t.readOperator()
+		t.emit(OperatorToken, t.query[start:t.pos], start)
+	default:
+		_, size := utf8.DecodeRuneInString(t.query[t.pos:])
+		t.pos += size
+		t.emit(PunctuationToken, t.query[start:t.pos], start)
+	}
+}
+
+func (t *tokenizer) emit(kind int, value string, start int) {
+	t.tokens = append(t.tokens, Token{Kind: kind, Value: value, Start: start, End: t.pos})
+}
+
+func (t *tokenizer) peek(offset int) byte {
+	if t.pos+offset < len(t.query) {
+		return t.query[t.pos+offset]
+	}
+	return 0
+}
+
+// readBlockComment skips a block comment, which can be nested in PostgreSQL.
+func (t *tokenizer) readBlockComment() {
+	depth := 0
+	for t.pos < len(t.query) {
+		if strings.HasPrefix(t.query[t.pos:], "/*") {
+			depth++
+			t.pos += 2
+		} else if strings.HasPrefix(t.query[t.pos:], "*/") {
+			depth--
+			t.pos += 2
+			if depth == 0 {
+				return
+			}
+		} else {
+			t.pos++
+		}
+	}
+}
+
+// readQuoted reads a quoted string or identifier, with the quote doubled to
+// escape it. Backslash escapes are honored in escape strings (E'...').
+func (t *tokenizer) readQuoted(quote byte, backslashEscapes bool) string {
+	var value strings.Builder
+	t.pos++
+	for t.pos < len(t.query) {
+		c := t.query[t.pos]
+		if backslashEscapes && c == '\\' && t.pos+1 < len(t.query) {
+			value.WriteByte(unescape(t.query[t.pos+1]))
+			t.pos += 2
+			continue
+		}
+		if c == quote {
+			if t.peek(1) == quote {
+				value.WriteByte(quote)
+				t.pos += 2
+				continue
+			}
+			t.pos++
+			return value.String()
+		}
+		value.WriteByte(c)
+		t.pos++
+	}
+	return value.String()
+}
+
+// readDollar reads either a positional parameter ($1) or a dollar-quoted
+// string ($$...$$ or $tag$...$tag$).
+func (t *tokenizer) readDollar() {
+	start := t.pos
+	if isDigit(t.peek(1)) {
+		t.pos++
+		for t.pos < len(t.query) && isDigit(t.query[t.pos]) {
+			t.pos++
+		}
+		t.emit(ParameterToken, t.query[start:t.pos], start)
+		return
+	}
+
+	end := t.pos + 1
+	for end < len(t.query) && isTagCharacter(t.query[end]) {
+		end++
+	}
+	if end >= len(t.query) || t.query[end] != '$' {
+		t.pos++
+		t.emit(OperatorToken, "$", start)
+		return
+	}
+
+	tag := t.query[start : end+1]
+	bodyEnd := strings.Index(t.query[end+1:], tag)
+	if bodyEnd < 0 {
+		t.pos = len(t.query)
+		t.emit(StringToken, t.query[end+1:], start)
+		return
+	}
+	t.pos = end + 1 + bodyEnd + len(tag)
+	t.emit(StringToken, t.query[end+1:end+1+bodyEnd], start)
+}
+
+func (t *tokenizer) readNumber(start int) {
+	hexadecimal := false
+	for t.pos < len(t.query) {
+		c := t.query[t.pos]
+		if isDigit(c) || c == '.' || c == '_' {
+			t.pos++
+		} else if (c == 'e' || c == 'E') && (isDigit(t.peek(1)) || ((t.peek(1) == '+' || t.peek(1) == '-') && isDigit(t.peek(2)))) {
+			t.pos += 2
+		} else if (c == 'x' || c == 'X' || c == 'o' || c == 'O' || c == 'b' || c == 'B') && t.pos == start+1 && t.query[start] == '0' {
+			hexadecimal = c == 'x' || c == 'X'
+			t.pos++
+		} else if hexadecimal && isHexDigit(c) {
+			t.pos++
+		} else {
+			return
+		}
+	}
+}
+
+func (t *tokenizer) readWord() {
+	for t.pos < len(t.query) {
+		r, size := utf8.DecodeRuneInString(t.query[t.pos:])
+		if r != '_' && r != '$' && !unicode.IsLetter(r) && !unicode.IsDigit(r) {
+			return
+		}
+		t.pos += size
+	}
+}
+
+// readOperator reads a run of operator characters, stopping before a comment
+// start so that "1=1--" is not read as a single "=--" operator.
+func (t *tokenizer) readOperator() {
+	if t.query[t.pos] == ':' {
+		t.pos++
+		if t.peek(0) == ':' || t.peek(0) == '=' {
+			t.pos++
+		}
+		return
+	}
+	start := t.pos
+	t.pos++
+	for t.pos < len(t.query) && strings.IndexByte(operatorCharacters, t.query[t.pos]) >= 0 {
+		if strings.HasPrefix(t.query[t.pos:], "--") || strings.HasPrefix(t.query[t.pos:], "/*") {
+			break
+		}
+		t.pos++
+	}
+
+	// multi-character operators can't end with + or - unless they contain one
+	// of the characters below, so "=-1" is read as "=" followed by "-1"
+	if !strings.ContainsAny(t.query[start:t.pos], "~!@#%^&|`?") {
+		for t.pos-start > 1 && (t.query[t.pos-1] == '+' || t.query[t.pos-1] == '-') {
+			t.pos--
+		}
+	}
+}
+
+func isDigit(c byte) bool {
+	return c >= '0' && c <= '9'
+}
+
+func isHexDigit(c byte) bool {
+	return isDigit(c) || (c >= 'a' && c <= 'f') || (c >= 'A' && c <= 'F')
+}
+
+func isTagCharacter(c byte) bool {
+	return c == '_' || isDigit(c) || (c >= 'a' && c <= 'z') || (c >= 'A' && c <= 'Z') || c >= 0x80
+}
+
+func isWordStart(s string) bool {
+	r, _ := utf8.DecodeRuneInString(s)
+	return r == '_' || unicode.IsLetter(r)
+}
+
+func unescape(c byte) byte {
+	switch c {
+	case 'n':
+		return '\n'
+	case 't':
+		return '\t'
+	case 'r':
+		return '\r'
+	case 'b':
+		return '\b'
+	case 'f':
+		return '\f'
+	}
+	return c
+}
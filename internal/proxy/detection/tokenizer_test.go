@@ -0,0 +1,135 @@
+package detection
+
+import (
+	"reflect"
+	"testing"
+)
+
+// kindValue is a token without its position.
+type kindValue struct {
+	kind  int
+	value string
+}
+
+func kindValues(tokens []Token) []kindValue {
+	values := make([]kindValue, 0, len(tokens))
+	for _, token := range tokens {
+		values = append(values, kindValue{token.Kind, token.Value})
+	}
+	return values
+}
+
+func TestTokenize(t *testing.T) {
+	tests := []struct {
+		name  string
+		query string
+		want  []kindValue
+	}{
+		{
+			name:  "words are lower cased",
+			query: "SELECT Name FROM users",
+			want:  []kindValue{{WordToken, "select"}, {WordToken, "name"}, {WordToken, "from"}, {WordToken, "users"}},
+		},
+		{
+			name:  "strings are unescaped",
+			query: `'it''s' E'a\'b\n' U&'x' $$dollar$$ $tag$a$$b$tag$`,
+			want:  []kindValue{{StringToken, "it's"}, {StringToken, "a'b\n"}, {StringToken, "x"}, {StringToken, "dollar"}, {StringToken, "a$$b"}},
+		},
+		{
+			name:  "quoted identifiers keep their case",
+			query: `"User ""Name"""`,
+			want:  []kindValue{{QuotedIdentifierToken, `User "Name"`}},
+		},
+		{
+			name:  "numbers and parameters",
+			query: "1 2.5 .5 1e-3 0x1F $1",
+			want:  []kindValue{{NumberToken, "1"}, {NumberToken, "2.5"}, {NumberToken, ".5"}, {NumberToken, "1e-3"}, {NumberToken, "0x1F"}, {ParameterToken, "$1"}},
+		},
+		{
+			name:  "comments",
+			query: "a/* x /* nested */ y */b -- rest\nc",
+			want:  []kindValue{{WordToken, "a"}, {CommentToken, "/* x /* nested */ y */"}, {WordToken, "b"}, {CommentToken, "-- rest"}, {WordToken, "c"}},
+		},
+		{
+			name:  "operators stop before comments",
+			query: "1=1--",
+			want:  []kindValue{{NumberToken, "1"}, {OperatorToken, "="}, {NumberToken, "1"}, {CommentToken, "--"}},
+		},
+		{
+			name:  "operators don't swallow signs",
+			query: "a=-1 b::int c<>d",
+			want: []kindValue{{WordToken, "a"}, {OperatorToken, "="}, {OperatorToken, "-"}, {NumberToken, "1"},
+				{WordToken, "b"}, {OperatorToken, "::"}, {WordToken, "int"}, {WordToken, "c"}, {OperatorToken, "<>"}, {WordToken, "d"}},
+		},
+		{
+			name:  "punctuation",
+			query: "f(a, b);",
+			want: []kindValue{{WordToken, "f"}, {PunctuationToken, "("}, {WordToken, "a"}, {PunctuationToken, ","},
+				{WordToken, "b"}, {PunctuationToken, ")"}, {PunctuationToken, ";"}},
+		},
+		{
+			name:  "unterminated string",
+			query: "'abc",
+			want:  []kindValue{{StringToken, "abc"}},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := kindValues(Tokenize(tt.query)); !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("got %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestTokenizePositions(t *testing.T) {
+	query := "select 'a' /* c */ from t"
+	for _, token := range Tokenize(query) {
+		if token.Start < 0 || token.End > len(query) || token.Start >= token.End {
+			t.Fatalf("invalid span of %+v", token)
+		}
+	}
+	tokens := Tokenize(query)
+	if got := query[tokens[1].Start:tokens[1].End]; got != "'a'" {
+		t.Errorf("got %q for the string token", got)
+	}
+}
+
+func TestTokenRuleMatch(t *testing.T) {
+	tests := []struct {
+		name      string
+		pattern   string
+		query     string
+		wantMatch bool
+		wantFirst int
+		wantLast  int
+	}{
+		{name: "exact", pattern: "union all select", query: "select 1 union all select 2", wantMatch: true, wantFirst: 2, wantLast: 4},
+		{name: "case and whitespace", pattern: "union all select", query: "SELECT 1 UNION\n\tALL  Select 2", wantMatch: true, wantFirst: 2, wantLast: 4},
+		{name: "comments between tokens", pattern: "union all select", query: "select 1 union/**/all/* x */select 2", wantMatch: true, wantFirst: 2, wantLast: 6},
+		{name: "quoted identifier", pattern: "pg_sleep (", query: `select "pg_sleep"(5)`, wantMatch: true, wantFirst: 1, wantLast: 2},
+		{name: "no match", pattern: "union all select", query: "select 'union all select'"},
+		{name: "literal placeholder", pattern: "or <literal> = <literal>", query: "where a = 1 or 'x' = 'x'", wantMatch: true, wantFirst: 4, wantLast: 7},
+		{name: "literal placeholder rejects words", pattern: "or <literal> = <literal>", query: "where a = 1 or b = c"},
+		{name: "number placeholder", pattern: "<number> = <number>", query: "1 = '1'"},
+		{name: "string placeholder", pattern: "<string> = <string>", query: "'1' = '1'", wantMatch: true, wantFirst: 0, wantLast: 2},
+		{name: "any placeholder", pattern: "copy <any> from program", query: "COPY t FROM PROGRAM 'id'", wantMatch: true, wantFirst: 0, wantLast: 3},
+		{name: "comment placeholder", pattern: "null <comment>", query: "select null-- x", wantMatch: true, wantFirst: 1, wantLast: 2},
+		{name: "comment placeholder needs a comment", pattern: "null <comment>", query: "select null from t"},
+		{name: "empty pattern", pattern: "", query: "select 1"},
+		{name: "pattern longer than query", pattern: "select 1 from", query: "select 1"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			first, last, matched := NewTokenRule(tt.pattern).Match(Tokenize(tt.query))
+			if matched != tt.wantMatch {
+				t.Fatalf("matched = %t, want %t", matched, tt.wantMatch)
+			}
+			if matched && (first != tt.wantFirst || last != tt.wantLast) {
+				t.Errorf("matched tokens %d-%d, want %d-%d", first, last, tt.wantFirst, tt.wantLast)
+			}
+		})
+	}
+}
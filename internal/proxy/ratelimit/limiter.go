@@ -0,0 +1,191 @@
+package ratelimit
+
+import (
+	"fmt"
+	"math"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+const (
+	RejectAction = "reject"
+	DropAction   = "drop"
+	DelayAction  = "delay"
+
+	DefaultMaxDelay = time.Second
+
+	// idle buckets are forgotten, a new bucket starts full anyway
+	pruneInterval = time.Minute
+)
+
+// Config of a limiter. Rate is the number of requests per second refilled to
+// every bucket and Burst is the size of a bucket.
+type Config struct {
+	Rate     float64
+	Burst    int
+	Action   string
+	MaxDelay time.Duration
+}
+
+// NewConfig validates the rate limiting settings of a proxy.
+func NewConfig(rate float64, burst int, action, maxDelay string) (Config, error) {
+	config := Config{Rate: rate, Burst: burst, Action: strings.ToLower(action), MaxDelay: DefaultMaxDelay}
+	if rate < 0 || burst < 0 {
+		return config, fmt.Errorf("rate limit can't be negative")
+	}
+	if config.Burst == 0 {
+		config.Burst = int(math.Max(1, math.Ceil(rate)))
+	}
+
+	switch config.Action {
+	case "":
+		config.Action = RejectAction
+	case RejectAction, DropAction, DelayAction:
+	default:
+		return config, fmt.Errorf("unknown rate limit action: %s", action)
+	}
+
+	if maxDelay != "" {
+		delay, err := time.ParseDuration(maxDelay)
+		if err != nil {
+			return config, fmt.Errorf("invalid rate limit delay: %v", err)
+		}
+		if delay < 0 {
+			return config, fmt.Errorf("rate limit delay can't be negative")
+		}
+		config.MaxDelay = delay
+	}
+
+	return config, nil
+}
+
+func (c Config) Enabled() bool {
+	return c.Rate > 0
+}
+
+// Stats counts decisions of a limiter.
+type Stats struct {
+	Allowed  uint64
+	Rejected uint64
+	Dropped  uint64
+	Delayed  uint64
+}
+
+type bucket struct {
+	tokens  float64
+	updated time.Time
+}
+
+// Limiter is a token bucket limiter keeping a bucket for every key. A request
+// can be charged to several keys at once, e.g. client address and db user, and
+// is allowed only when all of their buckets have enough tokens.
+type Limiter struct {
+	// counters go first to keep them aligned for atomic access
+	allowed  uint64
+	rejected uint64
+	dropped  uint64
+	delayed  uint64
+
+	config    Config
+	buckets   map[string]*bucket
+	lastPrune time.Time
+	lock      sync.Mutex
+}
+
+func NewLimiter(config Config) *Limiter {
+	return &Limiter{config: config, buckets: make(map[string]*bucket), lastPrune: time.Now()}
+}
+
+func (l *Limiter) Config() Config {
+	return l.config
+}
+
+// Reserve charges cost tokens to every key. It returns zero when the request
+// is allowed right away, otherwise the time after which it would be. Tokens are
+// taken only if the request is allowed, or if it's going to be delayed by no
+// more than the configured maximum. A request costs at most a full bucket, so
+// that batches of more requests than the burst can pass at all.
+func (l *Limiter) Reserve(cost int, keys ...string) time.Duration {
+	l.lock.Lock()
+	defer l.lock.Unlock()
+
+	if cost > l.config.Burst {
+		cost = l.config.Burst
+	}
+
+	now := time.Now()
+	l.prune(now)
+
+	var wait time.Duration
+	buckets := make([]*bucket, 0, len(keys))
+	for _, key := range keys {
+		b := l.refill(key, now)
+		buckets = append(buckets, b)
+		if missing := float64(cost) - b.tokens; missing > 0 {
+			keyWait := time.Duration(missing / l.config.Rate * float64(time.Second))
+			if keyWait > wait {
+				wait = keyWait
+			}
+		}
+	}
+
+	if wait == 0 || (l.config.Action == DelayAction && wait <= l.config.MaxDelay) {
+		for _, b := range buckets {
+			b.tokens -= float64(cost)
+		}
+	}
+	return wait
+}
+
+func (l *Limiter) refill(key string, now time.Time) *bucket {
+	b, present := l.buckets[key]
+	if !present {
+		b = &bucket{tokens: float64(l.config.Burst), updated: now}
+		l.buckets[key] = b
+		return b
+	}
+
+	b.tokens = math.Min(float64(l.config.Burst), b.tokens+now.Sub(b.updated).Seconds()*l.config.Rate)
+	b.updated = now
+	return b
+}
+
+func (l *Limiter) prune(now time.Time) {
+	if now.Sub(l.lastPrune) < pruneInterval {
+		return
+	}
+	l.lastPrune = now
+
+	for key, b := range l.buckets {
+		if b.tokens+now.Sub(b.updated).Seconds()*l.config.Rate >= float64(l.config.Burst) {
+			delete(l.buckets, key)
+		}
+	}
+}
+
+func (l *Limiter) RecordAllowed() {
+	atomic.AddUint64(&l.allowed, 1)
+}
+
+func (l *Limiter) RecordRejected() {
+	atomic.AddUint64(&l.rejected, 1)
+}
+
+func (l *Limiter) RecordDropped() {
+	atomic.AddUint64(&l.dropped, 1)
+}
+
+func (l *Limiter) RecordDelayed() {
+	atomic.AddUint64(&l.delayed, 1)
+}
+
+func (l *Limiter) Stats() Stats {
+	return Stats{
+		Allowed:  atomic.LoadUint64(&l.allowed),
+		Rejected: atomic.LoadUint64(&l.rejected),
+		Dropped:  atomic.LoadUint64(&l.dropped),
+		Delayed:  atomic.LoadUint64(&l.delayed),
+	}
+}
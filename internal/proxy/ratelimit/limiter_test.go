@@ -0,0 +1,127 @@
+package ratelimit
+
+import (
+	"testing"
+	"time"
+)
+
+// tolerance covers tokens refilled while a test runs
+const tolerance = 50 * time.Millisecond
+
+func TestReserve(t *testing.T) {
+	type reservation struct {
+		cost int
+		keys []string
+		want time.Duration
+	}
+	tests := []struct {
+		name         string
+		config       Config
+		reservations []reservation
+	}{
+		{
+			name:   "within burst",
+			config: Config{Rate: 1, Burst: 3, Action: RejectAction},
+			reservations: []reservation{
+				{cost: 1, keys: []string{"a"}},
+				{cost: 2, keys: []string{"a"}},
+				{cost: 1, keys: []string{"a"}, want: time.Second},
+			},
+		},
+		{
+			name:   "rejected requests take no tokens",
+			config: Config{Rate: 1, Burst: 2, Action: RejectAction},
+			reservations: []reservation{
+				{cost: 2, keys: []string{"a"}},
+				{cost: 1, keys: []string{"a"}, want: time.Second},
+				{cost: 2, keys: []string{"a"}, want: 2 * time.Second},
+			},
+		},
+		{
+			name:   "cost above burst is capped",
+			config: Config{Rate: 1, Burst: 2, Action: RejectAction},
+			reservations: []reservation{
+				{cost: 5, keys: []string{"a"}},
+				{cost: 5, keys: []string{"a"}, want: 2 * time.Second},
+			},
+		},
+		{
+			name:   "delayed requests take tokens",
+			config: Config{Rate: 2, Burst: 1, Action: DelayAction, MaxDelay: time.Second},
+			reservations: []reservation{
+				{cost: 1, keys: []string{"a"}},
+				{cost: 1, keys: []string{"a"}, want: 500 * time.Millisecond},
+				{cost: 1, keys: []string{"a"}, want: time.Second},
+				{cost: 1, keys: []string{"a"}, want: 1500 * time.Millisecond},
+			},
+		},
+		{
+			name:   "delay never goes beyond a full bucket for large batches",
+			config: Config{Rate: 1, Burst: 2, Action: DelayAction, MaxDelay: 3 * time.Second},
+			reservations: []reservation{
+				{cost: 10, keys: []string{"a"}},
+				{cost: 10, keys: []string{"a"}, want: 2 * time.Second},
+			},
+		},
+		{
+			name:   "slowest of the keys",
+			config: Config{Rate: 1, Burst: 2, Action: RejectAction},
+			reservations: []reservation{
+				{cost: 2, keys: []string{"ip"}},
+				{cost: 1, keys: []string{"user"}},
+				{cost: 1, keys: []string{"ip", "user"}, want: time.Second},
+				{cost: 1, keys: []string{"user", "other"}},
+			},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			limiter := NewLimiter(tt.config)
+			for i, r := range tt.reservations {
+				got := limiter.Reserve(r.cost, r.keys...)
+				if got > r.want || got < r.want-tolerance {
+					t.Errorf("reservation %d: got wait %v, want %v", i, got, r.want)
+				}
+			}
+		})
+	}
+}
+
+func TestNewConfig(t *testing.T) {
+	tests := []struct {
+		name     string
+		rate     float64
+		burst    int
+		action   string
+		maxDelay string
+		want     Config
+		wantErr  bool
+	}{
+		{name: "defaults", rate: 2.5, want: Config{Rate: 2.5, Burst: 3, Action: RejectAction, MaxDelay: DefaultMaxDelay}},
+		{name: "disabled", want: Config{Burst: 1, Action: RejectAction, MaxDelay: DefaultMaxDelay}},
+		{name: "delay", rate: 10, burst: 20, action: "Delay", maxDelay: "250ms", want: Config{Rate: 10, Burst: 20, Action: DelayAction, MaxDelay: 250 * time.Millisecond}},
+		{name: "negative rate", rate: -1, wantErr: true},
+		{name: "unknown action", rate: 1, action: "sleep", wantErr: true},
+		{name: "invalid delay", rate: 1, action: DelayAction, maxDelay: "soon", wantErr: true},
+		{name: "negative delay", rate: 1, action: DelayAction, maxDelay: "-1s", wantErr: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := NewConfig(tt.rate, tt.burst, tt.action, tt.maxDelay)
+			if tt.wantErr {
+				if err == nil {
+					t.Fatalf("expected an error, got %+v", got)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+			if got != tt.want {
+				t.Errorf("got %+v, want %+v", got, tt.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,110 @@
+package relational
+
+import (
+	"encoding/binary"
+)
+
+const (
+	ErrorResponsePacket  = 0x45
+	NoticeResponsePacket = 0x4E
+)
+
+const (
+	TransactionIdle   = 'I'
+	TransactionBlock  = 'T'
+	TransactionFailed = 'E'
+)
+
+const (
+	FatalSeverity   = "FATAL"
+	ErrorSeverity   = "ERROR"
+	WarningSeverity = "WARNING"
+	NoticeSeverity  = "NOTICE"
+)
+
+const (
+	DefaultBlockedSQLState = "42000"
+	DefaultBlockedMessage  = "MALICIOUS ACTIVITY"
+	RateLimitSQLState      = "53400"
+	RateLimitMessage       = "rate limit exceeded"
+	AdminShutdownSQLState  = "57P01"
+	AdminShutdownMessage   = "terminating connection due to administrator command"
+)
+
+// ResponseFields are fields of ErrorResponse and NoticeResponse messages. Empty
+// optional fields are left out of the message.
+type ResponseFields struct {
+	Severity string
+	SQLState string
+	Message  string
+	Detail   string
+	Hint     string
+}
+
+// NewErrorResponse encodes an ErrorResponse message.
+func NewErrorResponse(fields ResponseFields) []byte {
+	if fields.Severity == "" {
+		fields.Severity = ErrorSeverity
+	}
+	return encodeResponse(ErrorResponsePacket, fields)
+}
+
+// NewNoticeResponse encodes a NoticeResponse message.
+func NewNoticeResponse(fields ResponseFields) []byte {
+	if fields.Severity == "" {
+		fields.Severity = NoticeSeverity
+	}
+	return encodeResponse(NoticeResponsePacket, fields)
+}
+
+// NewReadyForQuery encodes a ReadyForQuery message with the given transaction
+// status.
+func NewReadyForQuery(status byte) []byte {
+	return encodeMessage(ReadyForQueryPacket, []byte{status})
+}
+
+// responseMessage returns the message field of an ErrorResponse or
+// NoticeResponse payload.
+func responseMessage(payload []byte) string {
+	for len(payload) > 0 && payload[0] != 0 {
+		code := payload[0]
+		value, rest, err := readCString(payload[1:])
+		if err != nil {
+			return ""
+		}
+		if code == 'M' {
+			return value
+		}
+		payload = rest
+	}
+	return ""
+}
+
+func encodeResponse(packetType byte, fields ResponseFields) []byte {
+	var body []byte
+	body = appendField(body, 'S', fields.Severity)
+	// non-localized severity, understood by clients regardless of lc_messages
+	body = appendField(body, 'V', fields.Severity)
+	body = appendField(body, 'C', fields.SQLState)
+	body = appendField(body, 'M', fields.Message)
+	body = appendField(body, 'D', fields.Detail)
+	body = appendField(body, 'H', fields.Hint)
+	body = append(body, 0)
+	return encodeMessage(packetType, body)
+}
+
+func appendField(body []byte, code byte, value string) []byte {
+	if value == "" {
+		return body
+	}
+	body = append(body, code)
+	body = append(body, value...)
+	return append(body, 0)
+}
+
+func encodeMessage(packetType byte, body []byte) []byte {
+	message := make([]byte, headerLength, headerLength+len(body))
+	message[0] = packetType
+	binary.BigEndian.PutUint32(message[1:headerLength], uint32(lengthFieldSize+len(body)))
+	return append(message, body...)
+}
@@ -0,0 +1,84 @@
+package relational
+
+import (
+	"bytes"
+	"testing"
+)
+
+func TestNewErrorResponse(t *testing.T) {
+	tests := []struct {
+		name   string
+		fields ResponseFields
+		want   []byte
+	}{
+		{
+			name:   "default severity",
+			fields: ResponseFields{SQLState: "42000", Message: "blocked"},
+			want:   []byte("E\x00\x00\x00\x23SERROR\x00VERROR\x00C42000\x00Mblocked\x00\x00"),
+		},
+		{
+			name:   "all fields",
+			fields: ResponseFields{Severity: FatalSeverity, SQLState: "57P01", Message: "bye", Detail: "d", Hint: "h"},
+			want:   []byte("E\x00\x00\x00\x25SFATAL\x00VFATAL\x00C57P01\x00Mbye\x00Dd\x00Hh\x00\x00"),
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := NewErrorResponse(tt.fields); !bytes.Equal(got, tt.want) {
+				t.Errorf("got %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestNewNoticeResponse(t *testing.T) {
+	got := NewNoticeResponse(ResponseFields{Message: "note"})
+	want := []byte("N\x00\x00\x00\x1bSNOTICE\x00VNOTICE\x00Mnote\x00\x00")
+	if !bytes.Equal(got, want) {
+		t.Errorf("got %q, want %q", got, want)
+	}
+}
+
+func TestNewReadyForQuery(t *testing.T) {
+	for _, status := range []byte{TransactionIdle, TransactionBlock, TransactionFailed} {
+		got := NewReadyForQuery(status)
+		want := []byte{'Z', 0, 0, 0, 5, status}
+		if !bytes.Equal(got, want) {
+			t.Errorf("status %c: got %q, want %q", status, got, want)
+		}
+	}
+}
+
+func TestResponseMessage(t *testing.T) {
+	tests := []struct {
+		name    string
+		payload []byte
+		want    string
+	}{
+		{
+			name:    "encoded error",
+			payload: NewErrorResponse(ResponseFields{SQLState: "34000", Message: `portal "x" does not exist`})[headerLength:],
+			want:    `portal "x" does not exist`,
+		},
+		{
+			name:    "no message field",
+			payload: []byte("SERROR\x00C42601\x00\x00"),
+		},
+		{
+			name:    "unterminated field",
+			payload: []byte("SERROR\x00Mtrunc"),
+		},
+		{
+			name: "empty payload",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := responseMessage(tt.payload); got != tt.want {
+				t.Errorf("got %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,294 @@
+package relational
+
+import (
+	"encoding/binary"
+	"encoding/hex"
+	"fmt"
+	"math"
+	"strconv"
+	"strings"
+	"unicode/utf8"
+)
+
+const (
+	textFormat   = 0
+	binaryFormat = 1
+)
+
+// type OIDs of the parameter types which can be decoded from binary format
+const (
+	boolOid    = 16
+	byteaOid   = 17
+	nameOid    = 19
+	int8Oid    = 20
+	int2Oid    = 21
+	int4Oid    = 23
+	textOid    = 25
+	oidOid     = 26
+	jsonOid    = 114
+	float4Oid  = 700
+	float8Oid  = 701
+	bpcharOid  = 1042
+	varcharOid = 1043
+	uuidOid    = 2950
+	jsonbOid   = 3802
+)
+
+// PreparedStatement is a statement created with Parse, which can be bound to
+// parameter values later on.
+type PreparedStatement struct {
+	Name           string
+	Query          string
+	ParameterTypes []uint32
+}
+
+// BindMessage holds the parameter values bound to a prepared statement. A nil
+// value stands for NULL.
+type BindMessage struct {
+	Portal      string
+	Statement   string
+	FormatCodes []int16
+	Parameters  [][]byte
+}
+
+// CloseMessage describes a prepared statement ('S') or a portal ('P') to close.
+type CloseMessage struct {
+	Kind byte
+	Name string
+}
+
+func ParseParseMessage(payload []byte) (PreparedStatement, error) {
+	name, rest, err := readCString(payload)
+	if err != nil {
+		return PreparedStatement{}, err
+	}
+	query, rest, err := readCString(rest)
+	if err != nil {
+		return PreparedStatement{}, err
+	}
+
+	count, rest, err := readCount(rest, 4)
+	if err != nil {
+		return PreparedStatement{}, fmt.Errorf("malformed parse message: %v", err)
+	}
+	parameterTypes := make([]uint32, count)
+	for i := range parameterTypes {
+		parameterTypes[i] = binary.BigEndian.Uint32(rest[i*4:])
+	}
+
+	return PreparedStatement{Name: name, Query: query, ParameterTypes: parameterTypes}, nil
+}
+
+func ParseBindMessage(payload []byte) (BindMessage, error) {
+	portal, rest, err := readCString(payload)
+	if err != nil {
+		return BindMessage{}, err
+	}
+	statement, rest, err := readCString(rest)
+	if err != nil {
+		return BindMessage{}, err
+	}
+
+	formatsCount, rest, err := readCount(rest, 2)
+	if err != nil {
+		return BindMessage{}, fmt.Errorf("malformed bind message: %v", err)
+	}
+	formatCodes := make([]int16, formatsCount)
+	for i := range formatCodes {
+		formatCodes[i], rest, err = readInt16(rest)
+		if err != nil {
+			return BindMessage{}, err
+		}
+	}
+
+	// every parameter takes at least its length
+	parametersCount, rest, err := readCount(rest, 4)
+	if err != nil {
+		return BindMessage{}, fmt.Errorf("malformed bind message: %v", err)
+	}
+	parameters := make([][]byte, parametersCount)
+	for i := range parameters {
+		if len(rest) < 4 {
+			return BindMessage{}, fmt.Errorf("malformed bind message")
+		}
+		length := int32(binary.BigEndian.Uint32(rest))
+		rest = rest[4:]
+		if length < 0 {
+			continue
+		}
+		if len(rest) < int(length) {
+			return BindMessage{}, fmt.Errorf("malformed bind message")
+		}
+		parameters[i] = rest[:length]
+		rest = rest[length:]
+	}
+
+	return BindMessage{Portal: portal, Statement: statement, FormatCodes: formatCodes, Parameters: parameters}, nil
+}
+
+func ParseCloseMessage(payload []byte) (CloseMessage, error) {
+	if len(payload) < 1 {
+		return CloseMessage{}, fmt.Errorf("malformed close message")
+	}
+	name, _, err := readCString(payload[1:])
+	if err != nil {
+		return CloseMessage{}, err
+	}
+	return CloseMessage{Kind: payload[0], Name: name}, nil
+}
+
+// Format returns the format code of the parameter with given index. No codes
+// mean text format for all parameters and a single code applies to all of them.
+func (b BindMessage) Format(index int) int16 {
+	switch len(b.FormatCodes) {
+	case 0:
+		return textFormat
+	case 1:
+		return b.FormatCodes[0]
+	}
+	if index < len(b.FormatCodes) {
+		return b.FormatCodes[index]
+	}
+	return textFormat
+}
+
+// BindQuery returns the statement text with placeholders replaced by the bound
+// values. Values are substituted verbatim, without quoting, so payloads smuggled
+// through parameters are exposed to detection exactly as written by the client.
+func (ps PreparedStatement) BindQuery(bind BindMessage) string {
+	values := make([]string, len(bind.Parameters))
+	for i, parameter := range bind.Parameters {
+		var oid uint32
+		if i < len(ps.ParameterTypes) {
+			oid = ps.ParameterTypes[i]
+		}
+		values[i] = DecodeParameter(parameter, bind.Format(i), oid)
+	}
+
+	if ps.Query == "" {
+		return strings.Join(values, " ")
+	}
+	return substitutePlaceholders(ps.Query, values)
+}
+
+// DecodeParameter renders a bound parameter value as text.
+func DecodeParameter(value []byte, format int16, oid uint32) string {
+	if value == nil {
+		return "NULL"
+	}
+	if format == textFormat {
+		return string(value)
+	}
+
+	switch oid {
+	case boolOid:
+		if len(value) == 1 {
+			return strconv.FormatBool(value[0] != 0)
+		}
+	case int2Oid:
+		if len(value) == 2 {
+			return strconv.FormatInt(int64(int16(binary.BigEndian.Uint16(value))), 10)
+		}
+	case int4Oid:
+		if len(value) == 4 {
+			return strconv.FormatInt(int64(int32(binary.BigEndian.Uint32(value))), 10)
+		}
+	case oidOid:
+		if len(value) == 4 {
+			return strconv.FormatUint(uint64(binary.BigEndian.Uint32(value)), 10)
+		}
+	case int8Oid:
+		if len(value) == 8 {
+			return strconv.FormatInt(int64(binary.BigEndian.Uint64(value)), 10)
+		}
+	case float4Oid:
+		if len(value) == 4 {
+			return strconv.FormatFloat(float64(math.Float32frombits(binary.BigEndian.Uint32(value))), 'g', -1, 32)
+		}
+	case float8Oid:
+		if len(value) == 8 {
+			return strconv.FormatFloat(math.Float64frombits(binary.BigEndian.Uint64(value)), 'g', -1, 64)
+		}
+	case uuidOid:
+		if len(value) == 16 {
+			h := hex.EncodeToString(value)
+			return fmt.Sprintf("%s-%s-%s-%s-%s", h[:8], h[8:12], h[12:16], h[16:20], h[20:])
+		}
+	case jsonbOid:
+		// binary jsonb is the text representation preceded by a version byte
+		if len(value) > 0 && value[0] == 1 {
+			return string(value[1:])
+		}
+	case textOid, varcharOid, bpcharOid, nameOid, jsonOid:
+		return string(value)
+	case byteaOid:
+		return "\\x" + hex.EncodeToString(value)
+	}
+
+	if utf8.Valid(value) {
+		return string(value)
+	}
+	return "\\x" + hex.EncodeToString(value)
+}
+
+// substitutePlaceholders replaces $n placeholders which are not part of quoted
+// strings or identifiers.
+func substitutePlaceholders(query string, values []string) string {
+	var builder strings.Builder
+	var quote byte
+	for i := 0; i < len(query); i++ {
+		c := query[i]
+		if quote != 0 {
+			if c == quote {
+				quote = 0
+			}
+			builder.WriteByte(c)
+			continue
+		}
+		if c == '\'' || c == '"' {
+			quote = c
+			builder.WriteByte(c)
+			continue
+		}
+		if c == '$' {
+			end := i + 1
+			for end < len(query) && query[end] >= '0' && query[end] <= '9' {
+				end++
+			}
+			if end > i+1 {
+				index, err := strconv.Atoi(query[i+1 : end])
+				if err == nil && index >= 1 && index <= len(values) {
+					builder.WriteString(values[index-1])
+					i = end - 1
+					continue
+				}
+			}
+		}
+		builder.WriteByte(c)
+	}
+	return builder.String()
+}
+
+func readInt16(buff []byte) (int16, []byte, error) {
+	if len(buff) < 2 {
+		return 0, nil, fmt.Errorf("unexpected end of message")
+	}
+	return int16(binary.BigEndian.Uint16(buff)), buff[2:], nil
+}
+
+// readCount reads the count of the array which follows it. The count is
+// checked against the rest of the message, given the minimal size of an
+// element, before anything is allocated for the array.
+func readCount(buff []byte, elementSize int) (int, []byte, error) {
+	count, rest, err := readInt16(buff)
+	if err != nil {
+		return 0, nil, err
+	}
+	if count < 0 {
+		return 0, nil, fmt.Errorf("negative count %d", count)
+	}
+	if len(rest) < int(count)*elementSize {
+		return 0, nil, fmt.Errorf("count %d exceeds the message", count)
+	}
+	return int(count), rest, nil
+}
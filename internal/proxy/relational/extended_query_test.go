@@ -0,0 +1,213 @@
+package relational
+
+import (
+	"encoding/binary"
+	"reflect"
+	"testing"
+)
+
+// payload builds a message payload from strings, written as C strings, int16
+// counts and codes, int32 lengths and raw bytes.
+func payload(parts ...interface{}) []byte {
+	var buff []byte
+	for _, part := range parts {
+		switch value := part.(type) {
+		case string:
+			buff = append(buff, value...)
+			buff = append(buff, 0)
+		case int16:
+			buff = append(buff, 0, 0)
+			binary.BigEndian.PutUint16(buff[len(buff)-2:], uint16(value))
+		case uint16:
+			buff = append(buff, 0, 0)
+			binary.BigEndian.PutUint16(buff[len(buff)-2:], value)
+		case int32:
+			buff = append(buff, 0, 0, 0, 0)
+			binary.BigEndian.PutUint32(buff[len(buff)-4:], uint32(value))
+		case uint32:
+			buff = append(buff, 0, 0, 0, 0)
+			binary.BigEndian.PutUint32(buff[len(buff)-4:], value)
+		case []byte:
+			buff = append(buff, value...)
+		}
+	}
+	return buff
+}
+
+func TestParseParseMessage(t *testing.T) {
+	tests := []struct {
+		name    string
+		payload []byte
+		want    PreparedStatement
+		wantErr bool
+	}{
+		{
+			name:    "unnamed statement without parameter types",
+			payload: payload("", "SELECT 1", int16(0)),
+			want:    PreparedStatement{Query: "SELECT 1", ParameterTypes: []uint32{}},
+		},
+		{
+			name:    "named statement with parameter types",
+			payload: payload("s1", "SELECT $1, $2", int16(2), uint32(int4Oid), uint32(textOid)),
+			want:    PreparedStatement{Name: "s1", Query: "SELECT $1, $2", ParameterTypes: []uint32{int4Oid, textOid}},
+		},
+		{
+			name:    "missing query terminator",
+			payload: []byte("s1\x00SELECT 1"),
+			wantErr: true,
+		},
+		{
+			name:    "missing count",
+			payload: payload("", "SELECT 1"),
+			wantErr: true,
+		},
+		{
+			name:    "count above parameter types sent",
+			payload: payload("", "SELECT $1", int16(2), uint32(int4Oid)),
+			wantErr: true,
+		},
+		{
+			name:    "negative count",
+			payload: payload("", "SELECT 1", uint16(0xFFFF)),
+			wantErr: true,
+		},
+		{
+			name:    "negative count with trailing data",
+			payload: payload("", "SELECT 1", uint16(0x8000), make([]byte, 64)),
+			wantErr: true,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := ParseParseMessage(tt.payload)
+			if tt.wantErr {
+				if err == nil {
+					t.Fatalf("expected an error, got %+v", got)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("got %+v, want %+v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestParseBindMessage(t *testing.T) {
+	tests := []struct {
+		name    string
+		payload []byte
+		want    BindMessage
+		wantErr bool
+	}{
+		{
+			name:    "no formats and parameters",
+			payload: payload("", "", int16(0), int16(0), int16(0)),
+			want:    BindMessage{FormatCodes: []int16{}, Parameters: [][]byte{}},
+		},
+		{
+			name: "text and binary parameters with NULL",
+			payload: payload("p1", "s1", int16(2), int16(textFormat), int16(binaryFormat),
+				int16(3), int32(3), []byte("abc"), int32(4), []byte{0, 0, 0, 7}, int32(-1), int16(0)),
+			want: BindMessage{
+				Portal:      "p1",
+				Statement:   "s1",
+				FormatCodes: []int16{textFormat, binaryFormat},
+				Parameters:  [][]byte{[]byte("abc"), {0, 0, 0, 7}, nil},
+			},
+		},
+		{
+			name:    "missing statement terminator",
+			payload: []byte("\x00s1"),
+			wantErr: true,
+		},
+		{
+			name:    "negative formats count",
+			payload: payload("", "s1", uint16(0xFFFF), int16(0)),
+			wantErr: true,
+		},
+		{
+			name:    "formats count above codes sent",
+			payload: payload("", "s1", int16(3), int16(textFormat)),
+			wantErr: true,
+		},
+		{
+			name:    "negative parameters count",
+			payload: payload("", "s1", int16(0), uint16(0xFFFF)),
+			wantErr: true,
+		},
+		{
+			name:    "parameters count above lengths sent",
+			payload: payload("", "s1", int16(0), int16(2), int32(1), []byte("a")),
+			wantErr: true,
+		},
+		{
+			name:    "parameter longer than the message",
+			payload: payload("", "s1", int16(0), int16(1), int32(10), []byte("abc")),
+			wantErr: true,
+		},
+		{
+			name:    "missing parameters count",
+			payload: payload("", "s1", int16(0)),
+			wantErr: true,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := ParseBindMessage(tt.payload)
+			if tt.wantErr {
+				if err == nil {
+					t.Fatalf("expected an error, got %+v", got)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("got %+v, want %+v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestBindQuery(t *testing.T) {
+	tests := []struct {
+		name      string
+		statement PreparedStatement
+		bind      BindMessage
+		want      string
+	}{
+		{
+			name:      "text parameters",
+			statement: PreparedStatement{Query: "SELECT * FROM users WHERE id = $1 AND name = $2"},
+			bind:      BindMessage{Parameters: [][]byte{[]byte("7"), []byte("bob")}},
+			want:      "SELECT * FROM users WHERE id = 7 AND name = bob",
+		},
+		{
+			name:      "placeholders in quotes are kept",
+			statement: PreparedStatement{Query: "SELECT '$1', \"$1\", $1"},
+			bind:      BindMessage{Parameters: [][]byte{[]byte("x")}},
+			want:      "SELECT '$1', \"$1\", x",
+		},
+		{
+			name:      "binary int4 and NULL",
+			statement: PreparedStatement{Query: "SELECT $1, $2", ParameterTypes: []uint32{int4Oid, textOid}},
+			bind:      BindMessage{FormatCodes: []int16{binaryFormat}, Parameters: [][]byte{{0, 0, 1, 0}, nil}},
+			want:      "SELECT 256, NULL",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := tt.statement.BindQuery(tt.bind); got != tt.want {
+				t.Errorf("got %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
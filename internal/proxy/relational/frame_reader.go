@@ -0,0 +1,135 @@
+package relational
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/binary"
+	"fmt"
+	"io"
+)
+
+const (
+	headerLength    = 5
+	lengthFieldSize = 4
+	// MaxMessageLength is the longest message allowed by the protocol
+	MaxMessageLength = 1 << 30
+	// DefaultMaxMessageLength is the longest message accepted from clients
+	// unless configured otherwise
+	DefaultMaxMessageLength = 64 << 20
+	// MaxStartupMessageLength is the longest untyped message, the same limit
+	// PostgreSQL puts on startup packets
+	MaxStartupMessageLength = 10000
+)
+
+// Message is a single, complete protocol message. Raw holds the message exactly
+// as it was received, so it can be forwarded without re-encoding.
+type Message struct {
+	Header  PGHeader
+	Raw     []byte
+	Startup bool
+}
+
+// Payload returns the message body without the type byte and length field.
+func (m Message) Payload() []byte {
+	if m.Startup {
+		return m.Raw[lengthFieldSize:]
+	}
+	return m.Raw[headerLength:]
+}
+
+// FrameReader reassembles protocol messages from a byte stream, regardless of
+// how they were split between TCP reads. Messages are only held in memory as
+// their bytes arrive, so a declared length alone can't make it allocate.
+type FrameReader struct {
+	reader           *bufio.Reader
+	maxMessageLength int
+}
+
+// NewFrameReader creates a reader of typed messages up to maxMessageLength
+// bytes long. Untyped messages are always limited to MaxStartupMessageLength.
+func NewFrameReader(r io.Reader, bufferSize, maxMessageLength int) *FrameReader {
+	return &FrameReader{reader: bufio.NewReaderSize(r, bufferSize), maxMessageLength: maxMessageLength}
+}
+
+// ReadMessage reads one regular (typed) message: type byte, length and body.
+func (fr *FrameReader) ReadMessage() (Message, error) {
+	raw := make([]byte, headerLength)
+	if _, err := io.ReadFull(fr.reader, raw); err != nil {
+		return Message{}, err
+	}
+
+	header := CreateHeaderFromBytes(raw)
+	if err := validateLength(header.PacketLength, fr.maxMessageLength); err != nil {
+		return Message{}, err
+	}
+
+	raw, err := fr.readRemaining(raw, header.PacketLength-lengthFieldSize)
+	if err != nil {
+		return Message{}, err
+	}
+
+	return Message{Header: header, Raw: raw}, nil
+}
+
+// ReadStartupMessage reads one untyped message, as sent by the client before the
+// startup phase is over (StartupMessage, SSLRequest, CancelRequest...).
+func (fr *FrameReader) ReadStartupMessage() (Message, error) {
+	raw := make([]byte, lengthFieldSize)
+	if _, err := io.ReadFull(fr.reader, raw); err != nil {
+		return Message{}, err
+	}
+
+	length := int(binary.BigEndian.Uint32(raw))
+	if err := validateLength(length, MaxStartupMessageLength); err != nil {
+		return Message{}, err
+	}
+
+	raw, err := fr.readRemaining(raw, length-lengthFieldSize)
+	if err != nil {
+		return Message{}, err
+	}
+
+	return Message{Header: PGHeader{PacketLength: length}, Raw: raw, Startup: true}, nil
+}
+
+// ReadByte reads a single byte which is not framed as a message, e.g. the
+// answer to SSLRequest.
+func (fr *FrameReader) ReadByte() (byte, error) {
+	return fr.reader.ReadByte()
+}
+
+// Buffered returns the number of bytes which can be read without blocking.
+func (fr *FrameReader) Buffered() int {
+	return fr.reader.Buffered()
+}
+
+// readRemaining appends the rest of a message to its beginning. Messages which
+// fit in the read buffer are allocated at once, longer ones grow with the data
+// actually received.
+func (fr *FrameReader) readRemaining(raw []byte, remaining int) ([]byte, error) {
+	if remaining <= fr.reader.Size() {
+		message := make([]byte, len(raw)+remaining)
+		copy(message, raw)
+		if _, err := io.ReadFull(fr.reader, message[len(raw):]); err != nil {
+			return nil, err
+		}
+		return message, nil
+	}
+
+	message := bytes.NewBuffer(make([]byte, 0, len(raw)+fr.reader.Size()))
+	message.Write(raw)
+	if _, err := io.CopyN(message, fr.reader, int64(remaining)); err != nil {
+		if err == io.EOF {
+			err = io.ErrUnexpectedEOF
+		}
+		return nil, err
+	}
+	return message.Bytes(), nil
+}
+
+func validateLength(length, maxLength int) error {
+	if length < lengthFieldSize || length > maxLength {
+		return fmt.Errorf("invalid message length: %d", length)
+	}
+	return nil
+}
@@ -0,0 +1,198 @@
+package relational
+
+import (
+	"bytes"
+	"encoding/binary"
+	"io"
+	"runtime"
+	"testing"
+	"testing/iotest"
+)
+
+func TestFrameReaderReadMessage(t *testing.T) {
+	query := encodeMessage(QueryPacket, []byte("SELECT 1\x00"))
+	sync := encodeMessage(SyncPacket, nil)
+
+	tests := []struct {
+		name    string
+		reader  func(data []byte) io.Reader
+		data    []byte
+		want    [][]byte
+		wantErr bool
+	}{
+		{
+			name:   "whole messages",
+			reader: func(data []byte) io.Reader { return bytes.NewReader(data) },
+			data:   append(append([]byte{}, query...), sync...),
+			want:   [][]byte{query, sync},
+		},
+		{
+			name:   "split into single bytes",
+			reader: func(data []byte) io.Reader { return iotest.OneByteReader(bytes.NewReader(data)) },
+			data:   append(append([]byte{}, query...), sync...),
+			want:   [][]byte{query, sync},
+		},
+		{
+			name:   "split into halves",
+			reader: func(data []byte) io.Reader { return iotest.HalfReader(bytes.NewReader(data)) },
+			data:   query,
+			want:   [][]byte{query},
+		},
+		{
+			name:    "length below the length field",
+			reader:  func(data []byte) io.Reader { return bytes.NewReader(data) },
+			data:    []byte{QueryPacket, 0, 0, 0, 3},
+			wantErr: true,
+		},
+		{
+			name:    "oversize length",
+			reader:  func(data []byte) io.Reader { return bytes.NewReader(data) },
+			data:    []byte{QueryPacket, 0xFF, 0xFF, 0xFF, 0xFF, 'x'},
+			wantErr: true,
+		},
+		{
+			name:    "length above the configured limit",
+			reader:  func(data []byte) io.Reader { return bytes.NewReader(data) },
+			data:    []byte{QueryPacket, 0x04, 0x00, 0x00, 0x05, 'x'},
+			wantErr: true,
+		},
+		{
+			name:   "message longer than the read buffer",
+			reader: func(data []byte) io.Reader { return iotest.HalfReader(bytes.NewReader(data)) },
+			data:   encodeMessage(QueryPacket, bytes.Repeat([]byte{'x'}, 3*DefaultBufferSize)),
+			want:   [][]byte{encodeMessage(QueryPacket, bytes.Repeat([]byte{'x'}, 3*DefaultBufferSize))},
+		},
+		{
+			name:    "truncated long body",
+			reader:  func(data []byte) io.Reader { return bytes.NewReader(data) },
+			data:    encodeMessage(QueryPacket, bytes.Repeat([]byte{'x'}, 3*DefaultBufferSize))[:2*DefaultBufferSize],
+			wantErr: true,
+		},
+		{
+			name:    "truncated body",
+			reader:  func(data []byte) io.Reader { return bytes.NewReader(data) },
+			data:    query[:len(query)-2],
+			wantErr: true,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			reader := NewFrameReader(tt.reader(tt.data), DefaultBufferSize, DefaultMaxMessageLength)
+			for _, want := range tt.want {
+				message, err := reader.ReadMessage()
+				if err != nil {
+					t.Fatalf("unexpected error: %v", err)
+				}
+				if !bytes.Equal(message.Raw, want) {
+					t.Fatalf("got %q, want %q", message.Raw, want)
+				}
+				if message.Header.PacketType != int(want[0]) || message.Header.PacketLength != len(want)-1 {
+					t.Errorf("unexpected header %+v", message.Header)
+				}
+			}
+
+			_, err := reader.ReadMessage()
+			if tt.wantErr && (err == nil || err == io.EOF) {
+				t.Fatalf("expected a framing error, got %v", err)
+			}
+			if !tt.wantErr && err != io.EOF {
+				t.Fatalf("expected EOF after the messages, got %v", err)
+			}
+		})
+	}
+}
+
+func TestFrameReaderReadStartupMessage(t *testing.T) {
+	startup := []byte("\x00\x00\x00\x14\x00\x03\x00\x00user\x00alice\x00\x00")
+	sslRequest := []byte{0, 0, 0, 8, 0x04, 0xD2, 0x16, 0x2F}
+
+	tests := []struct {
+		name     string
+		data     []byte
+		wantCode int
+		wantUser string
+		wantErr  bool
+	}{
+		{name: "startup message", data: startup, wantCode: ProtocolVersion3 << 16, wantUser: "alice"},
+		{name: "ssl request", data: sslRequest, wantCode: SSLRequestCode},
+		{name: "length below the length field", data: []byte{0, 0, 0, 2}, wantErr: true},
+		{name: "oversize length", data: []byte{0x7F, 0xFF, 0xFF, 0xFF, 0}, wantErr: true},
+		{name: "above the startup limit", data: []byte{0, 0, 0x27, 0x11, 0}, wantErr: true},
+		{name: "truncated", data: startup[:10], wantErr: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			reader := NewFrameReader(iotest.OneByteReader(bytes.NewReader(tt.data)), DefaultBufferSize, DefaultMaxMessageLength)
+			message, err := reader.ReadStartupMessage()
+			if tt.wantErr {
+				if err == nil {
+					t.Fatalf("expected an error, got %q", message.Raw)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+			if !bytes.Equal(message.Raw, tt.data) {
+				t.Errorf("got %q, want %q", message.Raw, tt.data)
+			}
+			if code := GetStartupCode(message); code != tt.wantCode {
+				t.Errorf("got code %d, want %d", code, tt.wantCode)
+			}
+			if tt.wantUser == "" {
+				return
+			}
+			startupMessage, err := ParseStartupMessage(message)
+			if err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+			if user := startupMessage.User(); user != tt.wantUser {
+				t.Errorf("got user %q, want %q", user, tt.wantUser)
+			}
+		})
+	}
+}
+
+func TestFrameReaderDoesNotAllocateDeclaredLength(t *testing.T) {
+	typed := []byte{QueryPacket, 0, 0, 0, 0}
+	binary.BigEndian.PutUint32(typed[1:], DefaultMaxMessageLength)
+	startup := make([]byte, lengthFieldSize)
+	binary.BigEndian.PutUint32(startup, MaxMessageLength)
+
+	tests := []struct {
+		name string
+		read func(reader *FrameReader) error
+		data []byte
+	}{
+		{
+			name: "typed message within the limit",
+			read: func(reader *FrameReader) error { _, err := reader.ReadMessage(); return err },
+			data: append(typed, "SELECT 1"...),
+		},
+		{
+			name: "startup message above the limit",
+			read: func(reader *FrameReader) error { _, err := reader.ReadStartupMessage(); return err },
+			data: startup,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			reader := NewFrameReader(bytes.NewReader(tt.data), DefaultBufferSize, DefaultMaxMessageLength)
+
+			var before, after runtime.MemStats
+			runtime.ReadMemStats(&before)
+			err := tt.read(reader)
+			runtime.ReadMemStats(&after)
+
+			if err == nil || err == io.EOF {
+				t.Fatalf("expected an error, got %v", err)
+			}
+			if allocated := after.TotalAlloc - before.TotalAlloc; allocated > 1<<20 {
+				t.Errorf("allocated %d bytes for a message of %d bytes", allocated, len(tt.data))
+			}
+		})
+	}
+}
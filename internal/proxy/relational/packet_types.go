@@ -1,5 +1,20 @@
 package relational
 
+const (
+	BindPacket            = 0x42
+	ClosePacket           = 0x43
+	CommandCompletePacket = 0x43
+	DescribePacket        = 0x44
+	ExecutePacket         = 0x45
+	FunctionCallPacket    = 0x46
+	FlushPacket           = 0x48
+	ParsePacket           = 0x50
+	QueryPacket           = 0x51
+	SyncPacket            = 0x53
+	TerminatePacket       = 0x58
+	ReadyForQueryPacket   = 0x5A
+)
+
 var packetTypes = map[int]string{
 	0x52: "AUTHENTICATION_REQUEST",
 	0x31: "PARSE_COMPLETION",
@@ -9,6 +24,7 @@ var packetTypes = map[int]string{
 	0x44: "DESCRIBE",
 	0x45: "EXECUTE",
 	0x4B: "BACKEND_KEY_DATA",
+	0x4E: "NOTICE_RESPONSE",
 	0x50: "PARSE",
 	0x51: "QUERY",
 	0x53: "PARAMETER_STATUS",
@@ -21,3 +37,12 @@ var packetTypes = map[int]string{
 func GetPacketType(typeFlag int) string {
 	return packetTypes[typeFlag]
 }
+
+// getPacketTypeLabel names the packet type for metrics, including the unknown
+// ones.
+func getPacketTypeLabel(typeFlag int) string {
+	if name := GetPacketType(typeFlag); name != "" {
+		return name
+	}
+	return "UNKNOWN"
+}
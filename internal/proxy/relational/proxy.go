@@ -1,30 +1,32 @@
 package relational
 
 import (
-	"encoding/binary"
+	"crypto/tls"
 	"fmt"
-	"io"
-	"log"
 	"net"
-	utils "proxy-engineering-thesis"
-	"proxy-engineering-thesis/internal/aws"
+	"proxy-engineering-thesis/internal/alerting"
+	"proxy-engineering-thesis/internal/logger"
+	"proxy-engineering-thesis/internal/metrics"
 	"proxy-engineering-thesis/internal/proxy/blacklist"
 	"proxy-engineering-thesis/internal/proxy/detection"
+	"proxy-engineering-thesis/internal/proxy/ratelimit"
 	"proxy-engineering-thesis/model"
 	"strings"
+	"sync"
 	"time"
 
 	"github.com/google/uuid"
 )
 
 const (
-	MaxBufferSize = 16400
-	DetectionMode = iota
+	DefaultBufferSize = 16400
+	DetectionMode     = iota
 	PreventionMode
 	FullProtectionMode
 )
 
 type ProxyConfiguration struct {
+	Id               uint
 	Name             string
 	ListeningAddress model.Address
 	Listener         net.Listener
@@ -33,292 +35,318 @@ type ProxyConfiguration struct {
 	NumberOfSessions int
 	Mode             int
 	Done             chan interface{}
+	ClientTLSConfig  *tls.Config
+	TargetTLSConfig  *tls.Config
+	Detector         detection.Detector
+	EventRecorder    EventRecorder
+	Alerts           *alerting.Dispatcher
+	BlackList        *blacklist.BlackListManager
+	BlackListScope   string
+	RateLimiter      *ratelimit.Limiter
+	Metrics          *metrics.ProxyMetrics
+	logger           *logger.Logger
+	// SlowQueryThreshold is the duration above which statements are recorded
+	// as slow queries, zero turns the tracking off.
+	SlowQueryThreshold time.Duration
+	SlowQueryRecorder  SlowQueryRecorder
+	// BufferSize of reads from the client and the target of sessions
+	BufferSize int
+	// MaxMessageLength of messages from clients, replies of the target are
+	// only limited by the protocol
+	MaxMessageLength int
+	sessionsLock     sync.Mutex
 }
 
-type Session struct {
-	clientConn                net.Conn
-	targetConn                net.Conn
-	detector                  detection.Detector
-	blackListManager          *blacklist.BlackListManager
-	ClosingTriggered          bool
-	clientActivityInterrupted bool
-	mode                      int
-	cwClient                  *aws.CloudWatchConfiguration
-}
-
-func NewProxy(dto model.ProxyDto, ds model.DataSource, proxyMode string) *ProxyConfiguration {
-	return &ProxyConfiguration{
-		Name:             dto.Name,
-		ListeningAddress: dto.Address,
-		Target:           ds,
-		Sessions:         make(map[string]*Session),
-		NumberOfSessions: 0,
-		Mode:             GetProxyMode(proxyMode),
-		Done:             make(chan interface{}),
+func NewProxy(dto model.ProxyDto, ds model.DataSource, proxyMode string, services Services) (*ProxyConfiguration, error) {
+	clientTLSConfig, err := NewClientTLSConfig(dto)
+	if err != nil {
+		return nil, err
 	}
-}
 
-func (p *ProxyConfiguration) newSession(clientConn, targetConn net.Conn) string {
-	var cwClient *aws.CloudWatchConfiguration
-	conf, err := utils.ReadPropertiesBasedConfig("resources/cw.properties")
+	targetTLSConfig, err := NewTargetTLSConfig(ds)
 	if err != nil {
-		fmt.Printf("failed to retrieve CloudWatch config from file: %v\n", err)
+		return nil, err
 	}
 
-	if conf["logGroupName"] == "" || conf["logStreamName"] == "" {
-		fmt.Printf("didn't found necessary CloudWatch configuration in resources")
-		cwClient = nil
-	} else {
-		cwClient = aws.NewCloudWatchConfiguration(conf["logGroupName"], conf["logStreamName"])
-	}
-	sessionId := uuid.New()
-	s := &Session{
-		clientConn,
-		targetConn,
-		detection.SqlDetector{},
-		blacklist.NewBlackListManager(time.Millisecond * 200),
-		false,
-		false,
-		p.Mode,
-		cwClient,
+	var baseline detection.Baseline
+	if services.Baselines != nil {
+		baseline = services.Baselines.ForProxy(dto.ID)
 	}
-	go s.cwClient.InitLogStore()
-	p.Sessions[sessionId.String()] = s
-	p.NumberOfSessions = p.NumberOfSessions + 1
-	log.Printf("created session: %s", sessionId)
-	return sessionId.String()
-}
 
-func (s *Session) Close() error {
+	detector, err := detection.NewDetector(dto.DetectionEngine, services.RuleSet, baseline)
+	if err != nil {
+		return nil, err
+	}
 
-	err := s.clientConn.Close()
+	rateLimit, err := ratelimit.NewConfig(dto.RateLimit, dto.RateLimitBurst, dto.RateLimitAction, dto.RateLimitMaxDelay)
 	if err != nil {
-		log.Printf("failed to close client connection: %v\n", err)
-		return err
+		return nil, err
 	}
-	err = s.targetConn.Close()
+
+	slowQueryThreshold, err := getSlowQueryThreshold(dto.SlowQueryThreshold)
 	if err != nil {
-		log.Printf("failed to close target connection: %v\n", err)
-		return err
+		return nil, err
 	}
-	s.blackListManager.PurgeCache()
-	return nil
-}
 
-func (p *ProxyConfiguration) CloseSessions() {
-	for _, v := range p.Sessions {
-		v.ClosingTriggered = true
-		v.Close()
+	blackList := services.BlackList
+	if blackList == nil {
+		blackList = blacklist.NewBlackListManager(blacklist.NewAccessList(), blacklist.DefaultConfig())
 	}
+
+	var alerts *alerting.Dispatcher
+	if services.Alerts != nil {
+		alerts = services.Alerts.ForProxy(dto.ID)
+	}
+
+	proxyMetrics := services.Metrics
+	if proxyMetrics == nil {
+		proxyMetrics = metrics.NewProxyMetrics()
+	}
+
+	bufferSize := services.BufferSize
+	if bufferSize <= 0 {
+		bufferSize = DefaultBufferSize
+	}
+
+	maxMessageLength := services.MaxMessageLength
+	if maxMessageLength <= 0 {
+		maxMessageLength = DefaultMaxMessageLength
+	}
+
+	return &ProxyConfiguration{
+		Id:                 dto.ID,
+		Name:               dto.Name,
+		ListeningAddress:   dto.Address,
+		Target:             ds,
+		Sessions:           make(map[string]*Session),
+		NumberOfSessions:   0,
+		Mode:               GetProxyMode(proxyMode),
+		Done:               make(chan interface{}),
+		ClientTLSConfig:    clientTLSConfig,
+		TargetTLSConfig:    targetTLSConfig,
+		Detector:           detector,
+		EventRecorder:      services.EventRecorder,
+		Alerts:             alerts,
+		BlackList:          blackList,
+		BlackListScope:     GetBlackListScope(dto.BlacklistScope),
+		RateLimiter:        ratelimit.NewLimiter(rateLimit),
+		Metrics:            proxyMetrics,
+		SlowQueryThreshold: slowQueryThreshold,
+		SlowQueryRecorder:  services.SlowQueries,
+		BufferSize:         bufferSize,
+		MaxMessageLength:   maxMessageLength,
+		logger:             logger.New("proxy").With("proxy", dto.Name),
+	}, nil
 }
 
-func (p *ProxyConfiguration) Start() {
-	log.Printf("started new '%s' proxy instance", p.Name)
-	listener, err := net.Listen("tcp", p.ListeningAddress.CreateHostString())
+func getSlowQueryThreshold(threshold string) (time.Duration, error) {
+	if threshold == "" {
+		return 0, nil
+	}
+
+	duration, err := time.ParseDuration(threshold)
 	if err != nil {
-		log.Printf("error when setting up listener: %v\n", err)
-		return
+		return 0, fmt.Errorf("invalid slow query threshold: %v", err)
 	}
-	p.Listener = listener
+	if duration < 0 {
+		return 0, fmt.Errorf("slow query threshold can't be negative")
+	}
+	return duration, nil
+}
 
-	defer listener.Close()
+func (p *ProxyConfiguration) isSlowQuery(duration time.Duration) bool {
+	return p.SlowQueryThreshold > 0 && duration >= p.SlowQueryThreshold
+}
 
-	log.Printf("proxy listening on %s, forwarding to %s:%s...\n", p.ListeningAddress.CreateHostString(), p.Target.Hostname, p.Target.Port)
+func (p *ProxyConfiguration) newSession(clientConn, targetConn net.Conn) *Session {
+	sessionId := uuid.New().String()
+	s := &Session{
+		id:               sessionId,
+		proxy:            p,
+		logger:           p.logger.With("session", sessionId, "client", clientConn.RemoteAddr().String()),
+		startedAt:        time.Now(),
+		clientAddress:    clientConn.RemoteAddr().String(),
+		clientConn:       clientConn,
+		targetConn:       targetConn,
+		clientReader:     NewFrameReader(clientConn, p.BufferSize, p.MaxMessageLength),
+		targetReader:     NewFrameReader(targetConn, p.BufferSize, MaxMessageLength),
+		detector:         p.Detector,
+		statements:       make(map[string]PreparedStatement),
+		blackListManager: p.BlackList,
+		clientIP:         GetClientIP(clientConn.RemoteAddr().String()),
+		blackListKey:     blacklist.Key(GetClientIP(clientConn.RemoteAddr().String()), p.Id, p.BlackListScope),
+		mode:             p.Mode,
+		clientTLSConfig:  p.ClientTLSConfig,
+		targetTLSConfig:  p.TargetTLSConfig,
+		// the target owes the client ReadyForQuery ending the startup phase
+		transactionStatus:     TransactionIdle,
+		readyForQueryExpected: 1,
+		inFlight:              []inFlightStatement{{}},
+	}
 
-	for {
-		clientConn, err := listener.Accept()
-		if err != nil {
-			if strings.Contains(err.Error(), "use of closed network connection") {
-				log.Printf("closed proxy listener")
-				p.Done <- struct{}{}
-				return
-			}
-			log.Printf("error accepting connection: %v\n", err)
-			continue
-		}
+	p.sessionsLock.Lock()
+	p.Sessions[sessionId] = s
+	p.NumberOfSessions = len(p.Sessions)
+	p.sessionsLock.Unlock()
+	p.Metrics.ActiveSessions.Add(1, p.Name)
 
-		log.Printf("received new connection: %s", clientConn.RemoteAddr().String())
+	s.logger.Debug("created session")
+	return s
+}
 
-		targetConn, err := net.Dial("tcp", fmt.Sprintf("%s:%s", p.Target.Hostname, p.Target.Port))
-		if err != nil {
-			log.Printf("target is unreachable: %v\n", err)
-			clientConn.Close()
-			continue
-		}
+func (p *ProxyConfiguration) removeSession(sessionId string) {
+	p.sessionsLock.Lock()
+	delete(p.Sessions, sessionId)
+	p.NumberOfSessions = len(p.Sessions)
+	p.sessionsLock.Unlock()
+	p.Metrics.ActiveSessions.Add(-1, p.Name)
 
-		log.Printf("set up connection with database")
+	p.logger.Debug("removed session", "session", sessionId)
+}
 
-		sessionId := p.newSession(clientConn, targetConn)
-		session := p.Sessions[sessionId]
+// GetSessions returns a snapshot describing every open session.
+func (p *ProxyConfiguration) GetSessions() []SessionInfo {
+	p.sessionsLock.Lock()
+	defer p.sessionsLock.Unlock()
 
-		go p.handleConnection(session)
+	sessions := make([]SessionInfo, 0, len(p.Sessions))
+	for _, s := range p.Sessions {
+		sessions = append(sessions, s.Info())
 	}
+	return sessions
 }
 
-func (p *ProxyConfiguration) handleConnection(session *Session) {
-	var packetsProcessed int
-	clientBuffer := make([]byte, MaxBufferSize)
-	targetBuffer := make([]byte, MaxBufferSize)
+// GetNumberOfSessions returns the number of open sessions.
+func (p *ProxyConfiguration) GetNumberOfSessions() int {
+	p.sessionsLock.Lock()
+	defer p.sessionsLock.Unlock()
 
-	for {
-		if session.ClosingTriggered {
-			p.Done <- struct{}{}
-		}
+	return p.NumberOfSessions
+}
 
-		select {
-		case <-p.Done:
-			return
-		default:
+// TerminateSession ends a single session, telling the client it was terminated
+// by an administrator.
+func (p *ProxyConfiguration) TerminateSession(sessionId string) error {
+	p.sessionsLock.Lock()
+	s, present := p.Sessions[sessionId]
+	p.sessionsLock.Unlock()
 
-			packetsProcessed = packetsProcessed + 1
-			session.clientActivityInterrupted = false
+	if !present {
+		return fmt.Errorf("no session found with given id: %s", sessionId)
+	}
 
-			// Perform task operation
-			session.handleInboundTraffic(clientBuffer, packetsProcessed)
-			if !session.clientActivityInterrupted {
-				session.handleOutboundTraffic(targetBuffer, packetsProcessed)
-			}
-		}
+	s.Terminate(ResponseFields{Severity: FatalSeverity, SQLState: AdminShutdownSQLState, Message: AdminShutdownMessage})
+	return nil
+}
+
+func (p *ProxyConfiguration) CloseSessions() {
+	p.sessionsLock.Lock()
+	defer p.sessionsLock.Unlock()
+
+	for _, v := range p.Sessions {
+		v.Close()
 	}
 }
 
-func (s *Session) handleInboundTraffic(buff []byte, packetsProcessed int) {
-	read, err := s.clientConn.Read(buff)
+// Listen binds the listening address, so that failures can be reported before
+// the proxy is started.
+func (p *ProxyConfiguration) Listen() error {
+	listener, err := net.Listen("tcp", p.ListeningAddress.CreateHostString())
 	if err != nil {
-		if strings.Contains(err.Error(), "use of closed network connection") {
-			log.Printf("Connection closed")
-			s.ClosingTriggered = true
+		return fmt.Errorf("error when setting up listener: %v", err)
+	}
+	p.Listener = listener
+	return nil
+}
+
+func (p *ProxyConfiguration) Start() {
+	p.logger.Info("started new proxy instance")
+	if p.Listener == nil {
+		if err := p.Listen(); err != nil {
+			p.logger.Error("failed to start proxy", "error", err)
 			return
 		}
-		if err != io.EOF {
-			log.Printf("Error reading data from source: %v\n", err)
-		}
-		return
 	}
+	listener := p.Listener
 
-	source := s.clientConn.RemoteAddr().String()
-
-	defer func() {
-		s.blackListManager.UpdateLastAccess(source)
-	}()
+	defer listener.Close()
 
-	// Handle potential blacklisting
-	if s.blackListManager.ShouldRequestBeBlocked(source) && packetsProcessed > 10 {
-		s.blackListManager.BlockProcessingTraffic(source)
-	}
+	p.logger.Info("proxy listening", "address", p.ListeningAddress.CreateHostString(), "target", p.Target.CreateHostString())
 
-	next := true
-	maliciousDetected := false
-	offset := 0
-	for next {
-		header := CreateHeaderFromBytes(buff[offset : offset+5])
-		if header.PacketType == 0x51 || header.PacketType == 0x50 {
-			status := s.detector.DetectMaliciousContent(buff[offset+5 : offset+header.PacketLength])
-			if status == detection.MALICIOUS {
-				maliciousDetected = true
+	for {
+		clientConn, err := listener.Accept()
+		if err != nil {
+			if strings.Contains(err.Error(), "use of closed network connection") {
+				p.logger.Info("closed proxy listener")
+				close(p.Done)
+				return
 			}
+			p.logger.Error("failed to accept connection", "error", err)
+			continue
 		}
-		log.Printf("Packet type: %s; packet length: %d", GetPacketType(header.PacketType), header.PacketLength)
-		offset = offset + header.PacketLength + 1
-		next = !(offset >= read)
-	}
 
-	var buffToWrite []byte
-	if maliciousDetected {
-		source := s.clientConn.RemoteAddr().String()
-		s.blackListManager.UpdateCache(source)
+		p.logger.Debug("received new connection", "client", clientConn.RemoteAddr().String())
 
-		if s.mode == DetectionMode || s.mode == FullProtectionMode {
-			message := fmt.Sprintf("Malicious query; IP - %s", s.clientConn.RemoteAddr())
-			go s.cwClient.SendLog(message)
+		if p.BlackList.IsDenied(GetClientIP(clientConn.RemoteAddr().String())) {
+			p.logger.Warn("refused connection from denied address", "client", clientConn.RemoteAddr().String())
+			p.Metrics.Connections.Inc(p.Name, metrics.ConnectionRejected)
+			p.Metrics.BlacklistHits.Inc(p.Name, metrics.DenyListHit)
+			clientConn.Close()
+			continue
 		}
 
-		if s.mode == PreventionMode || s.mode == FullProtectionMode {
-			buffToWrite = GetMaliciousActivityDetectedError()
-			_, err := s.clientConn.Write(buffToWrite)
-			if err != nil {
-				if err != io.EOF {
-					log.Printf("Error writing data to proxy message buffer: %v\n", err)
-				}
-			}
-			s.clientActivityInterrupted = true
-			return
+		targetConn, err := net.Dial("tcp", p.Target.CreateHostString())
+		if err != nil {
+			p.logger.Error("target is unreachable", "error", err)
+			p.Metrics.TargetDialFailures.Inc(p.Name)
+			clientConn.Close()
+			continue
 		}
-	}
 
-	buffToWrite = buff[:read]
-	written, err := s.targetConn.Write(buffToWrite)
+		p.logger.Debug("set up connection with database", "client", clientConn.RemoteAddr().String())
+		p.Metrics.Connections.Inc(p.Name, metrics.ConnectionAccepted)
 
-	if err != nil {
-		if strings.Contains(err.Error(), "use of closed network connection") {
-			log.Printf("Connection closed")
-			s.ClosingTriggered = true
-			return
-		}
-		if err != io.EOF {
-			log.Printf("Error writing data from target to destination: %v\n", err)
-		}
-		return
-	}
+		session := p.newSession(clientConn, targetConn)
 
-	if packetsProcessed == 1 {
-		log.Printf("It's a startup message")
-		messageLength := binary.BigEndian.Uint32(buff[:4])
-		log.Printf("STARTUP MESSAGE LENGTH: %d", messageLength)
-		return
+		go p.handleConnection(session)
 	}
-
-	log.Printf("Copied %d bytes from listener to target\n", written)
 }
 
-func (s *Session) handleOutboundTraffic(buff []byte, packetsProcessed int) {
-	//when sending message from proxy itself, program will lock on that reading command
-	read, err := s.targetConn.Read(buff)
-	if err != nil {
-		if strings.Contains(err.Error(), "use of closed network connection") {
-			log.Printf("Connection closed")
-			return
-		}
-		if err != io.EOF {
-			log.Printf("Error reading data from source: %v\n", err)
-		}
-		return
-	}
-
-	written, err := s.clientConn.Write(buff[:read])
+// handleConnection relays the startup phase and then pumps traffic in both
+// directions independently, until either side of the session goes away.
+func (p *ProxyConfiguration) handleConnection(session *Session) {
+	defer p.removeSession(session.id)
 
-	if err != nil {
-		if strings.Contains(err.Error(), "use of closed network connection") {
-			log.Printf("Connection closed")
-			return
+	if err := session.startup(); err != nil {
+		if err != errCancelRequestRelayed {
+			session.logConnectionError(err)
 		}
-		if err != io.EOF {
-			log.Printf("Error writing data from target to destination: %v\n", err)
-		}
-		return
-	}
-
-	if packetsProcessed == 1 {
-		log.Printf("It's a startup message")
-		messageLength := binary.BigEndian.Uint32(buff[:4])
-		log.Printf("STARTUP MESSAGE LENGTH: %d", messageLength)
+		session.Close()
 		return
 	}
 
-	readPacketBytes(buff, read)
-
-	log.Printf("Copied %d bytes from listener to target\n", written)
+	var wg sync.WaitGroup
+	wg.Add(2)
+	go func() {
+		defer wg.Done()
+		session.pumpInbound()
+		session.Close()
+	}()
+	go func() {
+		defer wg.Done()
+		session.pumpOutbound()
+		session.Close()
+	}()
+	wg.Wait()
 }
 
-func readPacketBytes(buff []byte, readBytes int) {
-	next := true
-	offset := 0
-	for next {
-		header := CreateHeaderFromBytes(buff[offset : offset+5])
-		log.Printf("Packet type: %s; packet length: %d", GetPacketType(header.PacketType), header.PacketLength)
-		offset = offset + header.PacketLength + 1
-		next = !(offset >= readBytes)
+// GetBlackListScope returns the proxy scope only when it's requested, clients
+// are tracked by all proxies together by default.
+func GetBlackListScope(scope string) string {
+	if strings.ToLower(scope) == blacklist.ProxyScope {
+		return blacklist.ProxyScope
 	}
+	return blacklist.GlobalScope
 }
 
 func GetProxyMode(mode string) int {
@@ -0,0 +1,44 @@
+package relational
+
+import (
+	"proxy-engineering-thesis/internal/alerting"
+	"proxy-engineering-thesis/internal/metrics"
+	"proxy-engineering-thesis/internal/proxy/blacklist"
+	"proxy-engineering-thesis/internal/proxy/detection"
+	"proxy-engineering-thesis/model"
+)
+
+// EventRecorder receives security events raised by sessions. It's called by
+// the inbound pump, so it must not block on storing them.
+type EventRecorder interface {
+	RecordEvent(event model.SecurityEvent)
+}
+
+// SlowQueryRecorder receives statements which took longer than the slow query
+// threshold of the proxy. It must not block the session, it returns false
+// when the query is dropped instead.
+type SlowQueryRecorder interface {
+	RecordSlowQuery(query model.SlowQuery) bool
+}
+
+// AlertDispatchers provides the dispatcher sending alerts of a proxy to its
+// sinks.
+type AlertDispatchers interface {
+	ForProxy(proxyId uint) *alerting.Dispatcher
+}
+
+// Services groups components provided by the server and shared by proxies.
+type Services struct {
+	RuleSet       *detection.RuleSet
+	EventRecorder EventRecorder
+	Baselines     detection.BaselineStore
+	BlackList     *blacklist.BlackListManager
+	Metrics       *metrics.ProxyMetrics
+	SlowQueries   SlowQueryRecorder
+	Alerts        AlertDispatchers
+	// BufferSize of reads of sessions, DefaultBufferSize when not set
+	BufferSize int
+	// MaxMessageLength of messages from clients, DefaultMaxMessageLength when
+	// not set
+	MaxMessageLength int
+}
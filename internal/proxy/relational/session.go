@@ -0,0 +1,635 @@
+package relational
+
+import (
+	"crypto/tls"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"proxy-engineering-thesis/internal/alerting"
+	"proxy-engineering-thesis/internal/logger"
+	"proxy-engineering-thesis/internal/metrics"
+	"proxy-engineering-thesis/internal/proxy/blacklist"
+	"proxy-engineering-thesis/internal/proxy/detection"
+	"proxy-engineering-thesis/internal/proxy/ratelimit"
+	"proxy-engineering-thesis/model"
+	"strings"
+	"sync"
+	"time"
+)
+
+var (
+	errCancelRequestRelayed = errors.New("cancel request relayed to target")
+	errRateLimitDropped     = errors.New("connection dropped by rate limiter")
+)
+
+type Session struct {
+	// traffic counters go first to keep them aligned for atomic access
+	stats sessionStats
+
+	id               string
+	proxy            *ProxyConfiguration
+	logger           *logger.Logger
+	startedAt        time.Time
+	clientAddress    string
+	startupMessage   StartupMessage
+	stateLock        sync.RWMutex
+	clientConn       net.Conn
+	targetConn       net.Conn
+	clientReader     *FrameReader
+	targetReader     *FrameReader
+	clientWriteLock  sync.Mutex
+	closeOnce        sync.Once
+	detector         detection.Detector
+	statements       map[string]PreparedStatement
+	blackListManager *blacklist.BlackListManager
+	blackListKey     string
+	clientIP         string
+	ClosingTriggered bool
+	mode             int
+	clientTLSConfig  *tls.Config
+	targetTLSConfig  *tls.Config
+	discardUntilSync bool
+	// penalizedBan is the end of the last ban the session was delayed for
+	penalizedBan time.Time
+	// extendedQuery is the statement of the extended query protocol waiting
+	// for Sync, which is when it's timed from
+	extendedQuery string
+
+	replyLock             sync.Mutex
+	transactionStatus     byte
+	readyForQueryExpected uint64
+	readyForQueryReceived uint64
+	refusals              []refusal
+	inFlight              []inFlightStatement
+	// pendingExtended counts extended query messages forwarded since the
+	// last Sync, the target doesn't answer them with ReadyForQuery before it
+	pendingExtended int
+}
+
+// Close closes both connections of the session. It is safe to call it more
+// than once and from both pumps.
+func (s *Session) Close() error {
+	var closeErr error
+	s.closeOnce.Do(func() {
+		s.stateLock.Lock()
+		s.ClosingTriggered = true
+		clientConn, targetConn := s.clientConn, s.targetConn
+		s.stateLock.Unlock()
+
+		err := clientConn.Close()
+		if err != nil {
+			s.logger.Error("failed to close client connection", "error", err)
+			closeErr = err
+		}
+		err = targetConn.Close()
+		if err != nil {
+			s.logger.Error("failed to close target connection", "error", err)
+			closeErr = err
+		}
+	})
+	return closeErr
+}
+
+// connections returns the client and target connections of the session. They
+// are replaced by encrypted ones during the startup phase, so goroutines other
+// than the one handling it read them under the state lock.
+func (s *Session) connections() (net.Conn, net.Conn) {
+	s.stateLock.RLock()
+	defer s.stateLock.RUnlock()
+	return s.clientConn, s.targetConn
+}
+
+// replaceConnection swaps a connection of the session for its encrypted
+// version. A session closed during the handshake closes the encrypted
+// connection as well, so that it's not left open.
+func (s *Session) replaceConnection(conn *net.Conn, tlsConn net.Conn) error {
+	s.stateLock.Lock()
+	closed := s.ClosingTriggered
+	if !closed {
+		*conn = tlsConn
+	}
+	s.stateLock.Unlock()
+
+	if closed {
+		tlsConn.Close()
+		return net.ErrClosed
+	}
+	return nil
+}
+
+// startup handles the untyped messages of the startup phase. Encryption is
+// negotiated by the proxy itself on both sides, so that the traffic can be
+// inspected, and only the StartupMessage is relayed to the target.
+func (s *Session) startup() error {
+	for {
+		message, err := s.clientReader.ReadStartupMessage()
+		if err != nil {
+			return err
+		}
+
+		switch GetStartupCode(message) {
+		case SSLRequestCode:
+			if err := s.negotiateClientTLS(); err != nil {
+				return err
+			}
+		case GSSENCRequestCode:
+			// GSSAPI encrypted traffic could not be inspected, so it's always refused
+			if err := s.writeToClient([]byte{'N'}); err != nil {
+				return err
+			}
+		case CancelRequestCode:
+			// cancel requests come on their own connection, the target closes it
+			// right after reading the request, without sending any answer
+			s.logger.Info("relaying cancel request")
+			if err := s.negotiateTargetTLS(); err != nil {
+				return err
+			}
+			if _, err := s.targetConn.Write(message.Raw); err != nil {
+				return err
+			}
+			return errCancelRequestRelayed
+		default:
+			startupMessage, err := ParseStartupMessage(message)
+			if err != nil {
+				// the target is the one to reject the session in its own words
+				s.logger.Warn("failed to parse startup message", "error", err)
+			}
+			s.stateLock.Lock()
+			s.startupMessage = startupMessage
+			s.stateLock.Unlock()
+			s.logger.Info("client connecting", "user", startupMessage.User(), "database", startupMessage.Database(),
+				"application", startupMessage.ApplicationName())
+
+			if err := s.negotiateTargetTLS(); err != nil {
+				return err
+			}
+			_, err = s.targetConn.Write(message.Raw)
+			return err
+		}
+	}
+}
+
+func (s *Session) negotiateClientTLS() error {
+	if s.clientTLSConfig == nil {
+		return s.writeToClient([]byte{'N'})
+	}
+
+	if s.clientReader.Buffered() > 0 {
+		return fmt.Errorf("received unencrypted data after SSL request")
+	}
+
+	if err := s.writeToClient([]byte{'S'}); err != nil {
+		return err
+	}
+
+	tlsConn := tls.Server(s.clientConn, s.clientTLSConfig)
+	if err := tlsConn.Handshake(); err != nil {
+		return fmt.Errorf("TLS handshake with client failed: %v", err)
+	}
+
+	if err := s.replaceConnection(&s.clientConn, tlsConn); err != nil {
+		return err
+	}
+	s.clientReader = NewFrameReader(tlsConn, s.proxy.BufferSize, s.proxy.MaxMessageLength)
+	s.logger.Debug("established TLS connection with client")
+	return nil
+}
+
+func (s *Session) negotiateTargetTLS() error {
+	if s.targetTLSConfig == nil {
+		return nil
+	}
+
+	tlsConn, err := upgradeTargetConnection(s.targetConn, s.targetReader, s.targetTLSConfig)
+	if err != nil {
+		return err
+	}
+
+	if err := s.replaceConnection(&s.targetConn, tlsConn); err != nil {
+		return err
+	}
+	s.targetReader = NewFrameReader(tlsConn, s.proxy.BufferSize, MaxMessageLength)
+	s.logger.Debug("established TLS connection with target", "target", tlsConn.RemoteAddr())
+	return nil
+}
+
+// pumpInbound inspects and forwards client messages to the target until one of
+// the connections fails.
+func (s *Session) pumpInbound() {
+	for {
+		messages, err := readMessages(s.clientReader)
+		if err != nil {
+			s.logConnectionError(err)
+			return
+		}
+
+		if err := s.handleInboundTraffic(messages); err != nil {
+			s.logConnectionError(err)
+			return
+		}
+	}
+}
+
+// pumpOutbound forwards target messages to the client until one of the
+// connections fails.
+func (s *Session) pumpOutbound() {
+	for {
+		messages, err := readMessages(s.targetReader)
+		if err != nil {
+			s.logConnectionError(err)
+			return
+		}
+
+		if err := s.handleOutboundTraffic(messages); err != nil {
+			s.logConnectionError(err)
+			return
+		}
+	}
+}
+
+func (s *Session) handleInboundTraffic(messages []Message) error {
+	source := s.blackListKey
+	// allowlisted clients are exempt from automatic blocking
+	exempt := s.blackListManager.IsAllowed(s.clientIP)
+	var bannedUntil time.Time
+	if !exempt {
+		bannedUntil = s.blackListManager.BannedUntil(source)
+	}
+	if !bannedUntil.IsZero() {
+		s.proxy.Metrics.BlacklistHits.Inc(s.proxy.Name, metrics.OffenderHit)
+	}
+
+	var rejection *ResponseFields
+	switch s.rateLimit(messages, bannedUntil) {
+	case ratelimit.DropAction:
+		return errRateLimitDropped
+	case ratelimit.RejectAction:
+		rejection = &ResponseFields{Severity: ErrorSeverity, SQLState: RateLimitSQLState, Message: RateLimitMessage}
+	}
+
+	var maliciousVerdicts []detection.Verdict
+	var buffToWrite []byte
+	for _, message := range messages {
+		header := message.Header
+		s.logger.Debug("inbound message", "type", GetPacketType(header.PacketType), "length", header.PacketLength)
+		s.stats.recordInbound(message)
+		s.proxy.Metrics.Messages.Inc(s.proxy.Name, metrics.Inbound, getPacketTypeLabel(header.PacketType))
+		s.trackExtendedQuery(header.PacketType)
+
+		if s.discardUntilSync {
+			// Sync ends the failed extended query, the target answers it
+			if header.PacketType == SyncPacket {
+				s.discardUntilSync = false
+				s.extendedQuery = ""
+				s.expectReadyForQuery("")
+				buffToWrite = append(buffToWrite, message.Raw...)
+			}
+			continue
+		}
+
+		if rejection != nil && header.PacketType != TerminatePacket {
+			buffToWrite = append(buffToWrite, s.refuse(message, *rejection)...)
+			continue
+		}
+
+		verdict := s.inspectMessage(message)
+		if verdict.IsMalicious() {
+			maliciousVerdicts = append(maliciousVerdicts, verdict)
+			s.stats.recordDetection()
+		}
+
+		if verdict.IsMalicious() && s.blocking() {
+			s.proxy.Metrics.BlockedQueries.Inc(s.proxy.Name)
+			buffToWrite = append(buffToWrite, s.refuse(message, blockedQueryError(verdict))...)
+			continue
+		}
+
+		switch header.PacketType {
+		case ParsePacket, BindPacket:
+			if query := s.statementText(message); query != "" {
+				s.extendedQuery = query
+			}
+		case QueryPacket:
+			s.expectReadyForQuery(s.statementText(message))
+		case SyncPacket:
+			s.expectReadyForQuery(s.extendedQuery)
+			s.extendedQuery = ""
+		case FunctionCallPacket:
+			s.expectReadyForQuery("")
+		}
+		s.proxy.Metrics.BytesForwarded.Add(float64(len(message.Raw)), s.proxy.Name, metrics.Inbound)
+		buffToWrite = append(buffToWrite, message.Raw...)
+	}
+
+	if len(maliciousVerdicts) > 0 {
+		if !exempt {
+			s.blackListManager.UpdateCache(source)
+		}
+
+		for _, verdict := range maliciousVerdicts {
+			s.logger.Warn("malicious query", "user", s.User(), "database", s.Database(),
+				"severity", verdict.Severity, "score", verdict.Score, "rules", strings.Join(verdict.RuleNames(), ","),
+				"query", verdict.NormalizedQuery)
+
+			event := s.newSecurityEvent(verdict)
+			if s.proxy.EventRecorder != nil {
+				s.proxy.EventRecorder.RecordEvent(event)
+			}
+			if s.proxy.Alerts != nil && (s.mode == DetectionMode || s.mode == FullProtectionMode) {
+				s.proxy.Alerts.Dispatch(alerting.NewAlert(event))
+			}
+		}
+	}
+
+	if len(buffToWrite) == 0 {
+		return nil
+	}
+
+	written, err := s.targetConn.Write(buffToWrite)
+	if err != nil {
+		return err
+	}
+
+	s.logger.Debug("forwarded client messages to target", "bytes", written)
+	return nil
+}
+
+// rateLimit charges queries of the batch to the client address and the db
+// user. Recent offenders, banned until the given time, are limited regardless
+// of their rate: rejected or dropped throughout the ban, or delayed once at
+// its start. Bans are only enforced by proxies which block malicious queries
+// or limit the rate, detection only proxies let offenders through. It returns
+// the action to take, or an empty string when the batch can be forwarded.
+func (s *Session) rateLimit(messages []Message, bannedUntil time.Time) string {
+	var cost int
+	for _, message := range messages {
+		switch message.Header.PacketType {
+		case QueryPacket, SyncPacket, FunctionCallPacket:
+			cost++
+		}
+	}
+	if cost == 0 {
+		return ""
+	}
+
+	limiter := s.proxy.RateLimiter
+	config := limiter.Config()
+
+	var wait time.Duration
+	if config.Enabled() {
+		wait = limiter.Reserve(cost, "ip:"+s.clientIP, "user:"+s.User())
+	}
+	offender := !bannedUntil.IsZero() && (s.blocking() || config.Enabled())
+	if wait == 0 && !offender {
+		limiter.RecordAllowed()
+		return ""
+	}
+
+	switch config.Action {
+	case ratelimit.DelayAction:
+		if offender && !bannedUntil.Equal(s.penalizedBan) {
+			s.penalizedBan = bannedUntil
+			if wait < config.MaxDelay {
+				wait = config.MaxDelay
+			}
+		}
+		if wait == 0 {
+			limiter.RecordAllowed()
+			return ""
+		}
+		if wait <= config.MaxDelay {
+			s.logger.Info("delaying queries, rate limit exceeded", "delay", wait)
+			limiter.RecordDelayed()
+			s.proxy.Metrics.RateLimited.Inc(s.proxy.Name, ratelimit.DelayAction)
+			time.Sleep(wait)
+			return ""
+		}
+	case ratelimit.DropAction:
+		s.logger.Warn("dropping connection, rate limit exceeded")
+		limiter.RecordDropped()
+		s.proxy.Metrics.RateLimited.Inc(s.proxy.Name, ratelimit.DropAction)
+		return ratelimit.DropAction
+	}
+
+	s.logger.Warn("rejecting queries, rate limit exceeded")
+	limiter.RecordRejected()
+	s.proxy.Metrics.RateLimited.Inc(s.proxy.Name, ratelimit.RejectAction)
+	return ratelimit.RejectAction
+}
+
+// detect runs detection on a single query.
+func (s *Session) detect(query []byte) detection.Verdict {
+	return s.detectInContext(query, s.queryContext())
+}
+
+func (s *Session) detectInContext(query []byte, ctx detection.QueryContext) detection.Verdict {
+	s.proxy.Metrics.QueriesInspected.Inc(s.proxy.Name)
+	verdict := s.detector.DetectMaliciousContent(query, ctx)
+	for _, match := range verdict.Matches {
+		s.proxy.Metrics.Detections.Inc(s.proxy.Name, match.RuleName, match.Severity)
+	}
+	return verdict
+}
+
+// inspectMessage runs detection on messages carrying SQL. Prepared statements
+// are tracked, so that values bound to them are inspected together with the
+// statement text as one logical query.
+func (s *Session) inspectMessage(message Message) detection.Verdict {
+	switch message.Header.PacketType {
+	case QueryPacket:
+		return s.detect(message.Payload())
+	case ParsePacket:
+		statement, err := ParseParseMessage(message.Payload())
+		if err != nil {
+			s.logger.Warn("failed to decode parse message", "error", err)
+			return s.detect(message.Payload())
+		}
+		s.statements[statement.Name] = statement
+		return s.detect([]byte(statement.Query))
+	case BindPacket:
+		bind, err := ParseBindMessage(message.Payload())
+		if err != nil {
+			s.logger.Warn("failed to decode bind message", "error", err)
+			return s.detect(message.Payload())
+		}
+		statement, present := s.statements[bind.Statement]
+		if !present {
+			// the target fails the bind, the values are never run
+			s.logger.Warn("bind to unknown prepared statement", "statement", bind.Statement)
+			break
+		}
+		ctx := s.queryContext()
+		ctx.Statement = statement.Query
+		return s.detectInContext([]byte(statement.BindQuery(bind)), ctx)
+	case ClosePacket:
+		closeMessage, err := ParseCloseMessage(message.Payload())
+		if err == nil && closeMessage.Kind == 'S' {
+			delete(s.statements, closeMessage.Name)
+		}
+	}
+
+	return detection.Verdict{Status: detection.SAFE}
+}
+
+// statementText returns the text of the statement run by a Query, Parse or Bind
+// message, or an empty string when it isn't known.
+func (s *Session) statementText(message Message) string {
+	switch message.Header.PacketType {
+	case QueryPacket:
+		return strings.TrimRight(string(message.Payload()), "\x00")
+	case ParsePacket:
+		statement, err := ParseParseMessage(message.Payload())
+		if err != nil {
+			return ""
+		}
+		return statement.Query
+	case BindPacket:
+		bind, err := ParseBindMessage(message.Payload())
+		if err != nil {
+			return ""
+		}
+		return s.statements[bind.Statement].Query
+	}
+	return ""
+}
+
+func (s *Session) handleOutboundTraffic(messages []Message) error {
+	var written int
+	for _, message := range messages {
+		s.logger.Debug("outbound message", "type", GetPacketType(message.Header.PacketType), "length", message.Header.PacketLength)
+		written += len(message.Raw)
+		s.stats.recordOutbound(message)
+		s.proxy.Metrics.Messages.Inc(s.proxy.Name, metrics.Outbound, getPacketTypeLabel(message.Header.PacketType))
+		s.proxy.Metrics.BytesForwarded.Add(float64(len(message.Raw)), s.proxy.Name, metrics.Outbound)
+	}
+
+	if err := s.relayToClient(messages); err != nil {
+		return err
+	}
+
+	s.logger.Debug("forwarded target messages to client", "bytes", written)
+	return nil
+}
+
+// blockedQueryError describes a blocked query to the client, using SQLSTATE
+// and message of the matched rules when they configure them.
+func blockedQueryError(verdict detection.Verdict) ResponseFields {
+	sqlState, message := verdict.ClientError()
+	if sqlState == "" {
+		sqlState = DefaultBlockedSQLState
+	}
+	if message == "" {
+		message = DefaultBlockedMessage
+	}
+	return ResponseFields{Severity: ErrorSeverity, SQLState: sqlState, Message: message}
+}
+
+// blocking tells whether the session refuses malicious queries, rather than
+// only reporting them.
+func (s *Session) blocking() bool {
+	return s.mode == PreventionMode || s.mode == FullProtectionMode
+}
+
+func (s *Session) newSecurityEvent(verdict detection.Verdict) model.SecurityEvent {
+	action := model.ActionLogged
+	if s.blocking() {
+		action = model.ActionBlocked
+	}
+
+	return model.SecurityEvent{
+		Timestamp:     time.Now(),
+		ProxyID:       s.proxy.Id,
+		ProxyName:     s.proxy.Name,
+		SessionID:     s.id,
+		ClientAddress: s.clientAddress,
+		ClientIP:      GetClientIP(s.clientAddress),
+		DbUser:        s.User(),
+		Database:      s.Database(),
+		Query:         verdict.NormalizedQuery,
+		Severity:      verdict.Severity,
+		Score:         verdict.Score,
+		Rules:         strings.Join(verdict.RuleNames(), ","),
+		Verdict:       verdict.Explain(),
+		Action:        action,
+	}
+}
+
+func (s *Session) Id() string {
+	return s.id
+}
+
+func (s *Session) StartupMessage() StartupMessage {
+	s.stateLock.RLock()
+	defer s.stateLock.RUnlock()
+	return s.startupMessage
+}
+
+func (s *Session) StartupParameters() map[string]string {
+	return s.StartupMessage().Parameters
+}
+
+func (s *Session) User() string {
+	return s.StartupMessage().User()
+}
+
+func (s *Session) Database() string {
+	return s.StartupMessage().Database()
+}
+
+func (s *Session) ApplicationName() string {
+	return s.StartupMessage().ApplicationName()
+}
+
+func (s *Session) queryContext() detection.QueryContext {
+	return detection.QueryContext{
+		ClientAddress:   s.clientAddress,
+		User:            s.User(),
+		Database:        s.Database(),
+		ApplicationName: s.ApplicationName(),
+	}
+}
+
+// writeToClient serializes writes to the client connection, which is shared by
+// the outbound pump and replies generated by the proxy itself.
+func (s *Session) writeToClient(buff []byte) error {
+	s.clientWriteLock.Lock()
+	defer s.clientWriteLock.Unlock()
+
+	clientConn, _ := s.connections()
+	_, err := clientConn.Write(buff)
+	return err
+}
+
+// readMessages blocks until at least one message is available and then returns
+// it together with every other message which has already been received.
+func readMessages(reader *FrameReader) ([]Message, error) {
+	var messages []Message
+	for {
+		message, err := reader.ReadMessage()
+		if err != nil {
+			return nil, err
+		}
+		messages = append(messages, message)
+		if reader.Buffered() == 0 {
+			return messages, nil
+		}
+	}
+}
+
+// GetClientIP strips the port from a remote address.
+func GetClientIP(address string) string {
+	host, _, err := net.SplitHostPort(address)
+	if err != nil {
+		return address
+	}
+	return host
+}
+
+func (s *Session) logConnectionError(err error) {
+	if err == io.EOF || strings.Contains(err.Error(), "use of closed network connection") {
+		s.logger.Debug("connection closed")
+		return
+	}
+	s.logger.Warn("failed to process session traffic", "error", err)
+}
@@ -0,0 +1,72 @@
+package relational
+
+import (
+	"sync/atomic"
+	"time"
+)
+
+// SessionInfo is a read-only view of a session exposed outside of the proxy.
+// Inbound traffic goes from the client to the target, outbound the other way.
+type SessionInfo struct {
+	Id                string
+	ClientAddress     string
+	User              string
+	Database          string
+	ApplicationName   string
+	StartupParameters map[string]string
+	StartedAt         time.Time
+	TransactionStatus string
+	BytesInbound      uint64
+	BytesOutbound     uint64
+	MessagesInbound   uint64
+	MessagesOutbound  uint64
+	Queries           uint64
+	Detections        uint64
+}
+
+type sessionStats struct {
+	bytesInbound     uint64
+	bytesOutbound    uint64
+	messagesInbound  uint64
+	messagesOutbound uint64
+	queries          uint64
+	detections       uint64
+}
+
+func (ss *sessionStats) recordInbound(message Message) {
+	atomic.AddUint64(&ss.bytesInbound, uint64(len(message.Raw)))
+	atomic.AddUint64(&ss.messagesInbound, 1)
+	switch message.Header.PacketType {
+	case QueryPacket, ExecutePacket:
+		atomic.AddUint64(&ss.queries, 1)
+	}
+}
+
+func (ss *sessionStats) recordOutbound(message Message) {
+	atomic.AddUint64(&ss.bytesOutbound, uint64(len(message.Raw)))
+	atomic.AddUint64(&ss.messagesOutbound, 1)
+}
+
+func (ss *sessionStats) recordDetection() {
+	atomic.AddUint64(&ss.detections, 1)
+}
+
+func (s *Session) Info() SessionInfo {
+	startupMessage := s.StartupMessage()
+	return SessionInfo{
+		Id:                s.id,
+		ClientAddress:     s.clientAddress,
+		User:              startupMessage.User(),
+		Database:          startupMessage.Database(),
+		ApplicationName:   startupMessage.ApplicationName(),
+		StartupParameters: startupMessage.Parameters,
+		StartedAt:         s.startedAt,
+		TransactionStatus: string(s.TransactionStatus()),
+		BytesInbound:      atomic.LoadUint64(&s.stats.bytesInbound),
+		BytesOutbound:     atomic.LoadUint64(&s.stats.bytesOutbound),
+		MessagesInbound:   atomic.LoadUint64(&s.stats.messagesInbound),
+		MessagesOutbound:  atomic.LoadUint64(&s.stats.messagesOutbound),
+		Queries:           atomic.LoadUint64(&s.stats.queries),
+		Detections:        atomic.LoadUint64(&s.stats.detections),
+	}
+}
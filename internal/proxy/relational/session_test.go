@@ -0,0 +1,391 @@
+package relational
+
+import (
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/tls"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/binary"
+	"math/big"
+	"net"
+	"proxy-engineering-thesis/internal/proxy/blacklist"
+	"proxy-engineering-thesis/internal/proxy/detection"
+	"proxy-engineering-thesis/model"
+	"strings"
+	"testing"
+	"time"
+)
+
+const testTimeout = 5 * time.Second
+
+// connPair returns both ends of a loopback TCP connection. Unlike net.Pipe, it
+// buffers writes, so pipelined messages don't deadlock the pumps.
+func connPair(t *testing.T) (net.Conn, net.Conn) {
+	t.Helper()
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatalf("failed to listen: %v", err)
+	}
+	defer listener.Close()
+
+	accepted := make(chan net.Conn, 1)
+	go func() {
+		conn, _ := listener.Accept()
+		accepted <- conn
+	}()
+	dialed, err := net.Dial("tcp", listener.Addr().String())
+	if err != nil {
+		t.Fatalf("failed to dial: %v", err)
+	}
+	conn := <-accepted
+	if conn == nil {
+		t.Fatal("failed to accept")
+	}
+	t.Cleanup(func() {
+		dialed.Close()
+		conn.Close()
+	})
+	return dialed, conn
+}
+
+// fakeTarget emulates what the proxy relies on of a PostgreSQL server: the
+// transaction status, failed transactions, errors of the failing messages sent
+// in place of refused ones and skipping to Sync after an extended query error.
+type fakeTarget struct {
+	conn     net.Conn
+	reader   *FrameReader
+	status   byte
+	skipping bool
+	// received are the typed messages read from the proxy
+	received chan Message
+}
+
+func newFakeTarget(conn net.Conn) *fakeTarget {
+	target := &fakeTarget{
+		conn:     conn,
+		reader:   NewFrameReader(conn, DefaultBufferSize, MaxMessageLength),
+		status:   TransactionIdle,
+		received: make(chan Message, 100),
+	}
+	go target.serve()
+	return target
+}
+
+func (ft *fakeTarget) serve() {
+	if _, err := ft.reader.ReadStartupMessage(); err != nil {
+		return
+	}
+	ft.write(encodeMessage('R', []byte{0, 0, 0, 0}), NewReadyForQuery(ft.status))
+
+	for {
+		message, err := ft.reader.ReadMessage()
+		if err != nil {
+			return
+		}
+		ft.received <- message
+
+		packetType := message.Header.PacketType
+		if ft.skipping && packetType != SyncPacket && packetType != TerminatePacket {
+			continue
+		}
+		switch packetType {
+		case QueryPacket:
+			ft.write(ft.query(strings.TrimRight(string(message.Payload()), "\x00")), NewReadyForQuery(ft.status))
+			// errors of simple queries end with ReadyForQuery, nothing is skipped
+			ft.skipping = false
+		case ParsePacket:
+			ft.write(encodeMessage('1', nil))
+		case BindPacket:
+			ft.write(encodeMessage('2', nil))
+		case ExecutePacket:
+			portal, _, _ := readCString(message.Payload())
+			switch {
+			case portal != "":
+				ft.write(ft.fail("34000", `portal "`+portal+`" does not exist`))
+			case ft.status == TransactionFailed:
+				ft.write(ft.fail("25P02", "current transaction is aborted"))
+			default:
+				ft.write(encodeMessage(CommandCompletePacket, []byte("SELECT 1\x00")))
+			}
+		case SyncPacket:
+			ft.skipping = false
+			ft.write(NewReadyForQuery(ft.status))
+		case TerminatePacket:
+			return
+		}
+	}
+}
+
+func (ft *fakeTarget) query(query string) []byte {
+	command := strings.ToUpper(strings.Fields(query + " ")[0])
+	switch {
+	case query == refusalMarker:
+		return ft.fail("42601", `syntax error at or near "`+refusalMarker+`"`)
+	case command == "COMMIT" || command == "ROLLBACK":
+		if ft.status == TransactionFailed {
+			command = "ROLLBACK"
+		}
+		ft.status = TransactionIdle
+	case ft.status == TransactionFailed:
+		return ft.fail("25P02", "current transaction is aborted")
+	case command == "BEGIN":
+		ft.status = TransactionBlock
+	}
+	return encodeMessage(CommandCompletePacket, append([]byte(command), 0))
+}
+
+// fail answers with an error, which fails the transaction and the rest of the
+// extended query.
+func (ft *fakeTarget) fail(sqlState, message string) []byte {
+	if ft.status == TransactionBlock {
+		ft.status = TransactionFailed
+	}
+	ft.skipping = true
+	return NewErrorResponse(ResponseFields{Severity: ErrorSeverity, SQLState: sqlState, Message: message})
+}
+
+func (ft *fakeTarget) write(messages ...[]byte) {
+	var buff []byte
+	for _, message := range messages {
+		buff = append(buff, message...)
+	}
+	ft.conn.Write(buff)
+}
+
+// testClient talks to the proxy like a PostgreSQL client.
+type testClient struct {
+	t      *testing.T
+	conn   net.Conn
+	reader *FrameReader
+}
+
+func (c *testClient) send(messages ...[]byte) {
+	c.t.Helper()
+	var buff []byte
+	for _, message := range messages {
+		buff = append(buff, message...)
+	}
+	if _, err := c.conn.Write(buff); err != nil {
+		c.t.Fatalf("failed to send: %v", err)
+	}
+}
+
+// receive reads messages up to and including the next ReadyForQuery.
+func (c *testClient) receive() []Message {
+	c.t.Helper()
+	c.conn.SetReadDeadline(time.Now().Add(testTimeout))
+	var messages []Message
+	for {
+		message, err := c.reader.ReadMessage()
+		if err != nil {
+			c.t.Fatalf("failed to receive after %s: %v", describe(messages), err)
+		}
+		messages = append(messages, message)
+		if message.Header.PacketType == ReadyForQueryPacket {
+			return messages
+		}
+	}
+}
+
+// expect receives messages up to the next ReadyForQuery and compares them
+// with the description, see describe.
+func (c *testClient) expect(want string) {
+	c.t.Helper()
+	if got := describe(c.receive()); got != want {
+		c.t.Errorf("got %s, want %s", got, want)
+	}
+}
+
+// describe lists types of the messages, with SQLSTATE of errors and the
+// transaction status of ReadyForQuery, e.g. "C Z(T)" or "E(42000) Z(E)".
+func describe(messages []Message) string {
+	var parts []string
+	for _, message := range messages {
+		part := string(rune(message.Header.PacketType))
+		switch message.Header.PacketType {
+		case ErrorResponsePacket:
+			part += "(" + responseCode(message.Payload()) + ")"
+		case ReadyForQueryPacket:
+			part += "(" + string(message.Payload()) + ")"
+		}
+		parts = append(parts, part)
+	}
+	return strings.Join(parts, " ")
+}
+
+func responseCode(payload []byte) string {
+	for len(payload) > 0 && payload[0] != 0 {
+		code := payload[0]
+		value, rest, err := readCString(payload[1:])
+		if err != nil {
+			return ""
+		}
+		if code == 'C' {
+			return value
+		}
+		payload = rest
+	}
+	return ""
+}
+
+func queryMessage(text string) []byte {
+	return encodeMessage(QueryPacket, payload(text))
+}
+
+func parseMessage(statement, text string) []byte {
+	return encodeMessage(ParsePacket, payload(statement, text, int16(0)))
+}
+
+func bindMessage(portal, statement string) []byte {
+	return encodeMessage(BindPacket, payload(portal, statement, int16(0), int16(0), int16(0)))
+}
+
+func executeMessage(portal string) []byte {
+	return encodeMessage(ExecutePacket, payload(portal, int32(0)))
+}
+
+func syncMessage() []byte {
+	return encodeMessage(SyncPacket, nil)
+}
+
+func newTestProxy(t *testing.T, mode string, dto model.ProxyDto, blackList *blacklist.BlackListManager) *ProxyConfiguration {
+	t.Helper()
+	if dto.Name == "" {
+		dto.Name = "test"
+	}
+	proxy, err := NewProxy(dto, model.DataSource{}, mode, Services{
+		RuleSet:   detection.NewDefaultRuleSet(),
+		BlackList: blackList,
+	})
+	if err != nil {
+		t.Fatalf("failed to create proxy: %v", err)
+	}
+	return proxy
+}
+
+// startSession connects a client to a fake target through a session of the
+// proxy and completes the startup phase.
+func startSession(t *testing.T, proxy *ProxyConfiguration) (*testClient, *fakeTarget, *Session) {
+	t.Helper()
+	clientConn, sessionClientConn := connPair(t)
+	sessionTargetConn, targetConn := connPair(t)
+
+	target := newFakeTarget(targetConn)
+	session := proxy.newSession(sessionClientConn, sessionTargetConn)
+	go proxy.handleConnection(session)
+
+	client := &testClient{t: t, conn: clientConn, reader: NewFrameReader(clientConn, DefaultBufferSize, MaxMessageLength)}
+	client.send([]byte("\x00\x00\x00\x14\x00\x03\x00\x00user\x00alice\x00\x00"))
+	client.expect("R Z(I)")
+	return client, target, session
+}
+
+func TestOffenderBans(t *testing.T) {
+	tests := []struct {
+		name      string
+		mode      string
+		rateLimit float64
+		want      string
+	}{
+		{name: "detection mode passes offenders through", mode: "detection", want: "C Z(I)"},
+		{name: "detection mode with a rate limit", mode: "detection", rateLimit: 100, want: "E(" + RateLimitSQLState + ") Z(I)"},
+		{name: "prevention mode", mode: "prevention", want: "E(" + RateLimitSQLState + ") Z(I)"},
+		{name: "full protection mode", mode: "full", want: "E(" + RateLimitSQLState + ") Z(I)"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			blackList := blacklist.NewBlackListManager(blacklist.NewAccessList(), blacklist.DefaultConfig())
+			blackList.UpdateCache(blacklist.Key("127.0.0.1", 1, blacklist.GlobalScope))
+
+			proxy := newTestProxy(t, tt.mode, model.ProxyDto{RateLimit: tt.rateLimit}, blackList)
+			client, _, _ := startSession(t, proxy)
+
+			client.send(queryMessage("SELECT 1"))
+			client.expect(tt.want)
+		})
+	}
+}
+
+// testTLSConfig returns a server config with a self-signed certificate.
+func testTLSConfig(t *testing.T) *tls.Config {
+	t.Helper()
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatalf("failed to generate key: %v", err)
+	}
+	template := &x509.Certificate{
+		SerialNumber: big.NewInt(1),
+		Subject:      pkix.Name{CommonName: "localhost"},
+		NotBefore:    time.Now().Add(-time.Hour),
+		NotAfter:     time.Now().Add(time.Hour),
+	}
+	certificate, err := x509.CreateCertificate(rand.Reader, template, template, &key.PublicKey, key)
+	if err != nil {
+		t.Fatalf("failed to create certificate: %v", err)
+	}
+	return &tls.Config{Certificates: []tls.Certificate{{Certificate: [][]byte{certificate}, PrivateKey: key}}}
+}
+
+func TestTerminateDuringTLSHandshake(t *testing.T) {
+	serverConfig := testTLSConfig(t)
+	sslRequest := make([]byte, 8)
+	binary.BigEndian.PutUint32(sslRequest[:4], 8)
+	binary.BigEndian.PutUint32(sslRequest[4:], SSLRequestCode)
+
+	// sessions are terminated at different points of the handshake, before the
+	// connection is replaced by the encrypted one as well as after it
+	for i := 0; i < 20; i++ {
+		proxy := newTestProxy(t, "detection", model.ProxyDto{}, nil)
+		proxy.ClientTLSConfig = serverConfig
+		clientConn, sessionClientConn := connPair(t)
+		sessionTargetConn, targetConn := connPair(t)
+		newFakeTarget(targetConn)
+
+		session := proxy.newSession(sessionClientConn, sessionTargetConn)
+		done := make(chan struct{})
+		go func() {
+			proxy.handleConnection(session)
+			close(done)
+		}()
+
+		answer := make([]byte, 1)
+		clientConn.SetDeadline(time.Now().Add(testTimeout))
+		if _, err := clientConn.Write(sslRequest); err != nil {
+			t.Fatalf("failed to send SSL request: %v", err)
+		}
+		if _, err := clientConn.Read(answer); err != nil || answer[0] != 'S' {
+			t.Fatalf("SSL request answered with %q: %v", answer, err)
+		}
+
+		handshake := make(chan error, 1)
+		go func() {
+			handshake <- tls.Client(clientConn, &tls.Config{InsecureSkipVerify: true}).Handshake()
+		}()
+		time.Sleep(time.Duration(i) * time.Millisecond)
+		if err := proxy.TerminateSession(session.Id()); err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+
+		select {
+		case <-done:
+		case <-time.After(testTimeout):
+			t.Fatal("terminated session is still handled")
+		}
+		<-handshake
+		if sessions := proxy.GetNumberOfSessions(); sessions != 0 {
+			t.Fatalf("%d sessions left", sessions)
+		}
+
+		// the client connection is closed, encrypted or not
+		var err error
+		for err == nil {
+			_, err = clientConn.Read(make([]byte, 1024))
+		}
+		if netErr, ok := err.(net.Error); ok && netErr.Timeout() {
+			t.Fatal("client connection wasn't closed")
+		}
+	}
+}
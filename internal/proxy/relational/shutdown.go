@@ -0,0 +1,86 @@
+package relational
+
+import (
+	"context"
+	"time"
+)
+
+const drainPollInterval = 100 * time.Millisecond
+
+// Shutdown stops accepting connections and waits for sessions to become idle,
+// so that no transaction in progress is cut off. Idle sessions are terminated
+// with admin_shutdown error right away, the rest once the context is done.
+func (p *ProxyConfiguration) Shutdown(ctx context.Context) {
+	if p.Listener != nil {
+		p.Listener.Close()
+	}
+
+	ticker := time.NewTicker(drainPollInterval)
+	defer ticker.Stop()
+
+	for {
+		remaining := p.terminateSessions(false)
+		if remaining == 0 {
+			p.logger.Info("drained all sessions")
+			return
+		}
+
+		select {
+		case <-ctx.Done():
+			p.logger.Warn("shutdown deadline exceeded, terminating busy sessions", "sessions", remaining)
+			p.terminateSessions(true)
+			return
+		case <-ticker.C:
+		}
+	}
+}
+
+// terminateSessions terminates idle sessions, or all of them when forced, and
+// returns the number of sessions left running.
+func (p *ProxyConfiguration) terminateSessions(force bool) int {
+	p.sessionsLock.Lock()
+	sessions := make([]*Session, 0, len(p.Sessions))
+	for _, s := range p.Sessions {
+		sessions = append(sessions, s)
+	}
+	p.sessionsLock.Unlock()
+
+	remaining := 0
+	for _, s := range sessions {
+		if !force && !s.IsIdle() {
+			remaining++
+			continue
+		}
+		s.Terminate(ResponseFields{Severity: FatalSeverity, SQLState: AdminShutdownSQLState, Message: AdminShutdownMessage})
+	}
+	return remaining
+}
+
+// IsIdle tells whether the session is outside of a transaction block and has
+// no queries in progress, including extended queries waiting for Sync.
+func (s *Session) IsIdle() bool {
+	s.replyLock.Lock()
+	defer s.replyLock.Unlock()
+
+	return s.transactionStatus == TransactionIdle &&
+		s.readyForQueryReceived >= s.readyForQueryExpected &&
+		s.pendingExtended == 0
+}
+
+// Terminate sends the error to the client, asks the target to end the session
+// and closes both connections.
+func (s *Session) Terminate(fields ResponseFields) {
+	s.replyLock.Lock()
+	if err := s.writeToClient(NewErrorResponse(fields)); err != nil {
+		s.logger.Warn("failed to notify client about termination", "error", err)
+	}
+	s.replyLock.Unlock()
+
+	_, targetConn := s.connections()
+	if _, err := targetConn.Write(encodeMessage(TerminatePacket, nil)); err != nil {
+		s.logger.Warn("failed to terminate target session", "error", err)
+	}
+
+	s.logger.Info("terminated session", "sqlstate", fields.SQLState, "reason", fields.Message)
+	s.Close()
+}
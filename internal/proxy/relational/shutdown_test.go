@@ -0,0 +1,55 @@
+package relational
+
+import (
+	"context"
+	"proxy-engineering-thesis/model"
+	"testing"
+	"time"
+)
+
+func TestShutdownWaitsForSync(t *testing.T) {
+	proxy := newTestProxy(t, "detection", model.ProxyDto{}, nil)
+	client, _, session := startSession(t, proxy)
+
+	// the target answers Parse and Bind right away, ReadyForQuery only comes
+	// after Sync
+	client.send(parseMessage("", "SELECT 1"), bindMessage("", ""))
+	client.conn.SetReadDeadline(time.Now().Add(testTimeout))
+	for _, want := range []int{'1', '2'} {
+		message, err := client.reader.ReadMessage()
+		if err != nil || message.Header.PacketType != want {
+			t.Fatalf("got %s, want %c: %v", describe([]Message{message}), want, err)
+		}
+	}
+	if session.IsIdle() {
+		t.Fatal("session waiting for Sync is idle")
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), testTimeout)
+	defer cancel()
+	stopped := make(chan struct{})
+	go func() {
+		proxy.Shutdown(ctx)
+		close(stopped)
+	}()
+
+	time.Sleep(2 * drainPollInterval)
+	select {
+	case <-stopped:
+		t.Fatal("session was terminated before Sync")
+	default:
+	}
+
+	client.send(executeMessage(""), syncMessage())
+	client.expect("C Z(I)")
+
+	select {
+	case <-stopped:
+	case <-time.After(testTimeout):
+		t.Fatal("idle session wasn't terminated")
+	}
+	message, err := client.reader.ReadMessage()
+	if err != nil || responseCode(message.Payload()) != AdminShutdownSQLState {
+		t.Errorf("got %s after Sync, want E(%s): %v", describe([]Message{message}), AdminShutdownSQLState, err)
+	}
+}
@@ -1,15 +0,0 @@
-package relational
-
-// messgae:
-func GetMaliciousActivityDetectedError() []byte {
-	return []byte{
-		0x45, 0x00, 0x00, 0x00, 0x30, 0x53, 0x45,
-		0x52, 0x52, 0x4f, 0x52, 0x00, 0x56, 0x45,
-		0x52, 0x52, 0x4f, 0x52, 0x00, 0x43, 0x34,
-		0x32, 0x30, 0x30, 0x30, 0x00, 0x4d, 0x20,
-		0x4d, 0x41, 0x4c, 0x49, 0x43, 0x49, 0x4f,
-		0x55, 0x53, 0x20, 0x41, 0x43, 0x54, 0x49,
-		0x56, 0x49, 0x54, 0x59, 0x20, 0x00, 0x00,
-		0x5a, 0x00, 0x00, 0x00, 0x05, 0x49}
-
-}
@@ -0,0 +1,83 @@
+package relational
+
+import (
+	"bytes"
+	"encoding/binary"
+	"fmt"
+)
+
+const (
+	CancelRequestCode = 80877102
+	SSLRequestCode    = 80877103
+	GSSENCRequestCode = 80877104
+	ProtocolVersion3  = 3
+)
+
+// StartupMessage holds the protocol version and run-time parameters sent by the
+// client when opening the session.
+type StartupMessage struct {
+	ProtocolVersion int
+	Parameters      map[string]string
+}
+
+// GetStartupCode returns the protocol version or the special request code
+// carried right after the length of an untyped startup-phase message.
+func GetStartupCode(message Message) int {
+	payload := message.Payload()
+	if len(payload) < 4 {
+		return 0
+	}
+	return int(binary.BigEndian.Uint32(payload[:4]))
+}
+
+// ParseStartupMessage decodes the parameters of a StartupMessage, which is a
+// list of null-terminated name and value pairs ended with an empty name.
+func ParseStartupMessage(message Message) (StartupMessage, error) {
+	startup := StartupMessage{
+		ProtocolVersion: GetStartupCode(message),
+		Parameters:      make(map[string]string),
+	}
+	if startup.ProtocolVersion>>16 != ProtocolVersion3 {
+		return startup, fmt.Errorf("unsupported protocol version: %d.%d", startup.ProtocolVersion>>16, startup.ProtocolVersion&0xFFFF)
+	}
+
+	payload := message.Payload()[4:]
+	for len(payload) > 0 && payload[0] != 0 {
+		name, rest, err := readCString(payload)
+		if err != nil {
+			return startup, err
+		}
+		value, rest, err := readCString(rest)
+		if err != nil {
+			return startup, err
+		}
+		startup.Parameters[name] = value
+		payload = rest
+	}
+
+	return startup, nil
+}
+
+func (sm StartupMessage) User() string {
+	return sm.Parameters["user"]
+}
+
+// Database returns the requested database, which defaults to the user name.
+func (sm StartupMessage) Database() string {
+	if database, present := sm.Parameters["database"]; present && database != "" {
+		return database
+	}
+	return sm.User()
+}
+
+func (sm StartupMessage) ApplicationName() string {
+	return sm.Parameters["application_name"]
+}
+
+func readCString(buff []byte) (string, []byte, error) {
+	end := bytes.IndexByte(buff, 0)
+	if end < 0 {
+		return "", nil, fmt.Errorf("missing string terminator")
+	}
+	return string(buff[:end]), buff[end+1:], nil
+}
@@ -0,0 +1,101 @@
+package relational
+
+import (
+	"crypto/tls"
+	"crypto/x509"
+	"encoding/binary"
+	"fmt"
+	"net"
+	"os"
+	"proxy-engineering-thesis/model"
+	"strings"
+)
+
+const (
+	SSLModeDisable    = "disable"
+	SSLModeRequire    = "require"
+	SSLModeVerifyFull = "verify-full"
+)
+
+// NewClientTLSConfig returns configuration used to terminate TLS requested by
+// clients, or nil when the proxy has no certificate configured.
+func NewClientTLSConfig(dto model.ProxyDto) (*tls.Config, error) {
+	if dto.TLSCertificateFile == "" && dto.TLSKeyFile == "" {
+		return nil, nil
+	}
+
+	certificate, err := tls.LoadX509KeyPair(dto.TLSCertificateFile, dto.TLSKeyFile)
+	if err != nil {
+		return nil, fmt.Errorf("failed to load proxy certificate: %v", err)
+	}
+
+	return &tls.Config{
+		Certificates: []tls.Certificate{certificate},
+		MinVersion:   tls.VersionTLS12,
+	}, nil
+}
+
+// NewTargetTLSConfig returns configuration used to connect to the target
+// datasource, or nil when TLS is disabled for it.
+func NewTargetTLSConfig(ds model.DataSource) (*tls.Config, error) {
+	switch GetSSLMode(ds.SSLMode) {
+	case SSLModeDisable:
+		return nil, nil
+	case SSLModeRequire:
+		return &tls.Config{
+			InsecureSkipVerify: true,
+			MinVersion:         tls.VersionTLS12,
+		}, nil
+	case SSLModeVerifyFull:
+		config := &tls.Config{
+			ServerName: ds.Hostname,
+			MinVersion: tls.VersionTLS12,
+		}
+		if ds.SSLRootCertFile != "" {
+			pem, err := os.ReadFile(ds.SSLRootCertFile)
+			if err != nil {
+				return nil, fmt.Errorf("failed to read datasource root certificate: %v", err)
+			}
+			pool := x509.NewCertPool()
+			if !pool.AppendCertsFromPEM(pem) {
+				return nil, fmt.Errorf("no certificates found in %s", ds.SSLRootCertFile)
+			}
+			config.RootCAs = pool
+		}
+		return config, nil
+	}
+
+	return nil, fmt.Errorf("unsupported ssl mode: %s", ds.SSLMode)
+}
+
+func GetSSLMode(mode string) string {
+	if mode == "" {
+		return SSLModeDisable
+	}
+	return strings.ToLower(mode)
+}
+
+// upgradeTargetConnection asks the target to switch to TLS with SSLRequest and
+// performs the handshake if it agrees.
+func upgradeTargetConnection(conn net.Conn, reader *FrameReader, config *tls.Config) (net.Conn, error) {
+	request := make([]byte, 8)
+	binary.BigEndian.PutUint32(request[:4], 8)
+	binary.BigEndian.PutUint32(request[4:], SSLRequestCode)
+	if _, err := conn.Write(request); err != nil {
+		return nil, err
+	}
+
+	answer, err := reader.ReadByte()
+	if err != nil {
+		return nil, err
+	}
+	if answer != 'S' {
+		return nil, fmt.Errorf("target refused to establish TLS connection")
+	}
+
+	tlsConn := tls.Client(conn, config)
+	if err := tlsConn.Handshake(); err != nil {
+		return nil, fmt.Errorf("TLS handshake with target failed: %v", err)
+	}
+	return tlsConn, nil
+}
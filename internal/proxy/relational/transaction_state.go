@@ -0,0 +1,188 @@
+package relational
+
+import (
+	"proxy-engineering-thesis/internal/proxy/detection"
+	"proxy-engineering-thesis/model"
+	"strings"
+	"time"
+)
+
+// refusalMarker names the portal executed, or the statement run, in place of
+// refused messages. It's quoted by the errors they fail with, which tells them
+// apart from errors of the client's own messages.
+const refusalMarker = "goharder_refused"
+
+// failingQuery fails to parse, without side effects and in a failed
+// transaction too.
+func failingQuery() []byte {
+	return encodeMessage(QueryPacket, append([]byte(refusalMarker), 0))
+}
+
+// failingExecute executes a portal which doesn't exist.
+func failingExecute() []byte {
+	// the portal name is followed by the row limit, none
+	body := append([]byte(refusalMarker), 0, 0, 0, 0, 0)
+	return encodeMessage(ExecutePacket, body)
+}
+
+// refusal is a message refused by the proxy. The target is sent a message
+// failing in its place, so that it fails the transaction and skips the rest
+// of the extended query just like after an error of its own, and the error it
+// answers with is replaced by the one of the proxy.
+type refusal struct {
+	// cycle is the number of the ReadyForQuery ending the failing message
+	cycle  uint64
+	fields ResponseFields
+}
+
+// inFlightStatement is a statement forwarded to the target, timed until the
+// target answers it with ReadyForQuery.
+type inFlightStatement struct {
+	started time.Time
+	query   string
+}
+
+// slowStatement is a statement which took longer than the slow query
+// threshold of the proxy.
+type slowStatement struct {
+	query    string
+	duration time.Duration
+}
+
+// expectReadyForQuery notes that a message forwarded to the target will be
+// answered with ReadyForQuery. The query is the text of the statement the
+// message ends, it's used to report the statement when it's slow.
+func (s *Session) expectReadyForQuery(query string) {
+	s.replyLock.Lock()
+	s.readyForQueryExpected++
+	s.inFlight = append(s.inFlight, inFlightStatement{started: time.Now(), query: query})
+	s.replyLock.Unlock()
+}
+
+// trackExtendedQuery notes messages of the extended query protocol, which
+// leave the session busy until Sync ends them. Refused and discarded messages
+// count too, the client waits for Sync either way.
+func (s *Session) trackExtendedQuery(packetType int) {
+	s.replyLock.Lock()
+	defer s.replyLock.Unlock()
+
+	switch packetType {
+	case ParsePacket, BindPacket, DescribePacket, ExecutePacket, ClosePacket, FlushPacket:
+		s.pendingExtended++
+	case SyncPacket:
+		s.pendingExtended = 0
+	}
+}
+
+// refuse answers the message with the error instead of the target. It returns
+// the messages to forward to the target in its place.
+func (s *Session) refuse(message Message, fields ResponseFields) []byte {
+	s.replyLock.Lock()
+	s.refusals = append(s.refusals, refusal{cycle: s.readyForQueryExpected + 1, fields: fields})
+	s.replyLock.Unlock()
+
+	switch message.Header.PacketType {
+	case QueryPacket, FunctionCallPacket:
+		s.expectReadyForQuery("")
+		return failingQuery()
+	case SyncPacket:
+		s.expectReadyForQuery("")
+		return append(failingExecute(), message.Raw...)
+	}
+	// the target skips messages until Sync after the error, so do we
+	s.discardUntilSync = true
+	return failingExecute()
+}
+
+// relayToClient forwards target messages to the client, keeping track of the
+// transaction status and replacing errors of refused messages.
+func (s *Session) relayToClient(messages []Message) error {
+	slowStatements, err := s.relay(messages)
+	// slow queries are recorded outside of the reply lock, storing them
+	// shouldn't hold back replies to the client
+	for _, statement := range slowStatements {
+		s.recordSlowQuery(statement)
+	}
+	return err
+}
+
+func (s *Session) relay(messages []Message) ([]slowStatement, error) {
+	s.replyLock.Lock()
+	defer s.replyLock.Unlock()
+
+	var slowStatements []slowStatement
+	var buff []byte
+	for _, message := range messages {
+		if message.Header.PacketType == ErrorResponsePacket && s.isRefusalError(message) {
+			buff = append(buff, NewErrorResponse(s.refusals[0].fields)...)
+			s.refusals = s.refusals[1:]
+			continue
+		}
+		buff = append(buff, message.Raw...)
+		if message.Header.PacketType != ReadyForQueryPacket {
+			continue
+		}
+
+		if payload := message.Payload(); len(payload) > 0 {
+			s.transactionStatus = payload[0]
+		}
+		s.readyForQueryReceived++
+		if len(s.inFlight) > 0 {
+			// the start of the session isn't timed
+			if statement := s.inFlight[0]; !statement.started.IsZero() {
+				duration := time.Since(statement.started)
+				s.proxy.Metrics.QueryDuration.Observe(duration.Seconds(), s.proxy.Name)
+				if s.proxy.isSlowQuery(duration) && statement.query != "" {
+					slowStatements = append(slowStatements, slowStatement{query: statement.query, duration: duration})
+				}
+			}
+			s.inFlight = s.inFlight[1:]
+		}
+		// a failing message is skipped when the target failed on an earlier
+		// message of the extended query, its error goes to the client instead
+		for len(s.refusals) > 0 && s.refusals[0].cycle <= s.readyForQueryReceived {
+			s.refusals = s.refusals[1:]
+		}
+	}
+
+	return slowStatements, s.writeToClient(buff)
+}
+
+func (s *Session) recordSlowQuery(statement slowStatement) {
+	s.proxy.Metrics.SlowQueries.Inc(s.proxy.Name)
+	if s.proxy.SlowQueryRecorder == nil {
+		return
+	}
+
+	tokens := detection.Tokenize(statement.query)
+	recorded := s.proxy.SlowQueryRecorder.RecordSlowQuery(model.SlowQuery{
+		Timestamp:   time.Now(),
+		ProxyID:     s.proxy.Id,
+		SessionID:   s.id,
+		DbUser:      s.User(),
+		Database:    s.Database(),
+		Fingerprint: detection.Fingerprint(tokens),
+		Query:       detection.Normalize(tokens),
+		DurationMs:  float64(statement.duration) / float64(time.Millisecond),
+	})
+	if !recorded {
+		s.proxy.Metrics.SlowQueriesDropped.Inc(s.proxy.Name)
+	}
+}
+
+// isRefusalError tells whether the error is the target's answer to the message
+// sent in place of the first refused one.
+func (s *Session) isRefusalError(message Message) bool {
+	if len(s.refusals) == 0 || s.refusals[0].cycle != s.readyForQueryReceived+1 {
+		return false
+	}
+	return strings.Contains(responseMessage(message.Payload()), refusalMarker)
+}
+
+// TransactionStatus returns the transaction status as last reported to the
+// client.
+func (s *Session) TransactionStatus() byte {
+	s.replyLock.Lock()
+	defer s.replyLock.Unlock()
+	return s.transactionStatus
+}
@@ -0,0 +1,231 @@
+package relational
+
+import (
+	"proxy-engineering-thesis/internal/proxy/blacklist"
+	"proxy-engineering-thesis/model"
+	"strings"
+	"testing"
+	"time"
+)
+
+const maliciousQuery = "SELECT 1 UNION SELECT 2"
+
+// exchange is a batch of messages sent by the client together with the
+// replies it expects, one description per ReadyForQuery, see describe.
+type exchange struct {
+	send [][]byte
+	want []string
+}
+
+func TestRefusals(t *testing.T) {
+	blocked := "E(" + DefaultBlockedSQLState + ")"
+
+	tests := []struct {
+		name      string
+		exchanges []exchange
+		// wantForwarded are simple queries the target was sent, in order
+		wantForwarded []string
+	}{
+		{
+			name: "simple query",
+			exchanges: []exchange{
+				{send: [][]byte{queryMessage(maliciousQuery)}, want: []string{blocked + " Z(I)"}},
+				{send: [][]byte{queryMessage("SELECT 1")}, want: []string{"C Z(I)"}},
+			},
+			wantForwarded: []string{refusalMarker, "SELECT 1"},
+		},
+		{
+			name: "transaction fails",
+			exchanges: []exchange{
+				{send: [][]byte{queryMessage("BEGIN")}, want: []string{"C Z(T)"}},
+				{send: [][]byte{queryMessage(maliciousQuery)}, want: []string{blocked + " Z(E)"}},
+				{send: [][]byte{queryMessage("SELECT 1")}, want: []string{"E(25P02) Z(E)"}},
+				{send: [][]byte{queryMessage("COMMIT")}, want: []string{"C Z(I)"}},
+			},
+			wantForwarded: []string{"BEGIN", refusalMarker, "SELECT 1", "COMMIT"},
+		},
+		{
+			name: "extended query before Sync",
+			exchanges: []exchange{
+				{
+					send: [][]byte{parseMessage("", maliciousQuery), bindMessage("", ""), executeMessage(""), syncMessage()},
+					want: []string{blocked + " Z(I)"},
+				},
+				{
+					send: [][]byte{parseMessage("", "SELECT 1"), bindMessage("", ""), executeMessage(""), syncMessage()},
+					want: []string{"1 2 C Z(I)"},
+				},
+			},
+		},
+		{
+			name: "extended query in a transaction",
+			exchanges: []exchange{
+				{send: [][]byte{queryMessage("BEGIN")}, want: []string{"C Z(T)"}},
+				{
+					send: [][]byte{
+						parseMessage("", "SELECT 1"), bindMessage("", ""), executeMessage(""),
+						parseMessage("", maliciousQuery), bindMessage("", ""), executeMessage(""), syncMessage(),
+					},
+					want: []string{"1 2 C " + blocked + " Z(E)"},
+				},
+				{
+					send: [][]byte{parseMessage("", "SELECT 1"), bindMessage("", ""), executeMessage(""), syncMessage()},
+					want: []string{"1 2 E(25P02) Z(E)"},
+				},
+				{send: [][]byte{queryMessage("ROLLBACK")}, want: []string{"C Z(I)"}},
+			},
+		},
+		{
+			name: "target fails before the refused message",
+			exchanges: []exchange{
+				{
+					// the target skips the failing message, so its own error is the
+					// only one the client gets
+					send: [][]byte{
+						parseMessage("", "SELECT 1"), bindMessage("p", ""), executeMessage("p"),
+						parseMessage("", maliciousQuery), syncMessage(),
+					},
+					want: []string{"1 2 E(34000) Z(I)"},
+				},
+				{send: [][]byte{queryMessage(maliciousQuery)}, want: []string{blocked + " Z(I)"}},
+			},
+		},
+		{
+			name: "pipelined messages",
+			exchanges: []exchange{
+				{
+					send: [][]byte{
+						queryMessage(maliciousQuery),
+						queryMessage("SELECT 1"),
+						parseMessage("", "SELECT 1"), bindMessage("", ""), executeMessage(""), syncMessage(),
+						parseMessage("", maliciousQuery), bindMessage("", ""), executeMessage(""), syncMessage(),
+						queryMessage(maliciousQuery),
+						queryMessage("SELECT 2"),
+					},
+					want: []string{
+						blocked + " Z(I)",
+						"C Z(I)",
+						"1 2 C Z(I)",
+						blocked + " Z(I)",
+						blocked + " Z(I)",
+						"C Z(I)",
+					},
+				},
+			},
+			wantForwarded: []string{refusalMarker, "SELECT 1", refusalMarker, "SELECT 2"},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			// the client is allowlisted, refused queries don't get it banned
+			accessList := blacklist.NewAccessList()
+			if err := accessList.Update([]model.BlacklistEntry{{Type: model.AllowEntry, Address: "127.0.0.1"}}); err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+			blackList := blacklist.NewBlackListManager(accessList, blacklist.DefaultConfig())
+
+			proxy := newTestProxy(t, "prevention", model.ProxyDto{}, blackList)
+			client, target, session := startSession(t, proxy)
+
+			for _, exchange := range tt.exchanges {
+				client.send(exchange.send...)
+				for _, want := range exchange.want {
+					client.expect(want)
+				}
+			}
+
+			// every ReadyForQuery the client was sent is accounted for
+			if !session.IsIdle() {
+				t.Errorf("session isn't idle, expected %d ReadyForQuery, received %d", session.readyForQueryExpected,
+					session.readyForQueryReceived)
+			}
+			if len(session.refusals) > 0 {
+				t.Errorf("%d refusals left", len(session.refusals))
+			}
+
+			if tt.wantForwarded == nil {
+				return
+			}
+			var forwarded []string
+			for len(target.received) > 0 {
+				message := <-target.received
+				if message.Header.PacketType == QueryPacket {
+					forwarded = append(forwarded, strings.TrimRight(string(message.Payload()), "\x00"))
+				}
+			}
+			if got, want := strings.Join(forwarded, ","), strings.Join(tt.wantForwarded, ","); got != want {
+				t.Errorf("target was sent %s, want %s", got, want)
+			}
+		})
+	}
+}
+
+// slowQueryRecorder collects slow queries, or drops them when it's full.
+type slowQueryRecorder struct {
+	full    bool
+	queries chan model.SlowQuery
+}
+
+func (r *slowQueryRecorder) RecordSlowQuery(query model.SlowQuery) bool {
+	if r.full {
+		return false
+	}
+	r.queries <- query
+	return true
+}
+
+func TestSlowQueries(t *testing.T) {
+	tests := []struct {
+		name        string
+		full        bool
+		wantDropped bool
+	}{
+		{name: "recorded"},
+		{name: "dropped by a full recorder", full: true, wantDropped: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			proxy := newTestProxy(t, "detection", model.ProxyDto{SlowQueryThreshold: "1ns"}, nil)
+			recorder := &slowQueryRecorder{full: tt.full, queries: make(chan model.SlowQuery, 1)}
+			proxy.SlowQueryRecorder = recorder
+			client, _, _ := startSession(t, proxy)
+
+			client.send(queryMessage("SELECT 1"))
+			client.expect("C Z(I)")
+
+			// slow queries are recorded after the reply is relayed to the client
+			want := `goharder_slow_queries_total{proxy="test"} 1`
+			if tt.wantDropped {
+				want = `goharder_slow_queries_dropped_total{proxy="test"} 1`
+			}
+			deadline := time.Now().Add(testTimeout)
+			for !strings.Contains(exposition(t, proxy), want) {
+				if time.Now().After(deadline) {
+					t.Fatalf("no %s", want)
+				}
+				time.Sleep(time.Millisecond)
+			}
+
+			if tt.wantDropped {
+				return
+			}
+			if query := <-recorder.queries; query.Query != "select 1" || query.DbUser != "alice" {
+				t.Errorf("recorded %+v", query)
+			}
+			if strings.Contains(exposition(t, proxy), "goharder_slow_queries_dropped_total{") {
+				t.Error("recorded slow query is counted as dropped")
+			}
+		})
+	}
+}
+
+func exposition(t *testing.T, proxy *ProxyConfiguration) string {
+	t.Helper()
+	var exposition strings.Builder
+	if err := proxy.Metrics.Registry.Write(&exposition); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	return exposition.String()
+}
@@ -1,19 +1,24 @@
 package utils
 
 import (
-	"log"
+	"os"
+	"proxy-engineering-thesis/internal/logger"
 	"strings"
 )
 
+var log = logger.New("server")
+
 func ErrorPanic(err error) {
 	if err != nil {
-		log.Fatalf("System failed: %v", err)
+		log.Error("system failed", "error", err)
+		os.Exit(1)
 	}
 }
 
 func HandleConnectionClosed(err error, handler func()) {
 	if err != nil && strings.Contains(err.Error(), "use of closed network connection") {
-		log.Fatalf("Connection closed")
+		log.Error("connection closed")
+		os.Exit(1)
 		handler()
 	}
 }
@@ -0,0 +1,40 @@
+package model
+
+import "gorm.io/gorm"
+
+const (
+	CloudWatchSink = "cloudwatch"
+	WebhookSink    = "webhook"
+	SyslogSink     = "syslog"
+	FileSink       = "file"
+)
+
+// AlertSink configures where alerts of a proxy are sent. Target is the URL of
+// a webhook, host:port of a syslog server or path of a file relative to the
+// file sink directory. Alerts less severe than MinSeverity are skipped, empty
+// MinSeverity lets all through.
+type AlertSink struct {
+	gorm.Model
+	ProxyID     uint `gorm:"index"`
+	Type        string
+	MinSeverity string
+	Target      string
+	// Network of syslog, udp or tcp
+	Network string
+	// Secret signs webhook payloads with HMAC-SHA256, it's masked in responses
+	Secret        string
+	MaxRetries    int
+	LogGroupName  string
+	LogStreamName string
+	Region        string
+	// Profile of the shared AWS config files providing credentials
+	Profile string
+	// Endpoint overrides the CloudWatch Logs endpoint of the region
+	Endpoint string
+	// FlushInterval of CloudWatch batches, e.g. 5s
+	FlushInterval string
+}
+
+func (AlertSink) TableName() string {
+	return "alert_sinks"
+}
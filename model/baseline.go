@@ -0,0 +1,21 @@
+package model
+
+import "gorm.io/gorm"
+
+const (
+	BaselineLearning  = "learning"
+	BaselineEnforcing = "enforcing"
+)
+
+// QueryFingerprint is a query shape, with literals stripped, which a database
+// user is known to send through a proxy.
+type QueryFingerprint struct {
+	gorm.Model
+	ProxyID     uint   `gorm:"uniqueIndex:idx_query_fingerprint"`
+	DbUser      string `gorm:"uniqueIndex:idx_query_fingerprint"`
+	Fingerprint string `gorm:"uniqueIndex:idx_query_fingerprint"`
+}
+
+func (QueryFingerprint) TableName() string {
+	return "query_fingerprints"
+}
@@ -0,0 +1,26 @@
+package model
+
+import (
+	"time"
+
+	"gorm.io/gorm"
+)
+
+const (
+	AllowEntry = "allow"
+	DenyEntry  = "deny"
+)
+
+// BlacklistEntry pins a client address or CIDR range as allowed or denied.
+// Entries without expiry are permanent.
+type BlacklistEntry struct {
+	gorm.Model
+	Type      string
+	Address   string
+	ExpiresAt *time.Time
+	Reason    string
+}
+
+func (BlacklistEntry) TableName() string {
+	return "blacklist_entries"
+}
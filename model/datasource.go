@@ -4,6 +4,8 @@ import "gorm.io/gorm"
 
 type DataSource struct {
 	gorm.Model
-	Address    `gorm:"embedded"`
-	Credential `gorm:"embedded"`
+	Address         `gorm:"embedded"`
+	Credential      `gorm:"embedded"`
+	SSLMode         string
+	SSLRootCertFile string
 }
@@ -0,0 +1,34 @@
+package model
+
+import (
+	"time"
+
+	"gorm.io/gorm"
+)
+
+const (
+	ActionLogged  = "logged"
+	ActionBlocked = "blocked"
+)
+
+type SecurityEvent struct {
+	gorm.Model
+	Timestamp     time.Time `gorm:"index"`
+	ProxyID       uint      `gorm:"index"`
+	ProxyName     string
+	SessionID     string `gorm:"index"`
+	ClientAddress string
+	ClientIP      string `gorm:"index"`
+	DbUser        string
+	Database      string
+	Query         string
+	Severity      string `gorm:"index"`
+	Score         int
+	Rules         string
+	Verdict       string
+	Action        string
+}
+
+func (SecurityEvent) TableName() string {
+	return "security_events"
+}
@@ -1,12 +1,36 @@
 package model
 
-import "gorm.io/gorm"
+import (
+	"time"
+
+	"gorm.io/gorm"
+)
 
 type ProxyDto struct {
 	gorm.Model
-	Name         string
-	Address      `gorm:"embedded"`
-	DataSourceID uint
+	Name               string
+	Address            `gorm:"embedded"`
+	DataSourceID       uint
+	TLSCertificateFile string
+	TLSKeyFile         string
+	DetectionEngine    string
+	BaselineMode       string
+	TrainingUntil      *time.Time
+	BlacklistScope     string
+	// RateLimit is the number of queries per second allowed for every client
+	// address and every db user, zero turns rate limiting off.
+	RateLimit         float64
+	RateLimitBurst    int
+	RateLimitAction   string
+	RateLimitMaxDelay string
+	// SlowQueryThreshold is a duration, e.g. "500ms", statements running
+	// longer are recorded as slow queries. Empty turns the tracking off.
+	SlowQueryThreshold string
+	// Running, RunMode and StartedAt keep the run state, so proxies can be
+	// restarted together with the server.
+	Running   bool
+	RunMode   string
+	StartedAt *time.Time
 }
 
 func (ProxyDto) TableName() string {
@@ -0,0 +1,17 @@
+package model
+
+import "gorm.io/gorm"
+
+type Rule struct {
+	gorm.Model
+	Name        string
+	PatternType string
+	Pattern     string
+	Severity    string
+	Enabled     bool
+	Description string
+	// SQLState and ErrorMessage override the error returned to clients whose
+	// queries are blocked because of this rule.
+	SQLState     string
+	ErrorMessage string
+}
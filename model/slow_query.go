@@ -0,0 +1,26 @@
+package model
+
+import (
+	"time"
+
+	"gorm.io/gorm"
+)
+
+// SlowQuery is a statement which took longer than the slow query threshold of
+// the proxy. Query is normalized, statements differing only in values share
+// the fingerprint.
+type SlowQuery struct {
+	gorm.Model
+	Timestamp   time.Time `gorm:"index"`
+	ProxyID     uint      `gorm:"index"`
+	SessionID   string
+	DbUser      string
+	Database    string
+	Fingerprint string `gorm:"index"`
+	Query       string
+	DurationMs  float64
+}
+
+func (SlowQuery) TableName() string {
+	return "slow_queries"
+}
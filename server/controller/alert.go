@@ -0,0 +1,102 @@
+package controller
+
+import (
+	"github.com/gin-gonic/gin"
+	"net/http"
+	"proxy-engineering-thesis/model"
+	"proxy-engineering-thesis/server/service"
+)
+
+type AlertController struct {
+	alertService service.AlertService
+}
+
+func NewAlertController(alertService service.AlertService) *AlertController {
+	return &AlertController{alertService: alertService}
+}
+
+func (ac *AlertController) GetSinks(ctx *gin.Context) {
+	id := ctx.Param("id")
+	sinks, err := ac.alertService.GetSinks(id)
+	if err != nil {
+		ctx.Data(http.StatusBadRequest, "text/plain; charset=utf-8", []byte(err.Error()))
+		return
+	}
+
+	ctx.JSON(http.StatusOK, sinks)
+}
+
+func (ac *AlertController) CreateSink(ctx *gin.Context) {
+	id := ctx.Param("id")
+	var req model.AlertSink
+	err := ctx.ShouldBindJSON(&req)
+	if err != nil {
+		ctx.Data(http.StatusBadRequest, "text/plain; charset=utf-8", []byte(err.Error()))
+		return
+	}
+
+	err = ac.alertService.CreateSink(id, &req)
+	if err != nil {
+		ctx.Data(http.StatusBadRequest, "text/plain; charset=utf-8", []byte(err.Error()))
+		return
+	}
+
+	ctx.JSON(http.StatusOK, req)
+}
+
+func (ac *AlertController) UpdateSink(ctx *gin.Context) {
+	id := ctx.Param("id")
+	sinkId, ok := parseId(ctx, "sinkId", "alert sink")
+	if !ok {
+		return
+	}
+
+	var req model.AlertSink
+	err := ctx.ShouldBindJSON(&req)
+	if err != nil {
+		ctx.Data(http.StatusBadRequest, "text/plain; charset=utf-8", []byte(err.Error()))
+		return
+	}
+
+	err = ac.alertService.UpdateSink(id, sinkId, &req)
+	if err != nil {
+		ctx.Data(http.StatusBadRequest, "text/plain; charset=utf-8", []byte(err.Error()))
+		return
+	}
+
+	ctx.JSON(http.StatusOK, req)
+}
+
+func (ac *AlertController) DeleteSink(ctx *gin.Context) {
+	id := ctx.Param("id")
+	sinkId, ok := parseId(ctx, "sinkId", "alert sink")
+	if !ok {
+		return
+	}
+
+	err := ac.alertService.DeleteSink(id, sinkId)
+	if err != nil {
+		ctx.Data(http.StatusBadRequest, "text/plain; charset=utf-8", []byte(err.Error()))
+		return
+	}
+
+	ctx.JSON(http.StatusOK, nil)
+}
+
+// TestSink sends a test alert to the sink, so that its configuration can be
+// checked before a real alert is raised.
+func (ac *AlertController) TestSink(ctx *gin.Context) {
+	id := ctx.Param("id")
+	sinkId, ok := parseId(ctx, "sinkId", "alert sink")
+	if !ok {
+		return
+	}
+
+	err := ac.alertService.TestSink(id, sinkId)
+	if err != nil {
+		ctx.Data(http.StatusBadRequest, "text/plain; charset=utf-8", []byte(err.Error()))
+		return
+	}
+
+	ctx.JSON(http.StatusOK, nil)
+}
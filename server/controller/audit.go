@@ -1,12 +1,15 @@
 package controller
 
 import (
-	"fmt"
 	"github.com/gin-gonic/gin"
+	"net/http"
+	"proxy-engineering-thesis/internal/logger"
 	"proxy-engineering-thesis/model/relational"
 	"proxy-engineering-thesis/server/service"
 )
 
+var log = logger.New("controller")
+
 type AuditController struct {
 	auditService service.AuditService
 }
@@ -20,14 +23,14 @@ func (ac *AuditController) PerformAudit(ctx *gin.Context) {
 	id := ctx.Param("id")
 	err := ctx.ShouldBindJSON(&config)
 	if err != nil {
-		fmt.Printf("failed to parse audit configuration object: %v\n", err)
+		log.Error("failed to parse audit configuration object", "error", err)
 		ctx.JSON(400, nil)
 		return
 	}
 	auditResult, err := ac.auditService.PerformAudit(id, config)
 	if err != nil {
-		fmt.Printf("failed to perform audit: %v\n", err)
-		ctx.JSON(400, nil)
+		log.Error("failed to perform audit", "error", err)
+		ctx.Data(http.StatusBadRequest, "text/plain; charset=utf-8", []byte(err.Error()))
 		return
 	}
 
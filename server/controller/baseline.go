@@ -0,0 +1,97 @@
+package controller
+
+import (
+	"github.com/gin-gonic/gin"
+	"net/http"
+	"proxy-engineering-thesis/model"
+	"proxy-engineering-thesis/server/service"
+)
+
+type BaselineController struct {
+	baselineService service.BaselineService
+}
+
+func NewBaselineController(baselineService service.BaselineService) *BaselineController {
+	return &BaselineController{baselineService: baselineService}
+}
+
+func (bc *BaselineController) Export(ctx *gin.Context) {
+	id := ctx.Param("id")
+	baseline, err := bc.baselineService.Export(id)
+	if err != nil {
+		ctx.Data(http.StatusBadRequest, "text/plain; charset=utf-8", []byte(err.Error()))
+		return
+	}
+
+	ctx.JSON(http.StatusOK, baseline)
+}
+
+func (bc *BaselineController) Import(ctx *gin.Context) {
+	id := ctx.Param("id")
+	var req service.Baseline
+	err := ctx.ShouldBindJSON(&req)
+	if err != nil {
+		ctx.Data(http.StatusBadRequest, "text/plain; charset=utf-8", []byte(err.Error()))
+		return
+	}
+
+	err = bc.baselineService.Import(id, req)
+	if err != nil {
+		ctx.Data(http.StatusBadRequest, "text/plain; charset=utf-8", []byte(err.Error()))
+		return
+	}
+
+	ctx.JSON(http.StatusOK, nil)
+}
+
+func (bc *BaselineController) AddFingerprint(ctx *gin.Context) {
+	id := ctx.Param("id")
+	var req model.QueryFingerprint
+	err := ctx.ShouldBindJSON(&req)
+	if err != nil {
+		ctx.Data(http.StatusBadRequest, "text/plain; charset=utf-8", []byte(err.Error()))
+		return
+	}
+
+	err = bc.baselineService.AddFingerprint(id, &req)
+	if err != nil {
+		ctx.Data(http.StatusBadRequest, "text/plain; charset=utf-8", []byte(err.Error()))
+		return
+	}
+
+	ctx.JSON(http.StatusOK, req)
+}
+
+func (bc *BaselineController) DeleteFingerprint(ctx *gin.Context) {
+	id := ctx.Param("id")
+	fingerprintId, ok := parseId(ctx, "fingerprintId", "fingerprint")
+	if !ok {
+		return
+	}
+
+	err := bc.baselineService.DeleteFingerprint(id, fingerprintId)
+	if err != nil {
+		ctx.Data(http.StatusBadRequest, "text/plain; charset=utf-8", []byte(err.Error()))
+		return
+	}
+
+	ctx.JSON(http.StatusOK, nil)
+}
+
+func (bc *BaselineController) SetMode(ctx *gin.Context) {
+	id := ctx.Param("id")
+	var req service.BaselineModeRequest
+	err := ctx.ShouldBindJSON(&req)
+	if err != nil {
+		ctx.Data(http.StatusBadRequest, "text/plain; charset=utf-8", []byte(err.Error()))
+		return
+	}
+
+	baseline, err := bc.baselineService.SetMode(id, req)
+	if err != nil {
+		ctx.Data(http.StatusBadRequest, "text/plain; charset=utf-8", []byte(err.Error()))
+		return
+	}
+
+	ctx.JSON(http.StatusOK, baseline)
+}
@@ -0,0 +1,95 @@
+package controller
+
+import (
+	"github.com/gin-gonic/gin"
+	"net/http"
+	"proxy-engineering-thesis/model"
+	"proxy-engineering-thesis/server/service"
+)
+
+type BlacklistController struct {
+	blacklistService service.BlacklistService
+}
+
+func NewBlacklistController(blacklistService service.BlacklistService) *BlacklistController {
+	return &BlacklistController{blacklistService: blacklistService}
+}
+
+func (bc *BlacklistController) Create(ctx *gin.Context) {
+	var req model.BlacklistEntry
+	err := ctx.ShouldBindJSON(&req)
+	if err != nil {
+		ctx.Data(http.StatusBadRequest, "text/plain; charset=utf-8", []byte(err.Error()))
+		return
+	}
+
+	err = bc.blacklistService.Create(&req)
+	if err != nil {
+		ctx.Data(http.StatusBadRequest, "text/plain; charset=utf-8", []byte(err.Error()))
+		return
+	}
+
+	ctx.JSON(http.StatusOK, req)
+}
+
+func (bc *BlacklistController) Update(ctx *gin.Context) {
+	id, ok := parseId(ctx, "id", "blacklist entry")
+	if !ok {
+		return
+	}
+
+	var req model.BlacklistEntry
+	err := ctx.ShouldBindJSON(&req)
+	if err != nil {
+		ctx.Data(http.StatusBadRequest, "text/plain; charset=utf-8", []byte(err.Error()))
+		return
+	}
+
+	err = bc.blacklistService.Update(id, &req)
+	if err != nil {
+		ctx.Data(http.StatusBadRequest, "text/plain; charset=utf-8", []byte(err.Error()))
+		return
+	}
+
+	ctx.JSON(http.StatusOK, req)
+}
+
+func (bc *BlacklistController) Delete(ctx *gin.Context) {
+	id, ok := parseId(ctx, "id", "blacklist entry")
+	if !ok {
+		return
+	}
+
+	err := bc.blacklistService.Delete(id)
+	if err != nil {
+		ctx.Data(http.StatusBadRequest, "text/plain; charset=utf-8", []byte(err.Error()))
+		return
+	}
+
+	ctx.JSON(http.StatusOK, nil)
+}
+
+func (bc *BlacklistController) GetAll(ctx *gin.Context) {
+	entries, err := bc.blacklistService.GetAll()
+	if err != nil {
+		ctx.JSON(http.StatusBadRequest, nil)
+		return
+	}
+
+	ctx.JSON(http.StatusOK, entries)
+}
+
+func (bc *BlacklistController) FindById(ctx *gin.Context) {
+	id, ok := parseId(ctx, "id", "blacklist entry")
+	if !ok {
+		return
+	}
+
+	entry, err := bc.blacklistService.GetById(id)
+	if err != nil {
+		ctx.JSON(http.StatusNotFound, nil)
+		return
+	}
+
+	ctx.JSON(http.StatusOK, entry)
+}
@@ -2,8 +2,10 @@ package controller
 
 import (
 	"github.com/gin-gonic/gin"
+	"net/http"
 	"proxy-engineering-thesis/model"
 	"proxy-engineering-thesis/server/service"
+	"strconv"
 )
 
 type DataSourceController struct {
@@ -26,14 +28,16 @@ func (pc *DataSourceController) Create(ctx *gin.Context) {
 }
 
 func (pc *DataSourceController) Delete(ctx *gin.Context) {
-	var name string
-	err := ctx.ShouldBindQuery(&name)
-	if err != nil {
-		ctx.JSON(400, nil)
+	id := ctx.Param("id")
+	if _, err := strconv.ParseUint(id, 10, 64); err != nil {
+		ctx.Data(http.StatusBadRequest, "text/plain; charset=utf-8", []byte("invalid datasource id: "+id))
+		return
 	}
-	err = pc.dataSourceService.Delete(name)
+
+	err := pc.dataSourceService.Delete(id)
 	if err != nil {
-		ctx.JSON(400, nil)
+		ctx.Data(http.StatusBadRequest, "text/plain; charset=utf-8", []byte(err.Error()))
+		return
 	}
 	ctx.JSON(200, nil)
 }
@@ -0,0 +1,80 @@
+package controller
+
+import (
+	"fmt"
+	"github.com/gin-gonic/gin"
+	"net/http"
+	"proxy-engineering-thesis/server/repository"
+	"proxy-engineering-thesis/server/service"
+	"strconv"
+	"strings"
+	"time"
+)
+
+type EventController struct {
+	eventService service.EventService
+}
+
+func NewEventController(eventService service.EventService) *EventController {
+	return &EventController{eventService: eventService}
+}
+
+// Find lists security events. Supported query parameters: proxy, from and to
+// (RFC 3339), severity (comma separated), clientIp, page and size.
+func (ec *EventController) Find(ctx *gin.Context) {
+	filter, err := parseEventFilter(ctx)
+	if err != nil {
+		ctx.Data(http.StatusBadRequest, "text/plain; charset=utf-8", []byte(err.Error()))
+		return
+	}
+
+	page, err := ec.eventService.Find(filter)
+	if err != nil {
+		ctx.Data(http.StatusInternalServerError, "text/plain; charset=utf-8", []byte(err.Error()))
+		return
+	}
+
+	ctx.JSON(http.StatusOK, page)
+}
+
+func parseEventFilter(ctx *gin.Context) (repository.EventFilter, error) {
+	var filter repository.EventFilter
+
+	if proxy := ctx.Query("proxy"); proxy != "" {
+		proxyId, err := strconv.ParseUint(proxy, 10, 64)
+		if err != nil {
+			return filter, fmt.Errorf("invalid proxy id: %s", proxy)
+		}
+		filter.ProxyID = uint(proxyId)
+	}
+
+	for name, target := range map[string]*time.Time{"from": &filter.From, "to": &filter.To} {
+		if value := ctx.Query(name); value != "" {
+			parsed, err := time.Parse(time.RFC3339, value)
+			if err != nil {
+				return filter, fmt.Errorf("invalid '%s' time, expected RFC 3339: %s", name, value)
+			}
+			*target = parsed
+		}
+	}
+
+	if severity := ctx.Query("severity"); severity != "" {
+		for _, s := range strings.Split(severity, ",") {
+			filter.Severities = append(filter.Severities, strings.ToLower(strings.TrimSpace(s)))
+		}
+	}
+
+	filter.ClientIP = ctx.Query("clientIp")
+
+	for name, target := range map[string]*int{"page": &filter.Page, "size": &filter.Size} {
+		if value := ctx.Query(name); value != "" {
+			parsed, err := strconv.Atoi(value)
+			if err != nil {
+				return filter, fmt.Errorf("invalid '%s' value: %s", name, value)
+			}
+			*target = parsed
+		}
+	}
+
+	return filter, nil
+}
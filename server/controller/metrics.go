@@ -0,0 +1,24 @@
+package controller
+
+import (
+	"github.com/gin-gonic/gin"
+	"net/http"
+	"proxy-engineering-thesis/internal/metrics"
+)
+
+type MetricsController struct {
+	proxyMetrics *metrics.ProxyMetrics
+}
+
+func NewMetricsController(proxyMetrics *metrics.ProxyMetrics) *MetricsController {
+	return &MetricsController{proxyMetrics: proxyMetrics}
+}
+
+// Get exposes metrics in the Prometheus text format.
+func (mc *MetricsController) Get(ctx *gin.Context) {
+	ctx.Status(http.StatusOK)
+	ctx.Header("Content-Type", "text/plain; version=0.0.4; charset=utf-8")
+	if err := mc.proxyMetrics.Registry.Write(ctx.Writer); err != nil {
+		ctx.Error(err)
+	}
+}
@@ -0,0 +1,19 @@
+package controller
+
+import (
+	"github.com/gin-gonic/gin"
+	"net/http"
+	"strconv"
+)
+
+// parseId reads an id path parameter of the named resource. Invalid ids are
+// answered with 400, in which case false is returned.
+func parseId(ctx *gin.Context, param, resource string) (uint, bool) {
+	value := ctx.Param(param)
+	id, err := strconv.ParseUint(value, 10, 64)
+	if err != nil {
+		ctx.Data(http.StatusBadRequest, "text/plain; charset=utf-8", []byte("invalid "+resource+" id: "+value))
+		return 0, false
+	}
+	return uint(id), true
+}
@@ -57,7 +57,8 @@ func (pc *ProxyController) StartProxy(ctx *gin.Context) {
 	mode := ctx.Query("mode")
 	proxyId, err := pc.proxyService.StartProxy(id, mode)
 	if err != nil {
-		ctx.Data(http.StatusBadRequest, "text/plain; charset=utf-8", []byte("No configuration found"))
+		ctx.Data(http.StatusBadRequest, "text/plain; charset=utf-8", []byte(err.Error()))
+		return
 	}
 
 	ctx.Data(http.StatusOK, "text/plain; charset=utf-8", []byte(proxyId))
@@ -68,6 +69,7 @@ func (pc *ProxyController) StopProxy(ctx *gin.Context) {
 	err := pc.proxyService.StopProxy(id)
 	if err != nil {
 		ctx.Data(http.StatusBadRequest, "text/plain; charset=utf-8", []byte(err.Error()))
+		return
 	}
 }
 
@@ -80,3 +82,37 @@ func (pc *ProxyController) GetProxySessionsCount(ctx *gin.Context) {
 
 	ctx.Data(http.StatusOK, "text/plain; charset=utf-8", []byte(strconv.Itoa(count)))
 }
+
+func (pc *ProxyController) GetProxySessions(ctx *gin.Context) {
+	id := ctx.Param("id")
+	sessions, err := pc.proxyService.GetProxySessions(id)
+	if err != nil {
+		ctx.Data(http.StatusBadRequest, "text/plain; charset=utf-8", []byte(err.Error()))
+		return
+	}
+
+	ctx.JSON(http.StatusOK, sessions)
+}
+
+func (pc *ProxyController) TerminateSession(ctx *gin.Context) {
+	id := ctx.Param("id")
+	sessionId := ctx.Param("sessionId")
+	err := pc.proxyService.TerminateSession(id, sessionId)
+	if err != nil {
+		ctx.Data(http.StatusBadRequest, "text/plain; charset=utf-8", []byte(err.Error()))
+		return
+	}
+
+	ctx.JSON(http.StatusOK, nil)
+}
+
+func (pc *ProxyController) GetRateLimitStats(ctx *gin.Context) {
+	id := ctx.Param("id")
+	stats, err := pc.proxyService.GetRateLimitStats(id)
+	if err != nil {
+		ctx.Data(http.StatusBadRequest, "text/plain; charset=utf-8", []byte(err.Error()))
+		return
+	}
+
+	ctx.JSON(http.StatusOK, stats)
+}
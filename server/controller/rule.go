@@ -0,0 +1,95 @@
+package controller
+
+import (
+	"github.com/gin-gonic/gin"
+	"net/http"
+	"proxy-engineering-thesis/model"
+	"proxy-engineering-thesis/server/service"
+)
+
+type RuleController struct {
+	ruleService service.RuleService
+}
+
+func NewRuleController(ruleService service.RuleService) *RuleController {
+	return &RuleController{ruleService: ruleService}
+}
+
+func (rc *RuleController) Create(ctx *gin.Context) {
+	var req model.Rule
+	err := ctx.ShouldBindJSON(&req)
+	if err != nil {
+		ctx.Data(http.StatusBadRequest, "text/plain; charset=utf-8", []byte(err.Error()))
+		return
+	}
+
+	err = rc.ruleService.Create(&req)
+	if err != nil {
+		ctx.Data(http.StatusBadRequest, "text/plain; charset=utf-8", []byte(err.Error()))
+		return
+	}
+
+	ctx.JSON(http.StatusOK, req)
+}
+
+func (rc *RuleController) Update(ctx *gin.Context) {
+	id, ok := parseId(ctx, "id", "rule")
+	if !ok {
+		return
+	}
+
+	var req model.Rule
+	err := ctx.ShouldBindJSON(&req)
+	if err != nil {
+		ctx.Data(http.StatusBadRequest, "text/plain; charset=utf-8", []byte(err.Error()))
+		return
+	}
+
+	err = rc.ruleService.Update(id, &req)
+	if err != nil {
+		ctx.Data(http.StatusBadRequest, "text/plain; charset=utf-8", []byte(err.Error()))
+		return
+	}
+
+	ctx.JSON(http.StatusOK, req)
+}
+
+func (rc *RuleController) Delete(ctx *gin.Context) {
+	id, ok := parseId(ctx, "id", "rule")
+	if !ok {
+		return
+	}
+
+	err := rc.ruleService.Delete(id)
+	if err != nil {
+		ctx.Data(http.StatusBadRequest, "text/plain; charset=utf-8", []byte(err.Error()))
+		return
+	}
+
+	ctx.JSON(http.StatusOK, nil)
+}
+
+func (rc *RuleController) GetAll(ctx *gin.Context) {
+	rules, err := rc.ruleService.GetAll()
+	if err != nil {
+		ctx.JSON(http.StatusBadRequest, nil)
+		return
+	}
+
+	ctx.JSON(http.StatusOK, rules)
+}
+
+func (rc *RuleController) FindById(ctx *gin.Context) {
+	id, ok := parseId(ctx, "id", "rule")
+	if !ok {
+		return
+	}
+
+	rule, err := rc.ruleService.GetById(id)
+	if err != nil {
+		ctx.JSON(http.StatusNotFound, nil)
+		return
+	}
+
+	ctx.JSON(http.StatusOK, rule)
+}
@@ -0,0 +1,41 @@
+package controller
+
+import (
+	"fmt"
+	"github.com/gin-gonic/gin"
+	"net/http"
+	"proxy-engineering-thesis/server/service"
+	"strconv"
+)
+
+type SlowQueryController struct {
+	slowQueryService service.SlowQueryService
+}
+
+func NewSlowQueryController(slowQueryService service.SlowQueryService) *SlowQueryController {
+	return &SlowQueryController{slowQueryService: slowQueryService}
+}
+
+// TopFingerprints lists the slowest fingerprints of a proxy. Supported query
+// parameters: limit and sort (total, max, avg or count).
+func (sc *SlowQueryController) TopFingerprints(ctx *gin.Context) {
+	id := ctx.Param("id")
+
+	var limit int
+	if value := ctx.Query("limit"); value != "" {
+		parsed, err := strconv.Atoi(value)
+		if err != nil {
+			ctx.Data(http.StatusBadRequest, "text/plain; charset=utf-8", []byte(fmt.Sprintf("invalid 'limit' value: %s", value)))
+			return
+		}
+		limit = parsed
+	}
+
+	stats, err := sc.slowQueryService.TopFingerprints(id, ctx.Query("sort"), limit)
+	if err != nil {
+		ctx.Data(http.StatusBadRequest, "text/plain; charset=utf-8", []byte(err.Error()))
+		return
+	}
+
+	ctx.JSON(http.StatusOK, stats)
+}
@@ -0,0 +1,66 @@
+package repository
+
+import (
+	"proxy-engineering-thesis/model"
+)
+
+type AlertSinkRepository interface {
+	Create(req *model.AlertSink) error
+	Update(req *model.AlertSink) error
+	Delete(proxyId uint, id uint) error
+	Get(proxyId uint, id uint) (*model.AlertSink, error)
+	GetByProxy(proxyId uint) ([]model.AlertSink, error)
+}
+
+type AlertSinkRepositoryImpl struct {
+	*DbContext
+}
+
+func NewAlertSinkRepositoryImpl(dbCtx *DbContext) *AlertSinkRepositoryImpl {
+	return &AlertSinkRepositoryImpl{dbCtx}
+}
+
+func (ar *AlertSinkRepositoryImpl) Create(req *model.AlertSink) error {
+	tx := ar.Db.Create(req)
+	if err := tx.Error; err != nil {
+		return tx.Error
+	}
+	return nil
+}
+
+func (ar *AlertSinkRepositoryImpl) Update(req *model.AlertSink) error {
+	tx := ar.Db.Save(req)
+	if err := tx.Error; err != nil {
+		return tx.Error
+	}
+	return nil
+}
+
+func (ar *AlertSinkRepositoryImpl) Delete(proxyId uint, id uint) error {
+	tx := ar.Db.Where("proxy_id = ?", proxyId).Delete(&model.AlertSink{}, id)
+	if err := tx.Error; err != nil {
+		return err
+	}
+
+	return nil
+}
+
+func (ar *AlertSinkRepositoryImpl) Get(proxyId uint, id uint) (*model.AlertSink, error) {
+	var sink model.AlertSink
+	tx := ar.Db.Where("proxy_id = ?", proxyId).First(&sink, id)
+	if err := tx.Error; err != nil {
+		return nil, tx.Error
+	}
+
+	return &sink, nil
+}
+
+func (ar *AlertSinkRepositoryImpl) GetByProxy(proxyId uint) ([]model.AlertSink, error) {
+	var sinks []model.AlertSink
+	tx := ar.Db.Where("proxy_id = ?", proxyId).Find(&sinks)
+	if err := tx.Error; err != nil {
+		return nil, tx.Error
+	}
+
+	return sinks, nil
+}
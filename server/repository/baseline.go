@@ -0,0 +1,61 @@
+package repository
+
+import (
+	"gorm.io/gorm"
+	"proxy-engineering-thesis/model"
+)
+
+type FingerprintRepository interface {
+	Create(req *model.QueryFingerprint) error
+	Delete(proxyId uint, id uint) error
+	GetByProxy(proxyId uint) ([]model.QueryFingerprint, error)
+	ReplaceForProxy(proxyId uint, fingerprints []model.QueryFingerprint) error
+}
+
+type FingerprintRepositoryImpl struct {
+	*DbContext
+}
+
+func NewFingerprintRepositoryImpl(dbCtx *DbContext) *FingerprintRepositoryImpl {
+	return &FingerprintRepositoryImpl{dbCtx}
+}
+
+func (fr *FingerprintRepositoryImpl) Create(req *model.QueryFingerprint) error {
+	tx := fr.Db.Create(req)
+	if err := tx.Error; err != nil {
+		return tx.Error
+	}
+	return nil
+}
+
+func (fr *FingerprintRepositoryImpl) Delete(proxyId uint, id uint) error {
+	tx := fr.Db.Unscoped().Where("proxy_id = ?", proxyId).Delete(&model.QueryFingerprint{}, id)
+	if err := tx.Error; err != nil {
+		return err
+	}
+
+	return nil
+}
+
+func (fr *FingerprintRepositoryImpl) GetByProxy(proxyId uint) ([]model.QueryFingerprint, error) {
+	var fingerprints []model.QueryFingerprint
+	tx := fr.Db.Where("proxy_id = ?", proxyId).Order("db_user, fingerprint").Find(&fingerprints)
+	if err := tx.Error; err != nil {
+		return nil, tx.Error
+	}
+
+	return fingerprints, nil
+}
+
+// ReplaceForProxy atomically swaps the whole baseline of a proxy.
+func (fr *FingerprintRepositoryImpl) ReplaceForProxy(proxyId uint, fingerprints []model.QueryFingerprint) error {
+	return fr.Db.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Unscoped().Where("proxy_id = ?", proxyId).Delete(&model.QueryFingerprint{}).Error; err != nil {
+			return err
+		}
+		if len(fingerprints) == 0 {
+			return nil
+		}
+		return tx.Create(&fingerprints).Error
+	})
+}
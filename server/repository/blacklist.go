@@ -0,0 +1,66 @@
+package repository
+
+import (
+	"proxy-engineering-thesis/model"
+)
+
+type BlacklistRepository interface {
+	Create(req *model.BlacklistEntry) error
+	Update(req *model.BlacklistEntry) error
+	Delete(id uint) error
+	Get(id uint) (*model.BlacklistEntry, error)
+	GetAll() ([]model.BlacklistEntry, error)
+}
+
+type BlacklistRepositoryImpl struct {
+	*DbContext
+}
+
+func NewBlacklistRepositoryImpl(dbCtx *DbContext) *BlacklistRepositoryImpl {
+	return &BlacklistRepositoryImpl{dbCtx}
+}
+
+func (br *BlacklistRepositoryImpl) Create(req *model.BlacklistEntry) error {
+	tx := br.Db.Create(req)
+	if err := tx.Error; err != nil {
+		return tx.Error
+	}
+	return nil
+}
+
+func (br *BlacklistRepositoryImpl) Update(req *model.BlacklistEntry) error {
+	tx := br.Db.Save(req)
+	if err := tx.Error; err != nil {
+		return tx.Error
+	}
+	return nil
+}
+
+func (br *BlacklistRepositoryImpl) Delete(id uint) error {
+	tx := br.Db.Delete(&model.BlacklistEntry{}, id)
+	if err := tx.Error; err != nil {
+		return err
+	}
+
+	return nil
+}
+
+func (br *BlacklistRepositoryImpl) Get(id uint) (*model.BlacklistEntry, error) {
+	var entry model.BlacklistEntry
+	tx := br.Db.First(&entry, id)
+	if err := tx.Error; err != nil {
+		return nil, tx.Error
+	}
+
+	return &entry, nil
+}
+
+func (br *BlacklistRepositoryImpl) GetAll() ([]model.BlacklistEntry, error) {
+	var entries []model.BlacklistEntry
+	tx := br.Db.Find(&entries)
+	if err := tx.Error; err != nil {
+		return nil, tx.Error
+	}
+
+	return entries, nil
+}
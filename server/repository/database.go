@@ -8,24 +8,30 @@ import (
 )
 
 var tables = map[string]interface{}{
-	"proxies":     &model.ProxyDto{},
-	"datasources": &model.DataSource{},
+	"proxies":            &model.ProxyDto{},
+	"datasources":        &model.DataSource{},
+	"rules":              &model.Rule{},
+	"security_events":    &model.SecurityEvent{},
+	"query_fingerprints": &model.QueryFingerprint{},
+	"blacklist_entries":  &model.BlacklistEntry{},
+	"slow_queries":       &model.SlowQuery{},
+	"alert_sinks":        &model.AlertSink{},
 }
 
 type DbContext struct {
 	Db *gorm.DB
 }
 
-func NewDbContext() *DbContext {
-	return &DbContext{connectToDatabase()}
+func NewDbContext(path string) *DbContext {
+	return &DbContext{connectToDatabase(path)}
 }
 
 func (db *DbContext) SetUpSchema() {
-	db.Db.AutoMigrate(&model.ProxyDto{}, &model.DataSource{})
+	db.Db.AutoMigrate(&model.ProxyDto{}, &model.DataSource{}, &model.Rule{}, &model.SecurityEvent{}, &model.QueryFingerprint{}, &model.BlacklistEntry{}, &model.SlowQuery{}, &model.AlertSink{})
 }
 
-func connectToDatabase() *gorm.DB {
-	db, err := gorm.Open(sqlite.Open("gorm.db"), &gorm.Config{})
+func connectToDatabase(path string) *gorm.DB {
+	db, err := gorm.Open(sqlite.Open(path+"?_busy_timeout=5000"), &gorm.Config{})
 	utils.ErrorPanic(err)
 	return db
 }
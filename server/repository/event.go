@@ -0,0 +1,73 @@
+package repository
+
+import (
+	"proxy-engineering-thesis/model"
+	"time"
+)
+
+// EventFilter narrows down security events returned by EventRepository.Find.
+// Zero values don't filter anything.
+type EventFilter struct {
+	ProxyID    uint
+	From       time.Time
+	To         time.Time
+	Severities []string
+	ClientIP   string
+	Page       int
+	Size       int
+}
+
+type EventRepository interface {
+	Create(event *model.SecurityEvent) error
+	Find(filter EventFilter) ([]model.SecurityEvent, int64, error)
+}
+
+type EventRepositoryImpl struct {
+	*DbContext
+}
+
+func NewEventRepositoryImpl(dbCtx *DbContext) *EventRepositoryImpl {
+	return &EventRepositoryImpl{dbCtx}
+}
+
+func (er *EventRepositoryImpl) Create(event *model.SecurityEvent) error {
+	tx := er.Db.Create(event)
+	if err := tx.Error; err != nil {
+		return tx.Error
+	}
+	return nil
+}
+
+// Find returns a page of events matching the filter, newest first, along with
+// the total number of matching events.
+func (er *EventRepositoryImpl) Find(filter EventFilter) ([]model.SecurityEvent, int64, error) {
+	query := er.Db.Model(&model.SecurityEvent{})
+	if filter.ProxyID != 0 {
+		query = query.Where("proxy_id = ?", filter.ProxyID)
+	}
+	if !filter.From.IsZero() {
+		query = query.Where("timestamp >= ?", filter.From)
+	}
+	if !filter.To.IsZero() {
+		query = query.Where("timestamp <= ?", filter.To)
+	}
+	if len(filter.Severities) > 0 {
+		query = query.Where("severity IN ?", filter.Severities)
+	}
+	if filter.ClientIP != "" {
+		query = query.Where("client_ip = ?", filter.ClientIP)
+	}
+
+	var total int64
+	if tx := query.Count(&total); tx.Error != nil {
+		return nil, 0, tx.Error
+	}
+
+	var events []model.SecurityEvent
+	tx := query.Order("timestamp desc").Offset((filter.Page - 1) * filter.Size).Limit(filter.Size).Find(&events)
+	if err := tx.Error; err != nil {
+		return nil, 0, tx.Error
+	}
+
+	return events, total, nil
+}
@@ -2,10 +2,13 @@ package repository
 
 import (
 	"proxy-engineering-thesis/model"
+	"time"
 )
 
 type ProxyRepository interface {
 	Create(req *model.ProxyDto) error
+	UpdateRunState(id uint, running bool, mode string, startedAt *time.Time) error
+	UpdateBaselineMode(id uint, mode string, trainingUntil *time.Time) error
 	Delete(id string) error
 	Get(id string) (*model.ProxyDto, error)
 	GetAll() ([]model.ProxyDto, error)
@@ -27,6 +30,31 @@ func (pr *ProxyRepositoryImpl) Create(req *model.ProxyDto) error {
 	return nil
 }
 
+// UpdateRunState stores only the run state, leaving the configuration as it is.
+func (pr *ProxyRepositoryImpl) UpdateRunState(id uint, running bool, mode string, startedAt *time.Time) error {
+	tx := pr.Db.Model(&model.ProxyDto{}).Where("id = ?", id).Updates(map[string]interface{}{
+		"running":    running,
+		"run_mode":   mode,
+		"started_at": startedAt,
+	})
+	if err := tx.Error; err != nil {
+		return tx.Error
+	}
+	return nil
+}
+
+// UpdateBaselineMode stores only the baseline mode and the end of its training.
+func (pr *ProxyRepositoryImpl) UpdateBaselineMode(id uint, mode string, trainingUntil *time.Time) error {
+	tx := pr.Db.Model(&model.ProxyDto{}).Where("id = ?", id).Updates(map[string]interface{}{
+		"baseline_mode":  mode,
+		"training_until": trainingUntil,
+	})
+	if err := tx.Error; err != nil {
+		return tx.Error
+	}
+	return nil
+}
+
 func (pr *ProxyRepositoryImpl) Delete(id string) error {
 
 	tx := pr.Db.Delete(&model.ProxyDto{}, id)
@@ -0,0 +1,79 @@
+package repository
+
+import (
+	"proxy-engineering-thesis/model"
+)
+
+type RuleRepository interface {
+	Create(req *model.Rule) error
+	Update(req *model.Rule) error
+	Delete(id uint) error
+	Get(id uint) (*model.Rule, error)
+	GetAll() ([]model.Rule, error)
+	Count() (int64, error)
+}
+
+type RuleRepositoryImpl struct {
+	*DbContext
+}
+
+func NewRuleRepositoryImpl(dbCtx *DbContext) *RuleRepositoryImpl {
+	return &RuleRepositoryImpl{dbCtx}
+}
+
+func (rr *RuleRepositoryImpl) Create(req *model.Rule) error {
+	tx := rr.Db.Create(req)
+	if err := tx.Error; err != nil {
+		return tx.Error
+	}
+	return nil
+}
+
+func (rr *RuleRepositoryImpl) Update(req *model.Rule) error {
+	tx := rr.Db.Save(req)
+	if err := tx.Error; err != nil {
+		return tx.Error
+	}
+	return nil
+}
+
+func (rr *RuleRepositoryImpl) Delete(id uint) error {
+
+	tx := rr.Db.Delete(&model.Rule{}, id)
+	if err := tx.Error; err != nil {
+		return err
+	}
+
+	return nil
+}
+
+func (rr *RuleRepositoryImpl) Get(id uint) (*model.Rule, error) {
+	var rule model.Rule
+	tx := rr.Db.First(&rule, id)
+	if err := tx.Error; err != nil {
+		return nil, tx.Error
+	}
+
+	return &rule, nil
+}
+
+func (rr *RuleRepositoryImpl) GetAll() ([]model.Rule, error) {
+	var rules []model.Rule
+	tx := rr.Db.Find(&rules)
+	if err := tx.Error; err != nil {
+		return nil, tx.Error
+	}
+
+	return rules, nil
+}
+
+// Count returns the number of rules ever created, including deleted ones.
+func (rr *RuleRepositoryImpl) Count() (int64, error) {
+	var count int64
+	tx := rr.Db.Unscoped().Model(&model.Rule{}).Count(&count)
+	if err := tx.Error; err != nil {
+		return 0, tx.Error
+	}
+
+	return count, nil
+}
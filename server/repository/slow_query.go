@@ -0,0 +1,87 @@
+package repository
+
+import (
+	"fmt"
+	"proxy-engineering-thesis/model"
+)
+
+const (
+	SortByTotal = "total"
+	SortByMax   = "max"
+	SortByAvg   = "avg"
+	SortByCount = "count"
+)
+
+var slowQuerySortColumns = map[string]string{
+	SortByTotal: "total_duration_ms",
+	SortByMax:   "max_duration_ms",
+	SortByAvg:   "avg_duration_ms",
+	SortByCount: "count",
+}
+
+// SlowQueryStats aggregates slow queries of a proxy sharing the fingerprint.
+// Query is the text of the most recent of them.
+type SlowQueryStats struct {
+	Fingerprint     string
+	Query           string
+	Count           int64
+	TotalDurationMs float64
+	MaxDurationMs   float64
+	AvgDurationMs   float64
+	LastSeen        string
+}
+
+type SlowQueryRepository interface {
+	Create(query *model.SlowQuery) error
+	TopFingerprints(proxyId uint, sortBy string, limit int) ([]SlowQueryStats, error)
+}
+
+type SlowQueryRepositoryImpl struct {
+	*DbContext
+}
+
+func NewSlowQueryRepositoryImpl(dbCtx *DbContext) *SlowQueryRepositoryImpl {
+	return &SlowQueryRepositoryImpl{dbCtx}
+}
+
+func (sr *SlowQueryRepositoryImpl) Create(query *model.SlowQuery) error {
+	tx := sr.Db.Create(query)
+	if err := tx.Error; err != nil {
+		return tx.Error
+	}
+	return nil
+}
+
+// TopFingerprints returns up to limit fingerprints of the proxy, ordered by the
+// given aggregate, the greatest first.
+func (sr *SlowQueryRepositoryImpl) TopFingerprints(proxyId uint, sortBy string, limit int) ([]SlowQueryStats, error) {
+	column, present := slowQuerySortColumns[sortBy]
+	if !present {
+		return nil, fmt.Errorf("unknown slow query sort: %s", sortBy)
+	}
+
+	var stats []SlowQueryStats
+	tx := sr.Db.Model(&model.SlowQuery{}).
+		Select("fingerprint, count(*) AS count, sum(duration_ms) AS total_duration_ms, "+
+			"max(duration_ms) AS max_duration_ms, avg(duration_ms) AS avg_duration_ms, max(timestamp) AS last_seen").
+		Where("proxy_id = ?", proxyId).
+		Group("fingerprint").
+		Order(column + " desc").
+		Limit(limit).
+		Scan(&stats)
+	if err := tx.Error; err != nil {
+		return nil, tx.Error
+	}
+
+	for i := range stats {
+		var latest model.SlowQuery
+		tx := sr.Db.Where("proxy_id = ? AND fingerprint = ?", proxyId, stats[i].Fingerprint).
+			Order("timestamp desc").First(&latest)
+		if err := tx.Error; err != nil {
+			return nil, tx.Error
+		}
+		stats[i].Query = latest.Query
+	}
+
+	return stats, nil
+}
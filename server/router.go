@@ -8,26 +8,50 @@ import (
 	"proxy-engineering-thesis/server/controller"
 )
 
-const WebAppUrl = "http://frontend-domain:3000"
-
 func NewRouter(
+	webAppUrl string,
 	proxyController *controller.ProxyController,
 	sourceController *controller.DataSourceController,
-	auditController *controller.AuditController) *gin.Engine {
+	auditController *controller.AuditController,
+	ruleController *controller.RuleController,
+	eventController *controller.EventController,
+	baselineController *controller.BaselineController,
+	blacklistController *controller.BlacklistController,
+	metricsController *controller.MetricsController,
+	slowQueryController *controller.SlowQueryController,
+	alertController *controller.AlertController) *gin.Engine {
 	service := gin.Default()
 
-	service.NoRoute(WebAppReverseProxy)
+	if webAppUrl != "" {
+		remote, _ := url.Parse(webAppUrl)
+		service.NoRoute(WebAppReverseProxy(remote))
+	}
+	service.GET("/metrics", metricsController.Get)
 
 	router := service.Group("/api")
 
 	proxyRouter := router.Group("/proxy")
 	proxyRouter.GET("", proxyController.GetAll)
 	proxyRouter.GET("/:id", proxyController.FindById)
+	proxyRouter.GET("/:id/sessions", proxyController.GetProxySessions)
 	proxyRouter.GET("/:id/sessions/count", proxyController.GetProxySessionsCount)
+	proxyRouter.DELETE("/:id/sessions/:sessionId", proxyController.TerminateSession)
+	proxyRouter.GET("/:id/ratelimit", proxyController.GetRateLimitStats)
+	proxyRouter.GET("/:id/slow-queries", slowQueryController.TopFingerprints)
 	proxyRouter.POST("", proxyController.Create)
 	proxyRouter.PUT("/:id/start", proxyController.StartProxy)
 	proxyRouter.PUT("/:id/stop", proxyController.StopProxy)
 	proxyRouter.DELETE("/:id", proxyController.Delete)
+	proxyRouter.GET("/:id/baseline", baselineController.Export)
+	proxyRouter.PUT("/:id/baseline", baselineController.Import)
+	proxyRouter.PUT("/:id/baseline/mode", baselineController.SetMode)
+	proxyRouter.POST("/:id/baseline/fingerprints", baselineController.AddFingerprint)
+	proxyRouter.DELETE("/:id/baseline/fingerprints/:fingerprintId", baselineController.DeleteFingerprint)
+	proxyRouter.GET("/:id/alert-sinks", alertController.GetSinks)
+	proxyRouter.POST("/:id/alert-sinks", alertController.CreateSink)
+	proxyRouter.PUT("/:id/alert-sinks/:sinkId", alertController.UpdateSink)
+	proxyRouter.DELETE("/:id/alert-sinks/:sinkId", alertController.DeleteSink)
+	proxyRouter.POST("/:id/alert-sinks/:sinkId/test", alertController.TestSink)
 
 	dataSourceRouter := router.Group("/datasource")
 	dataSourceRouter.GET("", sourceController.GetAll)
@@ -38,19 +62,38 @@ func NewRouter(
 	auditRouter := router.Group("/audit")
 	auditRouter.POST("/:id", auditController.PerformAudit)
 
+	ruleRouter := router.Group("/rules")
+	ruleRouter.GET("", ruleController.GetAll)
+	ruleRouter.POST("", ruleController.Create)
+	ruleRouter.GET("/:id", ruleController.FindById)
+	ruleRouter.PUT("/:id", ruleController.Update)
+	ruleRouter.DELETE("/:id", ruleController.Delete)
+
+	eventRouter := router.Group("/events")
+	eventRouter.GET("", eventController.Find)
+
+	blacklistRouter := router.Group("/blacklist")
+	blacklistRouter.GET("", blacklistController.GetAll)
+	blacklistRouter.POST("", blacklistController.Create)
+	blacklistRouter.GET("/:id", blacklistController.FindById)
+	blacklistRouter.PUT("/:id", blacklistController.Update)
+	blacklistRouter.DELETE("/:id", blacklistController.Delete)
+
 	return service
 }
 
-func WebAppReverseProxy(c *gin.Context) {
-	remote, _ := url.Parse(WebAppUrl)
-	proxy := httputil.NewSingleHostReverseProxy(remote)
-	proxy.Director = func(req *http.Request) {
-		req.Header = c.Request.Header
-		req.Host = remote.Host
-		req.URL = c.Request.URL
-		req.URL.Scheme = remote.Scheme
-		req.URL.Host = remote.Host
-	}
+// WebAppReverseProxy serves requests outside of the API by the web app.
+func WebAppReverseProxy(remote *url.URL) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		proxy := httputil.NewSingleHostReverseProxy(remote)
+		proxy.Director = func(req *http.Request) {
+			req.Header = c.Request.Header
+			req.Host = remote.Host
+			req.URL = c.Request.URL
+			req.URL.Scheme = remote.Scheme
+			req.URL.Host = remote.Host
+		}
 
-	proxy.ServeHTTP(c.Writer, c.Request)
+		proxy.ServeHTTP(c.Writer, c.Request)
+	}
 }
@@ -1,52 +1,139 @@
 package server
 
 import (
+	"context"
 	"github.com/gin-gonic/gin"
 	"go.uber.org/dig"
 	"net/http"
+	"os"
+	"os/signal"
+	"proxy-engineering-thesis/internal/config"
+	"proxy-engineering-thesis/internal/logger"
+	"proxy-engineering-thesis/internal/metrics"
+	"proxy-engineering-thesis/internal/proxy/blacklist"
+	"proxy-engineering-thesis/internal/proxy/detection"
 	"proxy-engineering-thesis/internal/utils"
 	"proxy-engineering-thesis/server/controller"
 	"proxy-engineering-thesis/server/repository"
 	"proxy-engineering-thesis/server/service"
 	"proxy-engineering-thesis/server/storage"
+	"syscall"
 	"time"
 )
 
-func StartServer() {
+const (
+	httpShutdownTimeout        = 5 * time.Second
+	alertsShutdownTimeout      = 5 * time.Second
+	eventsShutdownTimeout      = 5 * time.Second
+	baselineShutdownTimeout    = 5 * time.Second
+	slowQueriesShutdownTimeout = 5 * time.Second
+)
+
+var log = logger.New("server")
+
+func StartServer(cfg *config.Config) {
+	utils.ErrorPanic(logger.Configure(cfg.Logger()))
+	// queries are logged without their literals, which may hold sensitive data
+	logger.SetQueryRedactor(detection.RedactQuery)
+
 	container := dig.New()
-	declareDependencies(container)
+	declareDependencies(container, cfg)
 
 	dbContextInitialization := func(dbCtx *repository.DbContext) {
 		dbCtx.SetUpSchema()
 	}
 
-	routeDeclaration := func(proxyController *controller.ProxyController, dsController *controller.DataSourceController, auditController *controller.AuditController) {
-		routes := NewRouter(proxyController, dsController, auditController)
+	rulesInitialization := func(ruleService service.RuleService) {
+		err := ruleService.LoadRules()
+		utils.ErrorPanic(err)
+	}
 
+	blacklistInitialization := func(blacklistService service.BlacklistService) {
+		err := blacklistService.LoadEntries()
+		utils.ErrorPanic(err)
+	}
+
+	proxiesRestoration := func(proxyService service.ProxyService) {
+		if err := proxyService.RestoreProxies(); err != nil {
+			log.Error("failed to restore proxies", "error", err)
+		}
+	}
+
+	routeDeclaration := func(routes *gin.Engine, proxyService service.ProxyService, alertService service.AlertService, eventService service.EventService, baselineService service.BaselineService, slowQueryService service.SlowQueryService) {
 		server := &http.Server{
-			Addr:           ":8888",
+			Addr:           cfg.Server.Address,
 			Handler:        routes,
-			ReadTimeout:    10 * time.Second,
-			WriteTimeout:   10 * time.Second,
-			MaxHeaderBytes: 1 << 20,
+			ReadTimeout:    cfg.Server.ReadTimeout,
+			WriteTimeout:   cfg.Server.WriteTimeout,
+			MaxHeaderBytes: cfg.Server.MaxHeaderBytes,
 		}
 
-		err := server.ListenAndServe()
-		utils.ErrorPanic(err)
+		go func() {
+			err := server.ListenAndServe()
+			if err != http.ErrServerClosed {
+				utils.ErrorPanic(err)
+			}
+		}()
+
+		signals := make(chan os.Signal, 1)
+		signal.Notify(signals, syscall.SIGINT, syscall.SIGTERM)
+		log.Info("shutting down", "signal", <-signals)
+
+		// proxies are drained first, the API stays available in the meantime
+		ctx, cancel := context.WithTimeout(context.Background(), cfg.Server.ShutdownTimeout)
+		defer cancel()
+		proxyService.Shutdown(ctx)
+
+		eventsCtx, eventsCancel := context.WithTimeout(context.Background(), eventsShutdownTimeout)
+		defer eventsCancel()
+		eventService.Close(eventsCtx)
+
+		baselineCtx, baselineCancel := context.WithTimeout(context.Background(), baselineShutdownTimeout)
+		defer baselineCancel()
+		baselineService.Close(baselineCtx)
+
+		slowQueriesCtx, slowQueriesCancel := context.WithTimeout(context.Background(), slowQueriesShutdownTimeout)
+		defer slowQueriesCancel()
+		slowQueryService.Close(slowQueriesCtx)
+
+		alertsCtx, alertsCancel := context.WithTimeout(context.Background(), alertsShutdownTimeout)
+		defer alertsCancel()
+		alertService.Close(alertsCtx)
+
+		httpCtx, httpCancel := context.WithTimeout(context.Background(), httpShutdownTimeout)
+		defer httpCancel()
+		if err := server.Shutdown(httpCtx); err != nil {
+			log.Error("failed to shut down http server", "error", err)
+		}
+		log.Info("server stopped")
 	}
 
 	if err := container.Invoke(dbContextInitialization); err != nil {
 		panic(err)
 	}
 
+	if err := container.Invoke(rulesInitialization); err != nil {
+		panic(err)
+	}
+
+	if err := container.Invoke(blacklistInitialization); err != nil {
+		panic(err)
+	}
+
+	if err := container.Invoke(proxiesRestoration); err != nil {
+		panic(err)
+	}
+
 	if err := container.Invoke(routeDeclaration); err != nil {
 		panic(err)
 	}
 
 }
 
-func declareDependencies(container *dig.Container) {
-	container.Provide(repository.NewDbContext)
+func declareDependencies(container *dig.Container, cfg *config.Config) {
+	container.Provide(func() *repository.DbContext {
+		return repository.NewDbContext(cfg.Database.Path)
+	})
 	container.Provide(func(db *repository.DbContext) repository.ProxyRepository {
 		return repository.NewProxyRepositoryImpl(db)
 	})
@@ -57,8 +144,54 @@ func declareDependencies(container *dig.Container) {
 	container.Provide(func(dsRepo repository.DataSourceRepository) service.DataSourceService {
 		return service.NewDataSourceService(dsRepo)
 	})
-	container.Provide(func(proxyRepo repository.ProxyRepository, dsService service.DataSourceService, proxyStorage *storage.ProxiesStorage) service.ProxyService {
-		return service.NewProxyService(proxyRepo, dsService, proxyStorage)
+	container.Provide(detection.NewRuleSet)
+	container.Provide(metrics.NewProxyMetrics)
+	container.Provide(blacklist.NewAccessList)
+	container.Provide(func(accessList *blacklist.AccessList) *blacklist.BlackListManager {
+		return blacklist.NewBlackListManager(accessList, blacklist.Config{
+			CacheSize:      cfg.Blacklist.CacheSize,
+			BanDuration:    cfg.Blacklist.BanDuration,
+			MaxBanDuration: cfg.Blacklist.MaxBanDuration,
+		})
+	})
+	container.Provide(func(db *repository.DbContext) repository.BlacklistRepository {
+		return repository.NewBlacklistRepositoryImpl(db)
+	})
+	container.Provide(func(blacklistRepo repository.BlacklistRepository, accessList *blacklist.AccessList) service.BlacklistService {
+		return service.NewBlacklistService(blacklistRepo, accessList)
+	})
+	container.Provide(func(db *repository.DbContext) repository.RuleRepository {
+		return repository.NewRuleRepositoryImpl(db)
+	})
+	container.Provide(func(ruleRepo repository.RuleRepository, ruleSet *detection.RuleSet) service.RuleService {
+		return service.NewRuleService(ruleRepo, ruleSet)
+	})
+	container.Provide(func(db *repository.DbContext) repository.EventRepository {
+		return repository.NewEventRepositoryImpl(db)
+	})
+	container.Provide(func(eventRepo repository.EventRepository) service.EventService {
+		return service.NewEventService(eventRepo)
+	})
+	container.Provide(func(db *repository.DbContext) repository.FingerprintRepository {
+		return repository.NewFingerprintRepositoryImpl(db)
+	})
+	container.Provide(func(fingerprintRepo repository.FingerprintRepository, proxyRepo repository.ProxyRepository) service.BaselineService {
+		return service.NewBaselineService(fingerprintRepo, proxyRepo)
+	})
+	container.Provide(func(db *repository.DbContext) repository.SlowQueryRepository {
+		return repository.NewSlowQueryRepositoryImpl(db)
+	})
+	container.Provide(func(slowQueryRepo repository.SlowQueryRepository, proxyRepo repository.ProxyRepository) service.SlowQueryService {
+		return service.NewSlowQueryService(slowQueryRepo, proxyRepo)
+	})
+	container.Provide(func(db *repository.DbContext) repository.AlertSinkRepository {
+		return repository.NewAlertSinkRepositoryImpl(db)
+	})
+	container.Provide(func(alertSinkRepo repository.AlertSinkRepository, proxyRepo repository.ProxyRepository) service.AlertService {
+		return service.NewAlertService(alertSinkRepo, proxyRepo, cfg.Alerts.FileSinkDir)
+	})
+	container.Provide(func(proxyRepo repository.ProxyRepository, dsService service.DataSourceService, proxyStorage *storage.ProxiesStorage, ruleSet *detection.RuleSet, eventService service.EventService, baselineService service.BaselineService, blackList *blacklist.BlackListManager, proxyMetrics *metrics.ProxyMetrics, slowQueryService service.SlowQueryService, alertService service.AlertService) service.ProxyService {
+		return service.NewProxyService(proxyRepo, dsService, proxyStorage, ruleSet, eventService, baselineService, blackList, cfg.Server.ShutdownTimeout, cfg.Proxy.BufferSize, cfg.Proxy.MaxMessageSize, proxyMetrics, slowQueryService, alertService)
 	})
 	container.Provide(func(dsService service.DataSourceService) *controller.DataSourceController {
 		return controller.NewDataSourceController(dsService)
@@ -72,7 +205,28 @@ func declareDependencies(container *dig.Container) {
 	container.Provide(func(auditService service.AuditService) *controller.AuditController {
 		return controller.NewAuditController(auditService)
 	})
-	container.Provide(func(proxyController *controller.ProxyController, dsController *controller.DataSourceController, auditController *controller.AuditController) *gin.Engine {
-		return NewRouter(proxyController, dsController, auditController)
+	container.Provide(func(ruleService service.RuleService) *controller.RuleController {
+		return controller.NewRuleController(ruleService)
+	})
+	container.Provide(func(eventService service.EventService) *controller.EventController {
+		return controller.NewEventController(eventService)
+	})
+	container.Provide(func(baselineService service.BaselineService) *controller.BaselineController {
+		return controller.NewBaselineController(baselineService)
+	})
+	container.Provide(func(blacklistService service.BlacklistService) *controller.BlacklistController {
+		return controller.NewBlacklistController(blacklistService)
+	})
+	container.Provide(func(proxyMetrics *metrics.ProxyMetrics) *controller.MetricsController {
+		return controller.NewMetricsController(proxyMetrics)
+	})
+	container.Provide(func(slowQueryService service.SlowQueryService) *controller.SlowQueryController {
+		return controller.NewSlowQueryController(slowQueryService)
+	})
+	container.Provide(func(alertService service.AlertService) *controller.AlertController {
+		return controller.NewAlertController(alertService)
+	})
+	container.Provide(func(proxyController *controller.ProxyController, dsController *controller.DataSourceController, auditController *controller.AuditController, ruleController *controller.RuleController, eventController *controller.EventController, baselineController *controller.BaselineController, blacklistController *controller.BlacklistController, metricsController *controller.MetricsController, slowQueryController *controller.SlowQueryController, alertController *controller.AlertController) *gin.Engine {
+		return NewRouter(cfg.Server.WebAppUrl, proxyController, dsController, auditController, ruleController, eventController, baselineController, blacklistController, metricsController, slowQueryController, alertController)
 	})
 }
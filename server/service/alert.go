@@ -0,0 +1,359 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	utils "proxy-engineering-thesis"
+	"proxy-engineering-thesis/internal/alerting"
+	"proxy-engineering-thesis/model"
+	"proxy-engineering-thesis/server/repository"
+	"strings"
+	"sync"
+)
+
+type AlertService interface {
+	ForProxy(proxyId uint) *alerting.Dispatcher
+	GetSinks(proxyId string) ([]model.AlertSink, error)
+	CreateSink(proxyId string, req *model.AlertSink) error
+	UpdateSink(proxyId string, sinkId uint, req *model.AlertSink) error
+	DeleteSink(proxyId string, sinkId uint) error
+	TestSink(proxyId string, sinkId uint) error
+	Close(ctx context.Context)
+}
+
+// AlertServiceImpl keeps a dispatcher for every proxy which was started, with
+// sinks in sync with the ones stored in the database.
+type AlertServiceImpl struct {
+	alertSinkRepository repository.AlertSinkRepository
+	proxyRepository     repository.ProxyRepository
+	defaultSinks        []model.AlertSink
+	fileSinkDir         string
+	dispatchers         map[uint]*alerting.Dispatcher
+	lock                sync.Mutex
+}
+
+func NewAlertService(alertSinkRepository repository.AlertSinkRepository, proxyRepository repository.ProxyRepository, fileSinkDir string) *AlertServiceImpl {
+	return &AlertServiceImpl{
+		alertSinkRepository: alertSinkRepository,
+		proxyRepository:     proxyRepository,
+		defaultSinks:        getDefaultSinks(),
+		fileSinkDir:         fileSinkDir,
+		dispatchers:         make(map[uint]*alerting.Dispatcher),
+	}
+}
+
+const defaultCloudWatchConfig = "resources/cw.properties"
+
+// maskedSecret is returned in place of secrets of sinks, which can be set but
+// aren't ever sent back. Updates sending it keep the secret.
+const maskedSecret = "********"
+
+// cloudWatchEnv maps environment variables to keys of the CloudWatch config
+// file, variables take precedence over the file.
+var cloudWatchEnv = map[string]string{
+	"CLOUDWATCH_LOG_GROUP":      "logGroupName",
+	"CLOUDWATCH_LOG_STREAM":     "logStreamName",
+	"CLOUDWATCH_REGION":         "region",
+	"CLOUDWATCH_PROFILE":        "profile",
+	"CLOUDWATCH_ENDPOINT":       "endpoint",
+	"CLOUDWATCH_FLUSH_INTERVAL": "flushInterval",
+}
+
+// getDefaultSinks returns the CloudWatch sink used by proxies which don't
+// have a CloudWatch sink of their own. It's configured by the properties file
+// at CLOUDWATCH_CONFIG, resources/cw.properties by default, and CLOUDWATCH_*
+// environment variables.
+func getDefaultSinks() []model.AlertSink {
+	path := os.Getenv("CLOUDWATCH_CONFIG")
+	if path == "" {
+		path = defaultCloudWatchConfig
+	}
+
+	conf, err := utils.ReadPropertiesBasedConfig(path)
+	if err != nil {
+		if path != defaultCloudWatchConfig || !errors.Is(err, os.ErrNotExist) {
+			log.Warn("failed to read CloudWatch configuration", "path", path, "error", err)
+		}
+		conf = utils.Config{}
+	}
+	for name, key := range cloudWatchEnv {
+		if value := os.Getenv(name); value != "" {
+			conf[key] = value
+		}
+	}
+
+	if conf["logGroupName"] == "" || conf["logStreamName"] == "" {
+		log.Debug("default CloudWatch sink isn't configured")
+		return nil
+	}
+
+	sink := model.AlertSink{
+		Type:          model.CloudWatchSink,
+		LogGroupName:  conf["logGroupName"],
+		LogStreamName: conf["logStreamName"],
+		Region:        conf["region"],
+		Profile:       conf["profile"],
+		Endpoint:      conf["endpoint"],
+		FlushInterval: conf["flushInterval"],
+	}
+	if err := alerting.ValidateConfig(sink); err != nil {
+		log.Error("invalid default CloudWatch sink", "error", err)
+		return nil
+	}
+	return []model.AlertSink{sink}
+}
+
+func (as *AlertServiceImpl) ForProxy(proxyId uint) *alerting.Dispatcher {
+	as.lock.Lock()
+	defer as.lock.Unlock()
+
+	dispatcher, present := as.dispatchers[proxyId]
+	if present {
+		return dispatcher
+	}
+
+	dispatcher = alerting.NewDispatcher()
+	if err := as.loadSinks(proxyId, dispatcher); err != nil {
+		log.Error("failed to load alert sinks of proxy", "proxyId", proxyId, "error", err)
+	}
+	as.dispatchers[proxyId] = dispatcher
+	return dispatcher
+}
+
+func (as *AlertServiceImpl) loadSinks(proxyId uint, dispatcher *alerting.Dispatcher) error {
+	sinks, err := as.alertSinkRepository.GetByProxy(proxyId)
+	if err != nil {
+		return err
+	}
+
+	configs := make([]model.AlertSink, 0, len(sinks)+len(as.defaultSinks))
+	for _, sink := range sinks {
+		if strings.EqualFold(sink.Type, model.FileSink) {
+			path, err := as.fileSinkPath(sink.Target)
+			if err != nil {
+				log.Error("skipping alert sink", "proxyId", proxyId, "sinkId", sink.ID, "error", err)
+				continue
+			}
+			sink.Target = path
+		}
+		configs = append(configs, sink)
+	}
+	for _, defaultSink := range as.defaultSinks {
+		if !hasSinkType(sinks, defaultSink.Type) {
+			configs = append(configs, defaultSink)
+		}
+	}
+	dispatcher.SetSinks(configs)
+	return nil
+}
+
+// fileSinkPath returns the absolute path of the file sink target, which is
+// relative to the file sink directory and can't lead out of it.
+func (as *AlertServiceImpl) fileSinkPath(target string) (string, error) {
+	dir, err := filepath.Abs(as.fileSinkDir)
+	if err != nil {
+		return "", err
+	}
+	if filepath.IsAbs(target) || !isLocalPath(target) {
+		return "", fmt.Errorf("file sink path has to be relative to %s and stay within it: %s", as.fileSinkDir, target)
+	}
+	return filepath.Join(dir, target), nil
+}
+
+// isLocalPath tells whether the relative path stays within its directory.
+func isLocalPath(path string) bool {
+	path = filepath.Clean(path)
+	return path != "." && path != ".." && !strings.HasPrefix(path, ".."+string(filepath.Separator))
+}
+
+// checkSink validates the sink sent to the API.
+func (as *AlertServiceImpl) checkSink(sink model.AlertSink) error {
+	if err := alerting.ValidateConfig(sink); err != nil {
+		return err
+	}
+	if strings.EqualFold(sink.Type, model.FileSink) {
+		if _, err := as.fileSinkPath(sink.Target); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func maskSecret(sink *model.AlertSink) {
+	if sink.Secret != "" {
+		sink.Secret = maskedSecret
+	}
+}
+
+func hasSinkType(sinks []model.AlertSink, sinkType string) bool {
+	for _, sink := range sinks {
+		if strings.EqualFold(sink.Type, sinkType) {
+			return true
+		}
+	}
+	return false
+}
+
+// refresh reloads sinks of the proxy if it has a dispatcher already.
+func (as *AlertServiceImpl) refresh(proxyId uint) error {
+	as.lock.Lock()
+	defer as.lock.Unlock()
+
+	dispatcher, present := as.dispatchers[proxyId]
+	if !present {
+		return nil
+	}
+	return as.loadSinks(proxyId, dispatcher)
+}
+
+func (as *AlertServiceImpl) GetSinks(proxyId string) ([]model.AlertSink, error) {
+	proxy, err := as.proxyRepository.Get(proxyId)
+	if err != nil {
+		return nil, err
+	}
+
+	sinks, err := as.alertSinkRepository.GetByProxy(proxy.ID)
+	if err != nil {
+		return nil, err
+	}
+	for i := range sinks {
+		maskSecret(&sinks[i])
+	}
+	return sinks, nil
+}
+
+func (as *AlertServiceImpl) CreateSink(proxyId string, req *model.AlertSink) error {
+	proxy, err := as.proxyRepository.Get(proxyId)
+	if err != nil {
+		return err
+	}
+
+	if err := as.checkSink(*req); err != nil {
+		return err
+	}
+
+	req.ID = 0
+	req.ProxyID = proxy.ID
+	req.Type = strings.ToLower(req.Type)
+	req.MinSeverity = strings.ToLower(req.MinSeverity)
+	if err := as.alertSinkRepository.Create(req); err != nil {
+		return err
+	}
+
+	maskSecret(req)
+	return as.refresh(proxy.ID)
+}
+
+func (as *AlertServiceImpl) UpdateSink(proxyId string, sinkId uint, req *model.AlertSink) error {
+	proxy, err := as.proxyRepository.Get(proxyId)
+	if err != nil {
+		return err
+	}
+
+	sink, err := as.alertSinkRepository.Get(proxy.ID, sinkId)
+	if err != nil {
+		return err
+	}
+
+	if err := as.checkSink(*req); err != nil {
+		return err
+	}
+
+	sink.Type = strings.ToLower(req.Type)
+	sink.MinSeverity = strings.ToLower(req.MinSeverity)
+	sink.Target = req.Target
+	sink.Network = req.Network
+	if req.Secret != maskedSecret {
+		sink.Secret = req.Secret
+	}
+	sink.MaxRetries = req.MaxRetries
+	sink.LogGroupName = req.LogGroupName
+	sink.LogStreamName = req.LogStreamName
+	sink.Region = req.Region
+	sink.Profile = req.Profile
+	sink.Endpoint = req.Endpoint
+	sink.FlushInterval = req.FlushInterval
+	if err := as.alertSinkRepository.Update(sink); err != nil {
+		return err
+	}
+
+	*req = *sink
+	maskSecret(req)
+	return as.refresh(proxy.ID)
+}
+
+func (as *AlertServiceImpl) DeleteSink(proxyId string, sinkId uint) error {
+	proxy, err := as.proxyRepository.Get(proxyId)
+	if err != nil {
+		return err
+	}
+
+	if err := as.alertSinkRepository.Delete(proxy.ID, sinkId); err != nil {
+		return err
+	}
+
+	return as.refresh(proxy.ID)
+}
+
+// TestSink sends a test alert to the sink right away, reporting a failure of
+// the delivery.
+func (as *AlertServiceImpl) TestSink(proxyId string, sinkId uint) error {
+	proxy, err := as.proxyRepository.Get(proxyId)
+	if err != nil {
+		return err
+	}
+
+	config, err := as.alertSinkRepository.Get(proxy.ID, sinkId)
+	if err != nil {
+		return err
+	}
+
+	if strings.EqualFold(config.Type, model.FileSink) {
+		if config.Target, err = as.fileSinkPath(config.Target); err != nil {
+			return err
+		}
+	}
+
+	sink, err := alerting.NewSink(*config)
+	if err != nil {
+		return err
+	}
+
+	// sinks sending in batches deliver the alert when they're closed
+	err = sink.Send(alerting.NewTestAlert(proxy.ID, proxy.Name))
+	if closeErr := sink.Close(); err == nil {
+		err = closeErr
+	}
+	if err != nil {
+		return fmt.Errorf("failed to send test alert: %v", err)
+	}
+	return nil
+}
+
+// Close sends alerts still queued and closes all sinks. Unreachable sinks are
+// given up on when the context is done.
+func (as *AlertServiceImpl) Close(ctx context.Context) {
+	as.lock.Lock()
+	dispatchers := make([]*alerting.Dispatcher, 0, len(as.dispatchers))
+	for _, dispatcher := range as.dispatchers {
+		dispatchers = append(dispatchers, dispatcher)
+	}
+	as.lock.Unlock()
+
+	done := make(chan struct{})
+	go func() {
+		for _, dispatcher := range dispatchers {
+			dispatcher.Close()
+		}
+		close(done)
+	}()
+
+	select {
+	case <-done:
+	case <-ctx.Done():
+		log.Warn("alert sinks didn't flush before the deadline")
+	}
+}
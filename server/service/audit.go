@@ -1,7 +1,6 @@
 package service
 
 import (
-	"fmt"
 	"proxy-engineering-thesis/internal/audit/relational"
 	relationalModel "proxy-engineering-thesis/model/relational"
 )
@@ -21,7 +20,8 @@ func NewAuditService(dsService DataSourceService) *AuditServiceImpl {
 func (as AuditServiceImpl) PerformAudit(id string, config relationalModel.AuditConfiguration) (relationalModel.AuditData, error) {
 	ds, err := as.dataSourceService.GetById(id)
 	if err != nil {
-		fmt.Printf("failed to retrieve auditted datasource data: %v\n", err)
+		log.Error("failed to retrieve auditted datasource data", "error", err)
+		return relationalModel.AuditData{}, err
 	}
 
 	dsConnData := relational.DataSourceConnectionData{
@@ -29,11 +29,14 @@ func (as AuditServiceImpl) PerformAudit(id string, config relationalModel.AuditC
 		Port:     ds.Port,
 		Username: ds.Username,
 		Password: ds.Password,
+		SSLMode:  ds.SSLMode,
+		RootCert: ds.SSLRootCertFile,
 	}
 
 	auditResult, err := relational.PerformAudit(dsConnData, config)
 	if err != nil {
-		fmt.Printf("failed to perform audit: %v\n", err)
+		log.Error("failed to perform audit", "error", err)
+		return auditResult, err
 	}
 	return auditResult, nil
 }
@@ -0,0 +1,325 @@
+package service
+
+import (
+	"context"
+	"fmt"
+	"proxy-engineering-thesis/internal/proxy/detection"
+	"proxy-engineering-thesis/model"
+	"proxy-engineering-thesis/server/repository"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// learned fingerprints waiting for the database are dropped once the queue is
+// full, so that sessions never wait for it
+const fingerprintsQueueSize = 256
+
+// Baseline is the exported form of a proxy baseline.
+type Baseline struct {
+	Mode          string
+	TrainingUntil *time.Time
+	Fingerprints  []model.QueryFingerprint
+}
+
+// BaselineModeRequest switches a proxy between learning and enforcing. The
+// training window, e.g. "72h", limits learning in time.
+type BaselineModeRequest struct {
+	Mode           string
+	TrainingWindow string
+}
+
+type BaselineService interface {
+	detection.BaselineStore
+	Export(proxyId string) (Baseline, error)
+	Import(proxyId string, baseline Baseline) error
+	AddFingerprint(proxyId string, fingerprint *model.QueryFingerprint) error
+	DeleteFingerprint(proxyId string, fingerprintId uint) error
+	SetMode(proxyId string, req BaselineModeRequest) (Baseline, error)
+	Close(ctx context.Context)
+}
+
+// BaselineServiceImpl keeps baselines used by running proxies in memory and in
+// sync with the ones stored in the database.
+type BaselineServiceImpl struct {
+	fingerprintRepository repository.FingerprintRepository
+	proxyRepository       repository.ProxyRepository
+	baselines             map[uint]*proxyBaseline
+	lock                  sync.Mutex
+	learned               chan model.QueryFingerprint
+	done                  chan struct{}
+	closed                bool
+	learnedLock           sync.RWMutex
+}
+
+func NewBaselineService(fingerprintRepository repository.FingerprintRepository, proxyRepository repository.ProxyRepository) *BaselineServiceImpl {
+	bs := &BaselineServiceImpl{
+		fingerprintRepository: fingerprintRepository,
+		proxyRepository:       proxyRepository,
+		baselines:             make(map[uint]*proxyBaseline),
+		learned:               make(chan model.QueryFingerprint, fingerprintsQueueSize),
+		done:                  make(chan struct{}),
+	}
+	go bs.run()
+	return bs
+}
+
+// run stores learned fingerprints one at a time and in order.
+func (bs *BaselineServiceImpl) run() {
+	defer close(bs.done)
+	for fingerprint := range bs.learned {
+		if err := bs.fingerprintRepository.Create(&fingerprint); err != nil {
+			log.Error("failed to store query fingerprint", "error", err)
+		}
+	}
+}
+
+// storeLearned queues a fingerprint learned by a proxy session, to be
+// persisted in the background.
+func (bs *BaselineServiceImpl) storeLearned(fingerprint model.QueryFingerprint) {
+	bs.learnedLock.RLock()
+	defer bs.learnedLock.RUnlock()
+
+	if bs.closed {
+		log.Warn("baseline service is closed, dropping query fingerprint", "proxyId", fingerprint.ProxyID)
+		return
+	}
+	select {
+	case bs.learned <- fingerprint:
+	default:
+		log.Warn("fingerprints queue is full, dropping query fingerprint", "proxyId", fingerprint.ProxyID)
+	}
+}
+
+// Close stores learned fingerprints still queued. They are given up on when
+// the context is done.
+func (bs *BaselineServiceImpl) Close(ctx context.Context) {
+	bs.learnedLock.Lock()
+	if !bs.closed {
+		bs.closed = true
+		close(bs.learned)
+	}
+	bs.learnedLock.Unlock()
+
+	select {
+	case <-bs.done:
+	case <-ctx.Done():
+		log.Warn("query fingerprints weren't stored before the deadline")
+	}
+}
+
+func (bs *BaselineServiceImpl) ForProxy(proxyId uint) detection.Baseline {
+	return bs.getBaseline(proxyId)
+}
+
+func (bs *BaselineServiceImpl) Export(proxyId string) (Baseline, error) {
+	proxy, err := bs.proxyRepository.Get(proxyId)
+	if err != nil {
+		return Baseline{}, err
+	}
+
+	fingerprints, err := bs.fingerprintRepository.GetByProxy(proxy.ID)
+	if err != nil {
+		return Baseline{}, err
+	}
+
+	return Baseline{Mode: getBaselineMode(proxy.BaselineMode), TrainingUntil: proxy.TrainingUntil, Fingerprints: fingerprints}, nil
+}
+
+// Import replaces fingerprints of the proxy with the given ones.
+func (bs *BaselineServiceImpl) Import(proxyId string, baseline Baseline) error {
+	proxy, err := bs.proxyRepository.Get(proxyId)
+	if err != nil {
+		return err
+	}
+
+	fingerprints := make([]model.QueryFingerprint, 0, len(baseline.Fingerprints))
+	for _, fingerprint := range baseline.Fingerprints {
+		fingerprints = append(fingerprints, model.QueryFingerprint{
+			ProxyID:     proxy.ID,
+			DbUser:      fingerprint.DbUser,
+			Fingerprint: fingerprint.Fingerprint,
+		})
+	}
+
+	if err := bs.fingerprintRepository.ReplaceForProxy(proxy.ID, fingerprints); err != nil {
+		return err
+	}
+
+	return bs.refresh(proxy)
+}
+
+func (bs *BaselineServiceImpl) AddFingerprint(proxyId string, fingerprint *model.QueryFingerprint) error {
+	proxy, err := bs.proxyRepository.Get(proxyId)
+	if err != nil {
+		return err
+	}
+
+	if strings.TrimSpace(fingerprint.Fingerprint) == "" {
+		return fmt.Errorf("fingerprint can't be empty")
+	}
+
+	fingerprint.ID = 0
+	fingerprint.ProxyID = proxy.ID
+	if err := bs.fingerprintRepository.Create(fingerprint); err != nil {
+		return err
+	}
+
+	return bs.refresh(proxy)
+}
+
+func (bs *BaselineServiceImpl) DeleteFingerprint(proxyId string, fingerprintId uint) error {
+	proxy, err := bs.proxyRepository.Get(proxyId)
+	if err != nil {
+		return err
+	}
+
+	if err := bs.fingerprintRepository.Delete(proxy.ID, fingerprintId); err != nil {
+		return err
+	}
+
+	return bs.refresh(proxy)
+}
+
+func (bs *BaselineServiceImpl) SetMode(proxyId string, req BaselineModeRequest) (Baseline, error) {
+	proxy, err := bs.proxyRepository.Get(proxyId)
+	if err != nil {
+		return Baseline{}, err
+	}
+
+	mode := strings.ToLower(req.Mode)
+	if mode != model.BaselineLearning && mode != model.BaselineEnforcing {
+		return Baseline{}, fmt.Errorf("unknown baseline mode: %s", req.Mode)
+	}
+
+	proxy.BaselineMode = mode
+	proxy.TrainingUntil = nil
+	if mode == model.BaselineLearning && req.TrainingWindow != "" {
+		window, err := time.ParseDuration(req.TrainingWindow)
+		if err != nil {
+			return Baseline{}, fmt.Errorf("invalid training window: %v", err)
+		}
+		trainingUntil := time.Now().Add(window)
+		proxy.TrainingUntil = &trainingUntil
+	}
+
+	if err := bs.proxyRepository.UpdateBaselineMode(proxy.ID, proxy.BaselineMode, proxy.TrainingUntil); err != nil {
+		return Baseline{}, err
+	}
+
+	if err := bs.refresh(proxy); err != nil {
+		return Baseline{}, err
+	}
+
+	return bs.Export(proxyId)
+}
+
+func (bs *BaselineServiceImpl) getBaseline(proxyId uint) *proxyBaseline {
+	bs.lock.Lock()
+	defer bs.lock.Unlock()
+
+	baseline, present := bs.baselines[proxyId]
+	if present {
+		return baseline
+	}
+
+	baseline = &proxyBaseline{proxyId: proxyId, fingerprintRepository: bs.fingerprintRepository, store: bs.storeLearned}
+	proxy, err := bs.proxyRepository.Get(strconv.FormatUint(uint64(proxyId), 10))
+	if err != nil {
+		log.Error("failed to load proxy for its baseline", "proxyId", proxyId, "error", err)
+		proxy = &model.ProxyDto{}
+	}
+	if err := baseline.load(proxy); err != nil {
+		log.Error("failed to load baseline of proxy", "proxyId", proxyId, "error", err)
+	}
+
+	bs.baselines[proxyId] = baseline
+	return baseline
+}
+
+// refresh reloads the baseline of the proxy if it's used by a running proxy.
+func (bs *BaselineServiceImpl) refresh(proxy *model.ProxyDto) error {
+	bs.lock.Lock()
+	baseline, present := bs.baselines[proxy.ID]
+	bs.lock.Unlock()
+
+	if !present {
+		return nil
+	}
+	return baseline.load(proxy)
+}
+
+func getBaselineMode(mode string) string {
+	if mode == "" {
+		return model.BaselineLearning
+	}
+	return mode
+}
+
+// proxyBaseline implements detection.Baseline for a single proxy.
+type proxyBaseline struct {
+	proxyId               uint
+	fingerprintRepository repository.FingerprintRepository
+	// store persists learned fingerprints without blocking the session
+	store         func(fingerprint model.QueryFingerprint)
+	lock          sync.RWMutex
+	mode          string
+	trainingUntil *time.Time
+	fingerprints  map[string]map[string]bool
+}
+
+func (pb *proxyBaseline) load(proxy *model.ProxyDto) error {
+	stored, err := pb.fingerprintRepository.GetByProxy(pb.proxyId)
+	if err != nil {
+		return err
+	}
+
+	fingerprints := make(map[string]map[string]bool)
+	for _, fingerprint := range stored {
+		if fingerprints[fingerprint.DbUser] == nil {
+			fingerprints[fingerprint.DbUser] = make(map[string]bool)
+		}
+		fingerprints[fingerprint.DbUser][fingerprint.Fingerprint] = true
+	}
+
+	pb.lock.Lock()
+	pb.mode = getBaselineMode(proxy.BaselineMode)
+	pb.trainingUntil = proxy.TrainingUntil
+	pb.fingerprints = fingerprints
+	pb.lock.Unlock()
+	return nil
+}
+
+func (pb *proxyBaseline) Learning() bool {
+	pb.lock.RLock()
+	defer pb.lock.RUnlock()
+
+	if pb.mode != model.BaselineLearning {
+		return false
+	}
+	return pb.trainingUntil == nil || time.Now().Before(*pb.trainingUntil)
+}
+
+func (pb *proxyBaseline) Contains(user, fingerprint string) bool {
+	pb.lock.RLock()
+	defer pb.lock.RUnlock()
+
+	return pb.fingerprints[user][fingerprint]
+}
+
+func (pb *proxyBaseline) Learn(user, fingerprint string) {
+	pb.lock.Lock()
+	if pb.fingerprints[user] == nil {
+		pb.fingerprints[user] = make(map[string]bool)
+	}
+	known := pb.fingerprints[user][fingerprint]
+	pb.fingerprints[user][fingerprint] = true
+	pb.lock.Unlock()
+
+	if known {
+		return
+	}
+
+	pb.store(model.QueryFingerprint{ProxyID: pb.proxyId, DbUser: user, Fingerprint: fingerprint})
+}
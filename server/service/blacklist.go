@@ -0,0 +1,89 @@
+package service
+
+import (
+	"proxy-engineering-thesis/internal/proxy/blacklist"
+	"proxy-engineering-thesis/model"
+	"proxy-engineering-thesis/server/repository"
+	"strings"
+)
+
+type BlacklistService interface {
+	GetAll() ([]model.BlacklistEntry, error)
+	GetById(id uint) (*model.BlacklistEntry, error)
+	Create(req *model.BlacklistEntry) error
+	Update(id uint, req *model.BlacklistEntry) error
+	Delete(id uint) error
+	LoadEntries() error
+}
+
+// BlacklistServiceImpl keeps the access list enforced by running proxies in
+// sync with the entries stored in the database.
+type BlacklistServiceImpl struct {
+	blacklistRepository repository.BlacklistRepository
+	accessList          *blacklist.AccessList
+}
+
+func NewBlacklistService(blacklistRepository repository.BlacklistRepository, accessList *blacklist.AccessList) *BlacklistServiceImpl {
+	return &BlacklistServiceImpl{blacklistRepository: blacklistRepository, accessList: accessList}
+}
+
+func (bs *BlacklistServiceImpl) GetAll() ([]model.BlacklistEntry, error) {
+	return bs.blacklistRepository.GetAll()
+}
+
+func (bs *BlacklistServiceImpl) GetById(id uint) (*model.BlacklistEntry, error) {
+	return bs.blacklistRepository.Get(id)
+}
+
+func (bs *BlacklistServiceImpl) Create(req *model.BlacklistEntry) error {
+	if err := blacklist.ValidateEntry(*req); err != nil {
+		return err
+	}
+
+	req.Type = strings.ToLower(req.Type)
+	if err := bs.blacklistRepository.Create(req); err != nil {
+		return err
+	}
+
+	return bs.LoadEntries()
+}
+
+func (bs *BlacklistServiceImpl) Update(id uint, req *model.BlacklistEntry) error {
+	entry, err := bs.blacklistRepository.Get(id)
+	if err != nil {
+		return err
+	}
+
+	if err := blacklist.ValidateEntry(*req); err != nil {
+		return err
+	}
+
+	entry.Type = strings.ToLower(req.Type)
+	entry.Address = req.Address
+	entry.ExpiresAt = req.ExpiresAt
+	entry.Reason = req.Reason
+	if err := bs.blacklistRepository.Update(entry); err != nil {
+		return err
+	}
+
+	*req = *entry
+	return bs.LoadEntries()
+}
+
+func (bs *BlacklistServiceImpl) Delete(id uint) error {
+	if err := bs.blacklistRepository.Delete(id); err != nil {
+		return err
+	}
+
+	return bs.LoadEntries()
+}
+
+// LoadEntries refreshes the shared access list with the stored entries.
+func (bs *BlacklistServiceImpl) LoadEntries() error {
+	entries, err := bs.blacklistRepository.GetAll()
+	if err != nil {
+		return err
+	}
+
+	return bs.accessList.Update(entries)
+}
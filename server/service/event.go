@@ -0,0 +1,111 @@
+package service
+
+import (
+	"context"
+	"proxy-engineering-thesis/model"
+	"proxy-engineering-thesis/server/repository"
+	"sync"
+)
+
+const (
+	DefaultEventsPageSize = 50
+	MaxEventsPageSize     = 1000
+	// events waiting for the database are dropped once the queue is full, so
+	// that sessions never wait for it
+	eventsQueueSize = 256
+)
+
+type EventsPage struct {
+	Events []model.SecurityEvent
+	Total  int64
+	Page   int
+	Size   int
+}
+
+type EventService interface {
+	RecordEvent(event model.SecurityEvent)
+	Find(filter repository.EventFilter) (EventsPage, error)
+	Close(ctx context.Context)
+}
+
+type EventServiceImpl struct {
+	eventRepository repository.EventRepository
+	events          chan model.SecurityEvent
+	done            chan struct{}
+	closed          bool
+	lock            sync.RWMutex
+}
+
+func NewEventService(eventRepository repository.EventRepository) *EventServiceImpl {
+	es := &EventServiceImpl{
+		eventRepository: eventRepository,
+		events:          make(chan model.SecurityEvent, eventsQueueSize),
+		done:            make(chan struct{}),
+	}
+	go es.run()
+	return es
+}
+
+// run stores queued events one at a time and in order.
+func (es *EventServiceImpl) run() {
+	defer close(es.done)
+	for event := range es.events {
+		if err := es.eventRepository.Create(&event); err != nil {
+			log.Error("failed to store security event", "error", err)
+		}
+	}
+}
+
+// RecordEvent queues an event raised by a proxy session, to be persisted in
+// the background. Failures are only logged, so they never interrupt the
+// session.
+func (es *EventServiceImpl) RecordEvent(event model.SecurityEvent) {
+	es.lock.RLock()
+	defer es.lock.RUnlock()
+
+	if es.closed {
+		log.Warn("event service is closed, dropping security event", "proxy", event.ProxyName)
+		return
+	}
+	select {
+	case es.events <- event:
+	default:
+		log.Warn("events queue is full, dropping security event", "proxy", event.ProxyName)
+	}
+}
+
+// Close stores events still queued. Events are given up on when the context
+// is done.
+func (es *EventServiceImpl) Close(ctx context.Context) {
+	es.lock.Lock()
+	if !es.closed {
+		es.closed = true
+		close(es.events)
+	}
+	es.lock.Unlock()
+
+	select {
+	case <-es.done:
+	case <-ctx.Done():
+		log.Warn("security events weren't stored before the deadline")
+	}
+}
+
+func (es *EventServiceImpl) Find(filter repository.EventFilter) (EventsPage, error) {
+	if filter.Page < 1 {
+		filter.Page = 1
+	}
+	if filter.Size < 1 {
+		filter.Size = DefaultEventsPageSize
+	}
+	if filter.Size > MaxEventsPageSize {
+		filter.Size = MaxEventsPageSize
+	}
+
+	events, total, err := es.eventRepository.Find(filter)
+	if err != nil {
+		return EventsPage{}, err
+	}
+
+	return EventsPage{Events: events, Total: total, Page: filter.Page, Size: filter.Size}, nil
+}
@@ -0,0 +1,5 @@
+package service
+
+import "proxy-engineering-thesis/internal/logger"
+
+var log = logger.New("service")
@@ -1,14 +1,19 @@
 package service
 
 import (
+	"context"
 	"fmt"
-	"github.com/google/uuid"
 	"math"
+	"proxy-engineering-thesis/internal/metrics"
+	"proxy-engineering-thesis/internal/proxy/blacklist"
+	"proxy-engineering-thesis/internal/proxy/detection"
+	"proxy-engineering-thesis/internal/proxy/ratelimit"
 	"proxy-engineering-thesis/internal/proxy/relational"
 	"proxy-engineering-thesis/model"
 	"proxy-engineering-thesis/server/repository"
 	"proxy-engineering-thesis/server/storage"
 	"strconv"
+	"time"
 )
 
 type ProxyService interface {
@@ -20,19 +25,58 @@ type ProxyService interface {
 	StartProxy(id, proxyMode string) (string, error)
 	StopProxy(id string) error
 	GetProxySessionsCount(id string) (int, error)
+	GetProxySessions(id string) ([]relational.SessionInfo, error)
+	TerminateSession(id, sessionId string) error
+	GetRateLimitStats(id string) (ratelimit.Stats, error)
+	RestoreProxies() error
+	Shutdown(ctx context.Context)
 }
 
 type ProxyServiceImpl struct {
 	proxyRepository   repository.ProxyRepository
 	dataSourceService DataSourceService
 	proxiesStorage    *storage.ProxiesStorage
+	ruleSet           *detection.RuleSet
+	eventService      EventService
+	baselineService   BaselineService
+	blackList         *blacklist.BlackListManager
+	shutdownTimeout   time.Duration
+	bufferSize        int
+	maxMessageSize    int
+	proxyMetrics      *metrics.ProxyMetrics
+	slowQueryService  SlowQueryService
+	alertService      AlertService
 }
 
 func NewProxyService(
 	proxyRepository repository.ProxyRepository,
 	sourceService DataSourceService,
-	storage *storage.ProxiesStorage) *ProxyServiceImpl {
-	return &ProxyServiceImpl{proxyRepository: proxyRepository, dataSourceService: sourceService, proxiesStorage: storage}
+	storage *storage.ProxiesStorage,
+	ruleSet *detection.RuleSet,
+	eventService EventService,
+	baselineService BaselineService,
+	blackList *blacklist.BlackListManager,
+	shutdownTimeout time.Duration,
+	bufferSize int,
+	maxMessageSize int,
+	proxyMetrics *metrics.ProxyMetrics,
+	slowQueryService SlowQueryService,
+	alertService AlertService) *ProxyServiceImpl {
+	return &ProxyServiceImpl{
+		proxyRepository:   proxyRepository,
+		dataSourceService: sourceService,
+		proxiesStorage:    storage,
+		ruleSet:           ruleSet,
+		eventService:      eventService,
+		baselineService:   baselineService,
+		blackList:         blackList,
+		shutdownTimeout:   shutdownTimeout,
+		bufferSize:        bufferSize,
+		maxMessageSize:    maxMessageSize,
+		proxyMetrics:      proxyMetrics,
+		slowQueryService:  slowQueryService,
+		alertService:      alertService,
+	}
 }
 
 func (ps *ProxyServiceImpl) GetById(id string) (*model.ProxyDto, error) {
@@ -58,6 +102,12 @@ func (ps *ProxyServiceImpl) Create(req *model.ProxyDto) {
 }
 
 func (ps *ProxyServiceImpl) Delete(id string) error {
+	if _, present := ps.proxiesStorage.GetProxy(id); present {
+		if err := ps.StopProxy(id); err != nil {
+			return err
+		}
+	}
+
 	err := ps.proxyRepository.Delete(id)
 	if err != nil {
 		return err
@@ -76,6 +126,12 @@ func (ps *ProxyServiceImpl) StartProxy(id, proxyMode string) (string, error) {
 		return "", err
 	}
 
+	return ps.startProxy(proxyDto, proxyMode)
+}
+
+// startProxy runs the proxy under id of the stored proxy and remembers it
+// should be running.
+func (ps *ProxyServiceImpl) startProxy(proxyDto *model.ProxyDto, proxyMode string) (string, error) {
 	dsId := strconv.FormatUint(uint64(proxyDto.DataSourceID), 10)
 
 	ds, err := ps.dataSourceService.GetById(dsId)
@@ -84,26 +140,124 @@ func (ps *ProxyServiceImpl) StartProxy(id, proxyMode string) (string, error) {
 		return "", err
 	}
 
-	proxyConfig := relational.NewProxy(*proxyDto, *ds, proxyMode)
-	processId := uuid.New()
-	ps.proxiesStorage.AddProxyToStorage(proxyConfig, processId.String())
+	proxyConfig, err := relational.NewProxy(*proxyDto, *ds, proxyMode, ps.proxyServices())
+	if err != nil {
+		return "", err
+	}
+
+	processId := strconv.FormatUint(uint64(proxyDto.ID), 10)
+	if _, present := ps.proxiesStorage.GetProxy(processId); present {
+		return "", fmt.Errorf("proxy instance with given id is already running: %s", processId)
+	}
+
+	if err := proxyConfig.Listen(); err != nil {
+		return "", err
+	}
+
+	if _, err := ps.proxiesStorage.AddProxyToStorage(proxyConfig, processId); err != nil {
+		proxyConfig.Listener.Close()
+		return "", err
+	}
 	go proxyConfig.Start()
-	return processId.String(), nil
+
+	startedAt := time.Now()
+	if err := ps.proxyRepository.UpdateRunState(proxyDto.ID, true, proxyMode, &startedAt); err != nil {
+		log.Error("failed to store run state of proxy", "proxyId", processId, "error", err)
+	}
+	return processId, nil
 }
 
 func (ps *ProxyServiceImpl) StopProxy(id string) error {
-	err := ps.proxiesStorage.RemoveProxyFromStorage(id)
+	proxyConfig, present := ps.proxiesStorage.GetProxy(id)
+	if !present {
+		return fmt.Errorf("no proxy instance with given id: %s", id)
+	}
+
+	err := ps.proxiesStorage.RemoveProxyFromStorage(id, ps.shutdownTimeout)
+	if err != nil {
+		return err
+	}
+
+	if err := ps.proxyRepository.UpdateRunState(proxyConfig.Id, false, "", nil); err != nil {
+		log.Error("failed to store run state of proxy", "proxyId", id, "error", err)
+	}
+	return nil
+}
+
+// Shutdown drains all running proxies when the server is stopped. Their run
+// state is kept, so they are restored on the next start.
+func (ps *ProxyServiceImpl) Shutdown(ctx context.Context) {
+	ps.proxiesStorage.Shutdown(ctx)
+}
+
+// RestoreProxies starts proxies which were running when the server stopped.
+func (ps *ProxyServiceImpl) RestoreProxies() error {
+	proxies, err := ps.proxyRepository.GetAll()
 	if err != nil {
 		return err
 	}
+
+	for _, proxyDto := range proxies {
+		if !proxyDto.Running {
+			continue
+		}
+
+		proxyDto := proxyDto
+		if _, err := ps.startProxy(&proxyDto, proxyDto.RunMode); err != nil {
+			log.Error("failed to restore proxy", "proxyId", proxyDto.ID, "error", err)
+			continue
+		}
+		log.Info("restored proxy", "proxyId", proxyDto.ID, "mode", proxyDto.RunMode)
+	}
 	return nil
 }
 
 func (ps *ProxyServiceImpl) GetProxySessionsCount(id string) (int, error) {
-	proxy, present := ps.proxiesStorage.Proxies[id]
+	proxy, present := ps.proxiesStorage.GetProxy(id)
 	if !present {
 		return -math.MinInt8, fmt.Errorf("no proxy found with given id: %s", id)
 	}
 
-	return proxy.NumberOfSessions, nil
+	return proxy.GetNumberOfSessions(), nil
+}
+
+func (ps *ProxyServiceImpl) GetProxySessions(id string) ([]relational.SessionInfo, error) {
+	proxy, present := ps.proxiesStorage.GetProxy(id)
+	if !present {
+		return nil, fmt.Errorf("no proxy found with given id: %s", id)
+	}
+
+	return proxy.GetSessions(), nil
+}
+
+func (ps *ProxyServiceImpl) TerminateSession(id, sessionId string) error {
+	proxy, present := ps.proxiesStorage.GetProxy(id)
+	if !present {
+		return fmt.Errorf("no proxy found with given id: %s", id)
+	}
+
+	return proxy.TerminateSession(sessionId)
+}
+
+func (ps *ProxyServiceImpl) GetRateLimitStats(id string) (ratelimit.Stats, error) {
+	proxy, present := ps.proxiesStorage.GetProxy(id)
+	if !present {
+		return ratelimit.Stats{}, fmt.Errorf("no proxy found with given id: %s", id)
+	}
+
+	return proxy.RateLimiter.Stats(), nil
+}
+
+func (ps *ProxyServiceImpl) proxyServices() relational.Services {
+	return relational.Services{
+		RuleSet:          ps.ruleSet,
+		EventRecorder:    ps.eventService,
+		Baselines:        ps.baselineService,
+		BlackList:        ps.blackList,
+		Metrics:          ps.proxyMetrics,
+		SlowQueries:      ps.slowQueryService,
+		Alerts:           ps.alertService,
+		BufferSize:       ps.bufferSize,
+		MaxMessageLength: ps.maxMessageSize,
+	}
 }
@@ -0,0 +1,107 @@
+package service
+
+import (
+	"fmt"
+	"proxy-engineering-thesis/internal/proxy/detection"
+	"proxy-engineering-thesis/model"
+	"proxy-engineering-thesis/server/repository"
+)
+
+type RuleService interface {
+	GetAll() ([]model.Rule, error)
+	GetById(id uint) (*model.Rule, error)
+	Create(req *model.Rule) error
+	Update(id uint, req *model.Rule) error
+	Delete(id uint) error
+	LoadRules() error
+}
+
+// RuleServiceImpl keeps the rule set used by running proxies in sync with the
+// rules stored in the database.
+type RuleServiceImpl struct {
+	ruleRepository repository.RuleRepository
+	ruleSet        *detection.RuleSet
+}
+
+func NewRuleService(ruleRepository repository.RuleRepository, ruleSet *detection.RuleSet) *RuleServiceImpl {
+	return &RuleServiceImpl{ruleRepository: ruleRepository, ruleSet: ruleSet}
+}
+
+func (rs *RuleServiceImpl) GetAll() ([]model.Rule, error) {
+	return rs.ruleRepository.GetAll()
+}
+
+func (rs *RuleServiceImpl) GetById(id uint) (*model.Rule, error) {
+	return rs.ruleRepository.Get(id)
+}
+
+func (rs *RuleServiceImpl) Create(req *model.Rule) error {
+	if _, err := detection.CompileRule(*req); err != nil {
+		return err
+	}
+
+	if err := rs.ruleRepository.Create(req); err != nil {
+		return err
+	}
+
+	return rs.LoadRules()
+}
+
+func (rs *RuleServiceImpl) Update(id uint, req *model.Rule) error {
+	rule, err := rs.ruleRepository.Get(id)
+	if err != nil {
+		return err
+	}
+
+	if _, err := detection.CompileRule(*req); err != nil {
+		return err
+	}
+
+	rule.Name = req.Name
+	rule.PatternType = req.PatternType
+	rule.Pattern = req.Pattern
+	rule.Severity = req.Severity
+	rule.Enabled = req.Enabled
+	rule.Description = req.Description
+	rule.SQLState = req.SQLState
+	rule.ErrorMessage = req.ErrorMessage
+	if err := rs.ruleRepository.Update(rule); err != nil {
+		return err
+	}
+
+	*req = *rule
+	return rs.LoadRules()
+}
+
+func (rs *RuleServiceImpl) Delete(id uint) error {
+	if err := rs.ruleRepository.Delete(id); err != nil {
+		return err
+	}
+
+	return rs.LoadRules()
+}
+
+// LoadRules refreshes the shared rule set with the stored rules. The store is
+// seeded with the built-in rules on first run.
+func (rs *RuleServiceImpl) LoadRules() error {
+	count, err := rs.ruleRepository.Count()
+	if err != nil {
+		return err
+	}
+
+	if count == 0 {
+		for _, rule := range detection.DefaultRules() {
+			rule := rule
+			if err := rs.ruleRepository.Create(&rule); err != nil {
+				return fmt.Errorf("failed to seed default rules: %v", err)
+			}
+		}
+	}
+
+	rules, err := rs.ruleRepository.GetAll()
+	if err != nil {
+		return err
+	}
+
+	return rs.ruleSet.Update(rules)
+}
@@ -0,0 +1,110 @@
+package service
+
+import (
+	"context"
+	"proxy-engineering-thesis/model"
+	"proxy-engineering-thesis/server/repository"
+	"sync"
+)
+
+const (
+	DefaultSlowQueriesLimit = 10
+	MaxSlowQueriesLimit     = 100
+	// slow queries waiting for the database are dropped once the queue is
+	// full, so that sessions never wait for it
+	slowQueriesQueueSize = 256
+)
+
+type SlowQueryService interface {
+	RecordSlowQuery(query model.SlowQuery) bool
+	TopFingerprints(proxyId string, sortBy string, limit int) ([]repository.SlowQueryStats, error)
+	Close(ctx context.Context)
+}
+
+type SlowQueryServiceImpl struct {
+	slowQueryRepository repository.SlowQueryRepository
+	proxyRepository     repository.ProxyRepository
+	slowQueries         chan model.SlowQuery
+	done                chan struct{}
+	closed              bool
+	lock                sync.RWMutex
+}
+
+func NewSlowQueryService(slowQueryRepository repository.SlowQueryRepository, proxyRepository repository.ProxyRepository) *SlowQueryServiceImpl {
+	ss := &SlowQueryServiceImpl{
+		slowQueryRepository: slowQueryRepository,
+		proxyRepository:     proxyRepository,
+		slowQueries:         make(chan model.SlowQuery, slowQueriesQueueSize),
+		done:                make(chan struct{}),
+	}
+	go ss.run()
+	return ss
+}
+
+// run stores queued slow queries one at a time and in order.
+func (ss *SlowQueryServiceImpl) run() {
+	defer close(ss.done)
+	for query := range ss.slowQueries {
+		if err := ss.slowQueryRepository.Create(&query); err != nil {
+			log.Error("failed to store slow query", "error", err)
+		}
+	}
+}
+
+// RecordSlowQuery queues a slow query reported by a proxy session, to be
+// persisted in the background. It returns false when the query is dropped
+// instead. Failures are only logged, so they never interrupt the session.
+func (ss *SlowQueryServiceImpl) RecordSlowQuery(query model.SlowQuery) bool {
+	ss.lock.RLock()
+	defer ss.lock.RUnlock()
+
+	if ss.closed {
+		log.Warn("slow query service is closed, dropping slow query", "proxyId", query.ProxyID)
+		return false
+	}
+	select {
+	case ss.slowQueries <- query:
+		return true
+	default:
+		log.Warn("slow queries queue is full, dropping slow query", "proxyId", query.ProxyID)
+		return false
+	}
+}
+
+// Close stores slow queries still queued. They are given up on when the
+// context is done.
+func (ss *SlowQueryServiceImpl) Close(ctx context.Context) {
+	ss.lock.Lock()
+	if !ss.closed {
+		ss.closed = true
+		close(ss.slowQueries)
+	}
+	ss.lock.Unlock()
+
+	select {
+	case <-ss.done:
+	case <-ctx.Done():
+		log.Warn("slow queries weren't stored before the deadline")
+	}
+}
+
+// TopFingerprints lists the slowest fingerprints of the proxy, by total time
+// spent in them unless sortBy says otherwise.
+func (ss *SlowQueryServiceImpl) TopFingerprints(proxyId string, sortBy string, limit int) ([]repository.SlowQueryStats, error) {
+	proxy, err := ss.proxyRepository.Get(proxyId)
+	if err != nil {
+		return nil, err
+	}
+
+	if sortBy == "" {
+		sortBy = repository.SortByTotal
+	}
+	if limit < 1 {
+		limit = DefaultSlowQueriesLimit
+	}
+	if limit > MaxSlowQueriesLimit {
+		limit = MaxSlowQueriesLimit
+	}
+
+	return ss.slowQueryRepository.TopFingerprints(proxy.ID, sortBy, limit)
+}
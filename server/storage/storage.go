@@ -1,32 +1,81 @@
 package storage
 
 import (
+	"context"
 	"fmt"
 	"proxy-engineering-thesis/internal/proxy/relational"
+	"sync"
+	"time"
 )
 
+// ProxiesStorage holds running proxy instances keyed by id of the stored proxy.
 type ProxiesStorage struct {
 	Proxies map[string]*relational.ProxyConfiguration
+	lock    sync.RWMutex
 }
 
 func NewProxiesStorage() *ProxiesStorage {
 	return &ProxiesStorage{
-		make(map[string]*relational.ProxyConfiguration),
+		Proxies: make(map[string]*relational.ProxyConfiguration),
 	}
 }
 
-func (p *ProxiesStorage) AddProxyToStorage(proxy *relational.ProxyConfiguration, id string) string {
+func (p *ProxiesStorage) AddProxyToStorage(proxy *relational.ProxyConfiguration, id string) (string, error) {
+	p.lock.Lock()
+	defer p.lock.Unlock()
+
+	if _, present := p.Proxies[id]; present {
+		return "", fmt.Errorf("proxy instance with given id is already running: %s", id)
+	}
 	p.Proxies[id] = proxy
-	return id
+	return id, nil
 }
 
-func (p *ProxiesStorage) RemoveProxyFromStorage(id string) error {
+func (p *ProxiesStorage) GetProxy(id string) (*relational.ProxyConfiguration, bool) {
+	p.lock.RLock()
+	defer p.lock.RUnlock()
+
+	proxy, present := p.Proxies[id]
+	return proxy, present
+}
+
+// RemoveProxyFromStorage stops the proxy. Its sessions are drained in the
+// background until they become idle or the timeout passes.
+func (p *ProxiesStorage) RemoveProxyFromStorage(id string, timeout time.Duration) error {
+	p.lock.Lock()
 	proxyConf := p.Proxies[id]
+	delete(p.Proxies, id)
+	p.lock.Unlock()
+
 	if proxyConf == nil {
 		return fmt.Errorf("no proxy instance with given id: %s", id)
 	}
-	proxyConf.CloseSessions()
 	proxyConf.Listener.Close()
-	delete(p.Proxies, id)
+	go func() {
+		ctx, cancel := context.WithTimeout(context.Background(), timeout)
+		defer cancel()
+		proxyConf.Shutdown(ctx)
+	}()
 	return nil
 }
+
+// Shutdown stops all proxies, waiting for their sessions to drain until the
+// context is done. Proxies are kept in the storage.
+func (p *ProxiesStorage) Shutdown(ctx context.Context) {
+	p.lock.RLock()
+	proxies := make([]*relational.ProxyConfiguration, 0, len(p.Proxies))
+	for _, proxyConf := range p.Proxies {
+		proxies = append(proxies, proxyConf)
+	}
+	p.lock.RUnlock()
+
+	var wg sync.WaitGroup
+	for _, proxyConf := range proxies {
+		wg.Add(1)
+		go func(proxyConf *relational.ProxyConfiguration) {
+			defer wg.Done()
+			proxyConf.Shutdown(ctx)
+		}(proxyConf)
+	}
+	wg.Wait()
+}